@@ -10,33 +10,44 @@ import (
 // ParseHCL2 unmarshals HCL files that are written using
 // version 2 of the HCL language and return parsed file content.
 func ParseHCL2(p []byte, v interface{}) (err error) {
-	result := terraform.ParseModule(map[string]interface{}{
-		"foo.tf": string(p),
-	})
+	_, err = parseHCL2(p, v, terraform.DefaultOptions())
+	return err
+}
 
-	parsedFiles, ok := result["parsedFiles"]
-	if !ok {
-		return errors.Errorf("no parsed files returned")
+// ParseHCL2WithSuppressions unmarshals HCL files like ParseHCL2
+// and also returns the suppressions found in their comments.
+func ParseHCL2WithSuppressions(p []byte, v interface{}) (Suppressions, error) {
+	options := terraform.DefaultOptions()
+	options.PreserveComments = true
+	result, err := parseHCL2(p, v, options)
+	if err != nil {
+		return nil, err
 	}
+	return extractHCL2Suppressions(result.Comments["foo.tf"], result.SourceMaps["foo.tf"]), nil
+}
 
-	parsedFilesMap, ok := parsedFiles.(map[string]interface{})
-	if !ok {
-		return errors.Errorf("invalid parsed files format")
+func parseHCL2(p []byte, v interface{}, options terraform.Options) (*terraform.ParseModuleResult, error) {
+	result := terraform.ParseModuleFiles(map[string][]byte{
+		"foo.tf": p,
+	}, options)
+
+	if err, ok := result.FailedFiles["foo.tf"]; ok {
+		return nil, errors.Wrap(err, "parse file")
 	}
 
-	parsed, ok := parsedFilesMap["foo.tf"]
+	parsed, ok := result.ParsedFiles["foo.tf"]
 	if !ok {
-		return errors.Errorf("parse file")
+		return nil, errors.Errorf("parse file")
 	}
 
-	parsedString, ok := parsed.(string)
-	if !ok {
-		return errors.Errorf("invalid parse result type")
+	parsedBytes, err := json.Marshal(parsed)
+	if err != nil {
+		return nil, errors.Errorf("marshal parse result: %v", err)
 	}
 
-	if err := json.Unmarshal([]byte(parsedString), v); err != nil {
-		return errors.Errorf("unmarshal parse result: %v", err)
+	if err := json.Unmarshal(parsedBytes, v); err != nil {
+		return nil, errors.Errorf("unmarshal parse result: %v", err)
 	}
 
-	return nil
+	return result, nil
 }
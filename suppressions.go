@@ -0,0 +1,359 @@
+package parsers
+
+import (
+	"bufio"
+	"bytes"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snyk/snyk-iac-parsers/terraform"
+)
+
+// SuppressionScope tells which part of a file a suppression applies to
+type SuppressionScope string
+
+const (
+	// LINE_SCOPE applies to a single line, e.g. an attribute
+	LINE_SCOPE SuppressionScope = "line"
+	// BLOCK_SCOPE applies to the lines of a block, e.g. a resource, or to a resource of a Terraform plan
+	BLOCK_SCOPE SuppressionScope = "block"
+	// FILE_SCOPE applies to the whole file
+	FILE_SCOPE SuppressionScope = "file"
+)
+
+const (
+	// SUPPRESSION_DIRECTIVE suppresses rules for the line, block or resource it is attached to
+	SUPPRESSION_DIRECTIVE = "snyk:ignore"
+	// FILE_SUPPRESSION_DIRECTIVE suppresses rules for the whole file
+	FILE_SUPPRESSION_DIRECTIVE = "snyk:ignore-file"
+	// SUPPRESSION_EXPIRY_LAYOUT is the layout of the expires option of the directives
+	SUPPRESSION_EXPIRY_LAYOUT = "2006-01-02"
+)
+
+// Suppression is an inline ignore found in a comment, written as
+//
+//	snyk:ignore SNYK-CC-TF-1,SNYK-CC-TF-2 [expires=2024-12-31] [address=aws_s3_bucket.logs] [justification...]
+//
+// snyk:ignore-file takes the same arguments and applies to the whole file
+// A directive without rule IDs or with an invalid expiry date is skipped, so that it never suppresses more than intended
+type Suppression struct {
+	RuleIDs []string         `json:"ruleIds"`
+	Scope   SuppressionScope `json:"scope"`
+	// StartLine and EndLine are the lines the suppression applies to, which are not set for the file scope
+	// and for the resources of a Terraform plan
+	StartLine int `json:"startLine,omitempty"`
+	EndLine   int `json:"endLine,omitempty"`
+	// Path is the JSON path of the HCL block or attribute the suppression is attached to,
+	// or the address of the resource of a Terraform plan it applies to
+	Path string `json:"path,omitempty"`
+	// Expires is the day after which the suppression no longer applies, in the SUPPRESSION_EXPIRY_LAYOUT layout
+	Expires       string `json:"expires,omitempty"`
+	Justification string `json:"justification,omitempty"`
+	// Line is the line of the directive itself
+	Line int `json:"line"`
+}
+
+// Suppressions are the suppressions of a file, in the order of their directives
+type Suppressions []Suppression
+
+// Expired tells whether the suppression no longer applies at the provided time
+func (suppression Suppression) Expired(now time.Time) bool {
+	if suppression.Expires == "" {
+		return false
+	}
+	expires, err := time.Parse(SUPPRESSION_EXPIRY_LAYOUT, suppression.Expires)
+	if err != nil {
+		return true
+	}
+	// the suppression applies until the end of the day it expires on
+	return !now.Before(expires.AddDate(0, 0, 1))
+}
+
+// Active returns the suppressions which have not expired at the provided time
+func (suppressions Suppressions) Active(now time.Time) Suppressions {
+	active := Suppressions{}
+	for _, suppression := range suppressions {
+		if !suppression.Expired(now) {
+			active = append(active, suppression)
+		}
+	}
+	return active
+}
+
+// parseSuppressionDirective parses the text of a comment, without its delimiters, into a suppression
+// The scope and the lines of the suppression are left to the caller, which knows what the comment is attached to
+func parseSuppressionDirective(text string) (Suppression, bool) {
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		return Suppression{}, false
+	}
+
+	var suppression Suppression
+	switch fields[0] {
+	case SUPPRESSION_DIRECTIVE:
+	case FILE_SUPPRESSION_DIRECTIVE:
+		suppression.Scope = FILE_SCOPE
+	default:
+		return Suppression{}, false
+	}
+
+	for _, ruleID := range strings.Split(fields[1], ",") {
+		if ruleID == "" || strings.Contains(ruleID, "=") {
+			return Suppression{}, false
+		}
+		suppression.RuleIDs = append(suppression.RuleIDs, ruleID)
+	}
+
+	// the options come first, and the justification is the rest of the text
+	rest := fields[2:]
+	for len(rest) > 0 {
+		key, value, ok := cutOption(rest[0])
+		if !ok {
+			break
+		}
+		switch key {
+		case "expires":
+			if _, err := time.Parse(SUPPRESSION_EXPIRY_LAYOUT, value); err != nil {
+				return Suppression{}, false
+			}
+			suppression.Expires = value
+		case "address":
+			suppression.Path = value
+		}
+		rest = rest[1:]
+	}
+	suppression.Justification = strings.Join(rest, " ")
+
+	return suppression, true
+}
+
+func cutOption(field string) (string, string, bool) {
+	i := strings.Index(field, "=")
+	if i <= 0 {
+		return "", "", false
+	}
+	key := field[:i]
+	if key != "expires" && key != "address" {
+		return "", "", false
+	}
+	return key, field[i+1:], true
+}
+
+// extractHCL2Suppressions reads the directives of the comments of a parsed HCL file
+// A directive applies to the block or attribute its comment is attached to, or to the line after it when it is not attached
+func extractHCL2Suppressions(comments terraform.Comments, sourceMap terraform.SourceMap) Suppressions {
+	suppressions := Suppressions{}
+	for path, pathComments := range comments {
+		for _, comment := range pathComments {
+			suppression, ok := parseSuppressionDirective(comment.Text)
+			if !ok {
+				continue
+			}
+			suppression.Line = comment.Range.Start.Line
+
+			switch {
+			case suppression.Scope == FILE_SCOPE:
+				suppression.Path = ""
+			case path == "":
+				suppression.Scope = LINE_SCOPE
+				suppression.StartLine = comment.Range.End.Line
+				if comment.Placement == terraform.LEADING_COMMENT {
+					suppression.StartLine++
+				}
+				suppression.EndLine = suppression.StartLine
+			default:
+				sourceRange := sourceMap[path]
+				suppression.Scope = LINE_SCOPE
+				if sourceRange.End.Line > sourceRange.Start.Line {
+					suppression.Scope = BLOCK_SCOPE
+				}
+				suppression.Path = path
+				suppression.StartLine = sourceRange.Start.Line
+				suppression.EndLine = sourceRange.End.Line
+			}
+			suppressions = append(suppressions, suppression)
+		}
+	}
+
+	sortSuppressions(suppressions)
+	return suppressions
+}
+
+// extractYAMLSuppressions reads the directives of the comments of a YAML file
+// A directive at the end of a line applies to that line, and a directive on its own line applies to the next line,
+// or to the whole block it starts when it is followed by a more indented block
+func extractYAMLSuppressions(p []byte) Suppressions {
+	lines := splitLines(p)
+	suppressions := Suppressions{}
+
+	// the content of block scalars, e.g. key: |, is not made of comments
+	blockScalarIndent := -1
+	for i, line := range lines {
+		indent := indentation(line)
+		trimmed := strings.TrimSpace(line)
+		if blockScalarIndent >= 0 {
+			if trimmed == "" || indent > blockScalarIndent {
+				continue
+			}
+			blockScalarIndent = -1
+		}
+
+		content, comment, ok := cutYAMLComment(line)
+		if isBlockScalarHeader(content) {
+			blockScalarIndent = indent
+		}
+		if !ok {
+			continue
+		}
+		suppression, ok := parseSuppressionDirective(comment)
+		if !ok {
+			continue
+		}
+		suppression.Line = i + 1
+
+		switch {
+		case suppression.Scope == FILE_SCOPE:
+			suppression.Path = ""
+		case strings.TrimSpace(content) != "":
+			suppression.Scope = LINE_SCOPE
+			suppression.StartLine = i + 1
+			suppression.EndLine = i + 1
+		default:
+			start, end, ok := nextYAMLBlock(lines, i+1)
+			if !ok {
+				continue
+			}
+			suppression.Scope = LINE_SCOPE
+			if end > start {
+				suppression.Scope = BLOCK_SCOPE
+			}
+			suppression.StartLine = start
+			suppression.EndLine = end
+		}
+		suppressions = append(suppressions, suppression)
+	}
+
+	return suppressions
+}
+
+// nextYAMLBlock returns the first and last lines of the block starting at the first line after the provided index
+// which is neither blank nor a comment, made of the lines indented more than it and, for a key without an inline value,
+// of the items of the sequence it holds
+func nextYAMLBlock(lines []string, from int) (int, int, bool) {
+	for i := from; i < len(lines); i++ {
+		content, _, _ := cutYAMLComment(lines[i])
+		content = strings.TrimSpace(content)
+		if content == "" {
+			continue
+		}
+		if content == "---" || content == "..." {
+			return 0, 0, false
+		}
+
+		indent := indentation(lines[i])
+		isKey := strings.HasSuffix(content, ":")
+		end := i
+		for j := i + 1; j < len(lines); j++ {
+			nestedContent := strings.TrimSpace(lines[j])
+			nestedIndent := indentation(lines[j])
+			// the comments only extend the block when they are nested in it, e.g. in a block scalar
+			if nestedContent == "" || (strings.HasPrefix(nestedContent, "#") && nestedIndent <= indent) {
+				continue
+			}
+			if nestedIndent < indent || (nestedIndent == indent && !(isKey && strings.HasPrefix(nestedContent, "- "))) {
+				break
+			}
+			end = j
+		}
+		return i + 1, end + 1, true
+	}
+	return 0, 0, false
+}
+
+// cutYAMLComment splits a line into its content and the text of its comment, if any
+// A comment starts with a # at the beginning of the line or after a whitespace, outside of a quoted string
+func cutYAMLComment(line string) (string, string, bool) {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			if i == 0 || strings.ContainsRune(" \t:[{,-", rune(line[i-1])) {
+				quote = r
+			}
+		case r == '#':
+			if i == 0 || line[i-1] == ' ' || line[i-1] == '\t' {
+				return line[:i], strings.TrimSpace(line[i+1:]), true
+			}
+		}
+	}
+	return line, "", false
+}
+
+// isBlockScalarHeader tells whether the content of a line ends with the indicator of a block scalar, e.g. | or >-
+func isBlockScalarHeader(content string) bool {
+	fields := strings.Fields(content)
+	if len(fields) == 0 {
+		return false
+	}
+	indicator := fields[len(fields)-1]
+	if indicator[0] != '|' && indicator[0] != '>' {
+		return false
+	}
+	return strings.Trim(indicator[1:], "+-0123456789") == ""
+}
+
+// extractTerraformPlanSuppressions reads the directives of the sidecar file of a Terraform plan, which the plan itself
+// cannot hold as JSON has no comments
+// Each line of the sidecar file is a directive, and the lines starting with # are comments
+// A directive with the address option applies to that resource, and a directive without it to the whole plan
+func extractTerraformPlanSuppressions(sidecar []byte) Suppressions {
+	suppressions := Suppressions{}
+	for i, line := range splitLines(sidecar) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		suppression, ok := parseSuppressionDirective(line)
+		if !ok {
+			continue
+		}
+		suppression.Line = i + 1
+
+		if suppression.Scope != FILE_SCOPE && suppression.Path != "" {
+			suppression.Scope = BLOCK_SCOPE
+		} else {
+			suppression.Scope = FILE_SCOPE
+			suppression.Path = ""
+		}
+		suppressions = append(suppressions, suppression)
+	}
+	return suppressions
+}
+
+func splitLines(p []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(p))
+	scanner.Buffer(nil, len(p)+1)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSuffix(scanner.Text(), "\r"))
+	}
+	return lines
+}
+
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+func sortSuppressions(suppressions Suppressions) {
+	sort.SliceStable(suppressions, func(i, j int) bool {
+		if suppressions[i].Line != suppressions[j].Line {
+			return suppressions[i].Line < suppressions[j].Line
+		}
+		return suppressions[i].Path < suppressions[j].Path
+	})
+}
@@ -0,0 +1,114 @@
+package parsers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseHCL2WithSuppressions(t *testing.T) {
+	input := []byte(`# snyk:ignore-file SNYK-CC-TF-9 generated file
+
+# snyk:ignore SNYK-CC-TF-1,SNYK-CC-TF-2 expires=2024-12-31 public website
+resource "aws_s3_bucket" "x" {
+	acl = "public-read" // snyk:ignore SNYK-CC-TF-3
+	/* snyk:ignore SNYK-CC-TF-4 */
+	versioning {
+		enabled = false
+	}
+	# snyk:ignore expires=2024-12-31
+	# not a directive
+}`)
+
+	var actual interface{}
+	suppressions, err := ParseHCL2WithSuppressions(input, &actual)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actual == nil {
+		t.Fatalf("expected the parsed document")
+	}
+
+	expected := Suppressions{
+		{RuleIDs: []string{"SNYK-CC-TF-9"}, Scope: FILE_SCOPE, Justification: "generated file", Line: 1},
+		{RuleIDs: []string{"SNYK-CC-TF-1", "SNYK-CC-TF-2"}, Scope: BLOCK_SCOPE, StartLine: 4, EndLine: 12, Path: "resource.aws_s3_bucket.x", Expires: "2024-12-31", Justification: "public website", Line: 3},
+		{RuleIDs: []string{"SNYK-CC-TF-3"}, Scope: LINE_SCOPE, StartLine: 5, EndLine: 5, Path: "resource.aws_s3_bucket.x.acl", Line: 5},
+		{RuleIDs: []string{"SNYK-CC-TF-4"}, Scope: BLOCK_SCOPE, StartLine: 7, EndLine: 9, Path: "resource.aws_s3_bucket.x.versioning", Line: 6},
+	}
+	if !reflect.DeepEqual(suppressions, expected) {
+		t.Errorf("expected %+v, got %+v", expected, suppressions)
+	}
+}
+
+func TestParseYAMLWithSuppressions(t *testing.T) {
+	input := []byte(`# snyk:ignore-file SNYK-CC-K8S-1
+apiVersion: v1
+kind: Pod
+spec:
+  # snyk:ignore SNYK-CC-K8S-2 expires=2024-01-31 needs root
+  containers:
+  - name: app
+    image: "app#1" # snyk:ignore SNYK-CC-K8S-3
+    args: |
+      # snyk:ignore SNYK-CC-K8S-4
+  # snyk:ignore SNYK-CC-K8S-5
+  hostNetwork: true
+`)
+
+	var actual interface{}
+	suppressions, err := ParseYAMLWithSuppressions(input, &actual)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := Suppressions{
+		{RuleIDs: []string{"SNYK-CC-K8S-1"}, Scope: FILE_SCOPE, Line: 1},
+		{RuleIDs: []string{"SNYK-CC-K8S-2"}, Scope: BLOCK_SCOPE, StartLine: 6, EndLine: 10, Expires: "2024-01-31", Justification: "needs root", Line: 5},
+		{RuleIDs: []string{"SNYK-CC-K8S-3"}, Scope: LINE_SCOPE, StartLine: 8, EndLine: 8, Line: 8},
+		{RuleIDs: []string{"SNYK-CC-K8S-5"}, Scope: LINE_SCOPE, StartLine: 12, EndLine: 12, Line: 11},
+	}
+	if !reflect.DeepEqual(suppressions, expected) {
+		t.Errorf("expected %+v, got %+v", expected, suppressions)
+	}
+}
+
+func TestParseTerraformPlanWithSuppressions(t *testing.T) {
+	sidecar := []byte(`# suppressions of the plan
+snyk:ignore SNYK-CC-TF-1 address=aws_s3_bucket.logs expires=2024-12-31 access logs
+snyk:ignore-file SNYK-CC-TF-2
+snyk:ignore SNYK-CC-TF-3
+snyk:ignore SNYK-CC-TF-4 expires=31/12/2024
+`)
+
+	var actual interface{}
+	suppressions, err := ParseTerraformPlanWithSuppressions([]byte(`{"resource_changes": []}`), sidecar, &actual)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := Suppressions{
+		{RuleIDs: []string{"SNYK-CC-TF-1"}, Scope: BLOCK_SCOPE, Path: "aws_s3_bucket.logs", Expires: "2024-12-31", Justification: "access logs", Line: 2},
+		{RuleIDs: []string{"SNYK-CC-TF-2"}, Scope: FILE_SCOPE, Line: 3},
+		{RuleIDs: []string{"SNYK-CC-TF-3"}, Scope: FILE_SCOPE, Line: 4},
+	}
+	if !reflect.DeepEqual(suppressions, expected) {
+		t.Errorf("expected %+v, got %+v", expected, suppressions)
+	}
+}
+
+func TestSuppressionsActive(t *testing.T) {
+	suppressions := Suppressions{
+		{RuleIDs: []string{"SNYK-CC-TF-1"}, Expires: "2024-12-31"},
+		{RuleIDs: []string{"SNYK-CC-TF-2"}},
+	}
+
+	lastDay := time.Date(2024, 12, 31, 23, 59, 0, 0, time.UTC)
+	if actual := suppressions.Active(lastDay); !reflect.DeepEqual(actual, suppressions) {
+		t.Errorf("expected %+v, got %+v", suppressions, actual)
+	}
+
+	nextDay := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if actual := suppressions.Active(nextDay); !reflect.DeepEqual(actual, suppressions[1:]) {
+		t.Errorf("expected %+v, got %+v", suppressions[1:], actual)
+	}
+}
@@ -0,0 +1,170 @@
+package terraform
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// CommentPlacement tells whether a comment precedes or follows the block or attribute it is attached to
+type CommentPlacement string
+
+const (
+	// LEADING_COMMENT is a comment on the lines right above a block or attribute
+	LEADING_COMMENT CommentPlacement = "leading"
+	// TRAILING_COMMENT is a comment at the end of the line of a block or attribute
+	TRAILING_COMMENT CommentPlacement = "trailing"
+)
+
+// Comment is a comment of a file, attached to the nearest block or attribute
+type Comment struct {
+	// Text is the content of the comment without its delimiters and surrounding whitespace,
+	// e.g. snyk:ignore SNYK-CC-TF-1 reason for # snyk:ignore SNYK-CC-TF-1 reason
+	Text      string           `json:"text"`
+	Placement CommentPlacement `json:"placement"`
+	Range     SourceRange      `json:"range"`
+}
+
+// Comments maps the JSON paths of a source map to the comments attached to them
+// The comments which are not attached to any block or attribute, e.g. the header of a file, are kept under the empty path
+type Comments map[string][]Comment
+
+// ExtractComments lexes the comments of a provided HCL file and attaches them to the JSON paths of its source map,
+// as returned by ParseHclToDocument
+// A leading comment is attached to the outermost path declared on the line following it, with no blank line in between,
+// and a trailing comment to the outermost path ending, or else starting, before it on the same line
+// The JSON syntax has no comments, so the comments of a .tf.json file are always empty
+func ExtractComments(fileName string, fileContent string, sourceMap SourceMap) Comments {
+	comments := Comments{}
+	if isJsonFile(fileName) {
+		return comments
+	}
+
+	tokens, _ := hclsyntax.LexConfig([]byte(fileContent), fileName, hcl.Pos{Line: 1, Column: 1})
+	// the line of the last token which is neither a comment nor a newline
+	lastLine := 0
+	// the leading comments on consecutive lines are attached together to the path following the last of them
+	var group []Comment
+	attachGroup := func() {
+		if len(group) == 0 {
+			return
+		}
+		paths := leadingCommentPaths(group[len(group)-1].Range.End.Line+1, sourceMap)
+		for _, path := range paths {
+			comments[path] = append(comments[path], group...)
+		}
+		group = nil
+	}
+
+	for _, token := range tokens {
+		switch token.Type {
+		case hclsyntax.TokenNewline, hclsyntax.TokenEOF:
+			continue
+		case hclsyntax.TokenComment:
+			comment := newComment(token)
+			if token.Range.Start.Line == lastLine {
+				comment.Placement = TRAILING_COMMENT
+				for _, path := range trailingCommentPaths(comment.Range.Start, sourceMap) {
+					comments[path] = append(comments[path], comment)
+				}
+				continue
+			}
+
+			comment.Placement = LEADING_COMMENT
+			if len(group) > 0 && group[len(group)-1].Range.End.Line+1 != comment.Range.Start.Line {
+				attachGroup()
+			}
+			group = append(group, comment)
+		default:
+			attachGroup()
+			lastLine = token.Range.End.Line
+		}
+	}
+	attachGroup()
+
+	return comments
+}
+
+func newComment(token hclsyntax.Token) Comment {
+	text := string(token.Bytes)
+	switch {
+	case strings.HasPrefix(text, "#"):
+		text = strings.TrimPrefix(text, "#")
+	case strings.HasPrefix(text, "//"):
+		text = strings.TrimPrefix(text, "//")
+	case strings.HasPrefix(text, "/*"):
+		text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+	}
+
+	r := token.Range
+	// the line comments hold the newline which ends them
+	if strings.HasSuffix(string(token.Bytes), "\n") && r.End.Line > r.Start.Line {
+		r.End = hcl.Pos{Line: r.End.Line - 1, Column: r.Start.Column + len(strings.TrimRight(string(token.Bytes), "\r\n"))}
+	}
+
+	return Comment{
+		Text:  strings.TrimSpace(text),
+		Range: newSourceRange(r),
+	}
+}
+
+// leadingCommentPaths returns the outermost paths declared first on the provided line,
+// or the empty path when there are none
+func leadingCommentPaths(line int, sourceMap SourceMap) []string {
+	return nearestPaths(sourceMap, func(sourceRange SourceRange) (int, bool) {
+		return -sourceRange.Start.Column, sourceRange.Start.Line == line
+	})
+}
+
+// trailingCommentPaths returns the outermost paths ending last before the provided position on its line,
+// or else starting last before it, or the empty path when there are none
+func trailingCommentPaths(pos SourcePos, sourceMap SourceMap) []string {
+	paths := nearestPaths(sourceMap, func(sourceRange SourceRange) (int, bool) {
+		return sourceRange.End.Column, sourceRange.End.Line == pos.Line && sourceRange.End.Column <= pos.Column
+	})
+	if len(paths) == 1 && paths[0] == "" {
+		paths = nearestPaths(sourceMap, func(sourceRange SourceRange) (int, bool) {
+			return sourceRange.Start.Column, sourceRange.Start.Line == pos.Line && sourceRange.Start.Column < pos.Column
+		})
+	}
+	return paths
+}
+
+// nearestPaths returns the outermost of the matching paths with the highest score, which are several when the same block
+// is emitted more than once, e.g. the instances of a resource with count
+func nearestPaths(sourceMap SourceMap, score func(SourceRange) (int, bool)) []string {
+	var nearest []string
+	bestScore := 0
+	for path, sourceRange := range sourceMap {
+		pathScore, ok := score(sourceRange)
+		switch {
+		case !ok:
+		case len(nearest) == 0 || pathScore > bestScore:
+			nearest = []string{path}
+			bestScore = pathScore
+		case pathScore == bestScore:
+			nearest = append(nearest, path)
+		}
+	}
+	if len(nearest) == 0 {
+		return []string{""}
+	}
+
+	var paths []string
+	for _, path := range nearest {
+		outermost := true
+		for _, other := range nearest {
+			if strings.HasPrefix(path, other+".") || strings.HasPrefix(path, other+"[") {
+				outermost = false
+				break
+			}
+		}
+		if outermost {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,142 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractComments(t *testing.T) {
+	input := `# main configuration
+
+# snyk:ignore SNYK-CC-TF-1 reason
+// owned by the platform team
+resource "aws_s3_bucket" "x" { # bucket
+	acl = "public-read" # snyk:ignore SNYK-CC-TF-2
+	/* tags */
+	tags = {
+		Name = "x"
+	}
+} # end`
+
+	_, sourceMap, err := ParseHclToDocument("main.tf", input, ModuleVariables{}, DefaultOptions())
+	require.Nil(t, err)
+
+	comment := func(text string, placement CommentPlacement, line int, start int, end int) Comment {
+		return Comment{
+			Text:      text,
+			Placement: placement,
+			Range: SourceRange{
+				FileName: "main.tf",
+				Start:    SourcePos{Line: line, Column: start},
+				End:      SourcePos{Line: line, Column: end},
+			},
+		}
+	}
+	assert.Equal(t, Comments{
+		"": {
+			comment("main configuration", LEADING_COMMENT, 1, 1, 21),
+		},
+		"resource.aws_s3_bucket.x": {
+			comment("snyk:ignore SNYK-CC-TF-1 reason", LEADING_COMMENT, 3, 1, 34),
+			comment("owned by the platform team", LEADING_COMMENT, 4, 1, 30),
+			comment("bucket", TRAILING_COMMENT, 5, 32, 40),
+			comment("end", TRAILING_COMMENT, 11, 3, 8),
+		},
+		"resource.aws_s3_bucket.x.acl": {
+			comment("snyk:ignore SNYK-CC-TF-2", TRAILING_COMMENT, 6, 22, 48),
+		},
+		"resource.aws_s3_bucket.x.tags": {
+			comment("tags", LEADING_COMMENT, 7, 2, 12),
+		},
+	}, ExtractComments("main.tf", input, sourceMap))
+
+	assert.Equal(t, Comments{}, ExtractComments("main.tf.json", `{}`, SourceMap{}))
+}
+
+func TestParseModuleFilesPreservesComments(t *testing.T) {
+	files := map[string][]byte{
+		"main.tf": []byte(`
+# snyk:ignore SNYK-CC-TF-1
+resource "aws_instance" "web" {
+	count = 2
+}`),
+	}
+	options := DefaultOptions()
+	options.ExpandCountAndForEach = true
+
+	parseRes := ParseModuleFiles(files, options)
+	assert.Empty(t, parseRes.Comments)
+
+	options.PreserveComments = true
+	parseRes = ParseModuleFiles(files, options)
+	comment := Comment{
+		Text:      "snyk:ignore SNYK-CC-TF-1",
+		Placement: LEADING_COMMENT,
+		Range: SourceRange{
+			FileName: "main.tf",
+			Start:    SourcePos{Line: 2, Column: 1},
+			End:      SourcePos{Line: 2, Column: 27},
+		},
+	}
+	assert.Equal(t, map[string]Comments{
+		"main.tf": {
+			`resource.aws_instance.web["0"]`: {comment},
+			`resource.aws_instance.web["1"]`: {comment},
+		},
+	}, parseRes.Comments)
+}
+
+func TestParseModuleFilesMovesOverrideComments(t *testing.T) {
+	files := map[string][]byte{
+		"main.tf": []byte(`
+resource "aws_s3_bucket" "logs" {
+	# the bucket is private
+	acl    = "private"
+	bucket = "logs" # the name of the bucket
+}`),
+		"main_override.tf": []byte(`
+# snyk:ignore SNYK-CC-TF-1
+resource "aws_s3_bucket" "logs" {
+	acl = "public-read" # the logs are public
+}`),
+	}
+	options := DefaultOptions()
+	options.PreserveComments = true
+
+	parseRes := ParseModuleFiles(files, options)
+	// the comments of the override file are attached to the paths it overrides in the base file,
+	// and replace the comments of the overridden attributes
+	assert.Equal(t, map[string]Comments{
+		"main.tf": {
+			"resource.aws_s3_bucket.logs": {{
+				Text:      "snyk:ignore SNYK-CC-TF-1",
+				Placement: LEADING_COMMENT,
+				Range: SourceRange{
+					FileName: "main_override.tf",
+					Start:    SourcePos{Line: 2, Column: 1},
+					End:      SourcePos{Line: 2, Column: 27},
+				},
+			}},
+			"resource.aws_s3_bucket.logs.acl": {{
+				Text:      "the logs are public",
+				Placement: TRAILING_COMMENT,
+				Range: SourceRange{
+					FileName: "main_override.tf",
+					Start:    SourcePos{Line: 4, Column: 22},
+					End:      SourcePos{Line: 4, Column: 43},
+				},
+			}},
+			"resource.aws_s3_bucket.logs.bucket": {{
+				Text:      "the name of the bucket",
+				Placement: TRAILING_COMMENT,
+				Range: SourceRange{
+					FileName: "main.tf",
+					Start:    SourcePos{Line: 5, Column: 18},
+					End:      SourcePos{Line: 5, Column: 42},
+				},
+			}},
+		},
+	}, parseRes.Comments)
+}
@@ -1,12 +1,50 @@
 package terraform
 
 const (
-	TF          = ".tf"
-	TFVARS      = ".tfvars"
-	AUTO_TFVARS = ".auto.tfvars"
+	TF               = ".tf"
+	TF_JSON          = ".tf.json"
+	TFVARS           = ".tfvars"
+	TFVARS_JSON      = ".tfvars.json"
+	AUTO_TFVARS      = ".auto.tfvars"
+	AUTO_TFVARS_JSON = ".auto.tfvars.json"
 
-	DEFAULT_TFVARS = "terraform.tfvars"
+	DEFAULT_TFVARS      = "terraform.tfvars"
+	DEFAULT_TFVARS_JSON = "terraform.tfvars.json"
+
+	// TF_VAR_PREFIX is the prefix of the environment variables which set the value of input variables
+	TF_VAR_PREFIX = "TF_VAR_"
+
+	// DYNAMIC_BLOCK_KEY marks a dynamic block which could not be rendered because its for_each value is not known
+	DYNAMIC_BLOCK_KEY = "__dynamic__"
+	// UNKNOWN_VALUE_KEY marks an expression which could not be evaluated, when structured unknown markers are requested
+	UNKNOWN_VALUE_KEY = "__unknown__"
+	// REFERENCE_KEY marks a reference to an attribute of a resource or data block which is only known after apply
+	REFERENCE_KEY = "__reference__"
+	// REDACTED_VALUE_KEY marks a value which was redacted, alongside the reason it was, e.g. SENSITIVE_REDACTION_REASON
+	REDACTED_VALUE_KEY = "__redacted__"
+
+	// SENSITIVE_REDACTION_REASON is used for the values derived from a variable declared with sensitive = true
+	SENSITIVE_REDACTION_REASON = "sensitive"
+)
+
+// The reasons why the value of an expression is not known
+const (
+	// UNRESOLVED_REFERENCE_REASON is used when the expression references a value which is not known, e.g. an attribute of a resource
+	UNRESOLVED_REFERENCE_REASON = "unresolved reference"
+	// UNSUPPORTED_FUNCTION_REASON is used when the expression calls a function the parser does not implement
+	UNSUPPORTED_FUNCTION_REASON = "unsupported function"
+	// INVALID_EXPRESSION_REASON is used when the expression fails to evaluate although all its references are known
+	INVALID_EXPRESSION_REASON = "invalid expression"
+	// NOT_EVALUATED_REASON is used when the expressions are not evaluated at all, as the Simplify option is not set
+	NOT_EVALUATED_REASON = "not evaluated"
+)
+
+// The types of the variable sources which are passed to the Terraform CLI rather than found in the module
+const (
+	VAR_SOURCE      VariableSourceType = "var"
+	VAR_FILE_SOURCE VariableSourceType = "varFile"
+	ENV_SOURCE      VariableSourceType = "env"
 )
 
-var VALID_VARIABLE_FILES = [...]string{TF, AUTO_TFVARS}
-var VALID_TERRAFORM_FILES = [...]string{TF}
+var VALID_VARIABLE_FILES = [...]string{TF, TF_JSON, AUTO_TFVARS, AUTO_TFVARS_JSON}
+var VALID_TERRAFORM_FILES = [...]string{TF, TF_JSON}
@@ -1,9 +1,47 @@
 package terraform
 
-import "fmt"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// ErrorCode is the stable code of a kind of error, which does not change with the wording of its message
+type ErrorCode string
+
+const (
+	INVALID_HCL_ERROR           ErrorCode = "INVALID_HCL"
+	INTERNAL_HCL_PARSING_ERROR  ErrorCode = "INTERNAL_HCL_PARSING"
+	INTERNAL_JSON_PARSING_ERROR ErrorCode = "INTERNAL_JSON_PARSING"
+	INVALID_MODULE_ERROR        ErrorCode = "INVALID_MODULE"
+	INVALID_OVERRIDE_ERROR      ErrorCode = "INVALID_OVERRIDE"
+	INVALID_VARIABLE_ERROR      ErrorCode = "INVALID_VARIABLE"
+	TOO_MANY_INSTANCES_WARNING  ErrorCode = "TOO_MANY_INSTANCES"
+)
+
+// DiagnosticSeverity is the severity of a diagnostic
+type DiagnosticSeverity string
+
+const (
+	ERROR_SEVERITY   DiagnosticSeverity = "error"
+	WARNING_SEVERITY DiagnosticSeverity = "warning"
+)
+
+// The sentinel errors of each error code, e.g. errors.Is(err, ErrInvalidHCL)
+var (
+	ErrInvalidHCL          = &CustomError{message: "Invalid HCL provided", code: INVALID_HCL_ERROR, userError: true}
+	ErrInternalHCLParsing  = &CustomError{message: "Unable to convert HCL to JSON object", code: INTERNAL_HCL_PARSING_ERROR}
+	ErrInternalJSONParsing = &CustomError{message: "Unable to convert JSON object to string", code: INTERNAL_JSON_PARSING_ERROR}
+	ErrInvalidModule       = &CustomError{message: "Unable to resolve module", code: INVALID_MODULE_ERROR, userError: true}
+	ErrInvalidOverride     = &CustomError{message: "Unable to apply override file", code: INVALID_OVERRIDE_ERROR, userError: true}
+	ErrInvalidVariable     = &CustomError{message: "Invalid variable value provided", code: INVALID_VARIABLE_ERROR, userError: true}
+)
 
 type CustomError struct {
 	message   string
+	code      ErrorCode
 	errors    []error
 	userError bool
 }
@@ -12,6 +50,144 @@ func (err *CustomError) Error() string {
 	return err.message
 }
 
+// Code returns the stable code of the error
+func (err *CustomError) Code() ErrorCode {
+	return err.code
+}
+
+// IsUserError tells whether the error is caused by the provided files rather than by the parser
+func (err *CustomError) IsUserError() bool {
+	return err.userError
+}
+
+// Diagnostics returns a diagnostic for each of the errors which caused the error,
+// or a single diagnostic holding its message when there are none
+func (err *CustomError) Diagnostics() []*Diagnostic {
+	if len(err.errors) == 0 {
+		return []*Diagnostic{{
+			Severity: ERROR_SEVERITY,
+			Code:     err.code,
+			Summary:  err.message,
+		}}
+	}
+
+	diagnostics := make([]*Diagnostic, 0, len(err.errors))
+	for _, e := range err.errors {
+		diagnostics = append(diagnostics, newDiagnostic(err.code, e))
+	}
+	return diagnostics
+}
+
+// Is matches the sentinel error of the same code, and the errors which caused the error
+func (err *CustomError) Is(target error) bool {
+	if sentinel, ok := target.(*CustomError); ok && sentinel.code != "" && sentinel.code == err.code {
+		return true
+	}
+	for _, e := range err.errors {
+		if errors.Is(e, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first diagnostic of the error, or the first error which caused the error matching the target
+func (err *CustomError) As(target interface{}) bool {
+	if diagnostic, ok := target.(**Diagnostic); ok {
+		*diagnostic = err.Diagnostics()[0]
+		return true
+	}
+	for _, e := range err.errors {
+		if errors.As(e, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON returns the message, code and diagnostics of the error, e.g. for the failedFiles of ParseModule
+func (err *CustomError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Message     string        `json:"message"`
+		Code        ErrorCode     `json:"code"`
+		UserError   bool          `json:"userError"`
+		Diagnostics []*Diagnostic `json:"diagnostics"`
+	}{
+		Message:     err.message,
+		Code:        err.code,
+		UserError:   err.userError,
+		Diagnostics: err.Diagnostics(),
+	})
+}
+
+// Diagnostic is a single problem found while parsing, with the range of the file it was found at when it is known
+type Diagnostic struct {
+	Severity DiagnosticSeverity `json:"severity"`
+	Code     ErrorCode          `json:"code"`
+	Summary  string             `json:"summary"`
+	Detail   string             `json:"detail,omitempty"`
+	Range    *SourceRange       `json:"range,omitempty"`
+}
+
+func (diagnostic *Diagnostic) Error() string {
+	message := diagnostic.Summary
+	if diagnostic.Detail != "" {
+		message = fmt.Sprintf("%s; %s", message, diagnostic.Detail)
+	}
+	if diagnostic.Range == nil {
+		return message
+	}
+	return fmt.Sprintf("%s:%d,%d: %s", diagnostic.Range.FileName, diagnostic.Range.Start.Line, diagnostic.Range.Start.Column, message)
+}
+
+// Is matches the sentinel error of the same code
+func (diagnostic *Diagnostic) Is(target error) bool {
+	sentinel, ok := target.(*CustomError)
+	return ok && sentinel.code != "" && sentinel.code == diagnostic.Code
+}
+
+// newDiagnostic converts an error into a diagnostic, taking the severity, summary, detail and range of the HCL diagnostics
+func newDiagnostic(code ErrorCode, err error) *Diagnostic {
+	var diagnostic *Diagnostic
+	if errors.As(err, &diagnostic) {
+		return diagnostic
+	}
+
+	var hclDiagnostic *hcl.Diagnostic
+	if !errors.As(err, &hclDiagnostic) {
+		return &Diagnostic{
+			Severity: ERROR_SEVERITY,
+			Code:     code,
+			Summary:  err.Error(),
+		}
+	}
+
+	diagnostic = &Diagnostic{
+		Severity: ERROR_SEVERITY,
+		Code:     code,
+		Summary:  hclDiagnostic.Summary,
+		Detail:   hclDiagnostic.Detail,
+	}
+	if hclDiagnostic.Severity == hcl.DiagWarning {
+		diagnostic.Severity = WARNING_SEVERITY
+	}
+	if hclDiagnostic.Subject != nil {
+		sourceRange := newSourceRange(*hclDiagnostic.Subject)
+		diagnostic.Range = &sourceRange
+	}
+	return diagnostic
+}
+
+// diagnosticsOf returns the diagnostics of an error, which is a single diagnostic holding its message
+// when it is not a CustomError
+func diagnosticsOf(err error) []*Diagnostic {
+	var customError *CustomError
+	if errors.As(err, &customError) {
+		return customError.Diagnostics()
+	}
+	return []*Diagnostic{newDiagnostic("", err)}
+}
+
 func GenerateDebugLogs(err error) string {
 	customError, ok := err.(*CustomError)
 
@@ -24,35 +200,35 @@ func GenerateDebugLogs(err error) string {
 	return debugging
 }
 
-func isUserError(err error) bool {
-	switch e := err.(type) {
-	case *CustomError:
-		return e.userError
-	default:
-		return false
+func newCustomError(sentinel *CustomError, errors []error) *CustomError {
+	return &CustomError{
+		message:   sentinel.message,
+		code:      sentinel.code,
+		errors:    errors,
+		userError: sentinel.userError,
 	}
 }
 
 func createInvalidHCLError(errors []error) *CustomError {
-	return &CustomError{
-		message:   "Invalid HCL provided",
-		errors:    errors,
-		userError: true,
-	}
+	return newCustomError(ErrInvalidHCL, errors)
 }
 
 func createInternalHCLParsingError(errors []error) *CustomError {
-	return &CustomError{
-		message:   "Unable to convert HCL to JSON object",
-		errors:    errors,
-		userError: false,
-	}
+	return newCustomError(ErrInternalHCLParsing, errors)
 }
 
 func createInternalJSONParsingError(errors []error) *CustomError {
-	return &CustomError{
-		message:   "Unable to convert JSON object to string",
-		errors:    errors,
-		userError: false,
-	}
+	return newCustomError(ErrInternalJSONParsing, errors)
+}
+
+func createInvalidModuleError(errors []error) *CustomError {
+	return newCustomError(ErrInvalidModule, errors)
+}
+
+func createInvalidOverrideError(errors []error) *CustomError {
+	return newCustomError(ErrInvalidOverride, errors)
+}
+
+func createInvalidVariableError(errors []error) *CustomError {
+	return newCustomError(ErrInvalidVariable, errors)
 }
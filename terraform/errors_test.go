@@ -1,9 +1,11 @@
 package terraform
 
 import (
+	"encoding/json"
 	"errors"
 	"testing"
 
+	"github.com/hashicorp/hcl/v2"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -32,9 +34,58 @@ func TestGenerateDebugLogsCustomErrorWithErrors(t *testing.T) {
 }
 
 func TestIsUserError(t *testing.T) {
-	assert.False(t, isUserError(errors.New("Test")))
-	assert.False(t, isUserError(&CustomError{
+	assert.False(t, (&CustomError{
 		message: "Test",
 		errors:  []error{},
+	}).IsUserError())
+	assert.True(t, createInvalidHCLError([]error{}).IsUserError())
+	assert.False(t, createInternalHCLParsingError([]error{}).IsUserError())
+}
+
+func TestCustomErrorDiagnostics(t *testing.T) {
+	_, err := ParseHclToJson("main.tf", `resource "aws_s3_bucket" "x" {
+	acl = 
+}`, ModuleVariables{})
+
+	assert.True(t, errors.Is(err, ErrInvalidHCL))
+	assert.False(t, errors.Is(err, ErrInvalidModule))
+
+	var diagnostic *Diagnostic
+	assert.True(t, errors.As(err, &diagnostic))
+	assert.Equal(t, &Diagnostic{
+		Severity: ERROR_SEVERITY,
+		Code:     INVALID_HCL_ERROR,
+		Summary:  "Invalid expression",
+		Detail:   "Expected the start of an expression, but found an invalid expression token.",
+		Range: &SourceRange{
+			FileName: "main.tf",
+			Start:    SourcePos{Line: 2, Column: 8},
+			End:      SourcePos{Line: 3, Column: 1},
+		},
+	}, diagnostic)
+	assert.Equal(t, "main.tf:2,8: Invalid expression; Expected the start of an expression, but found an invalid expression token.", diagnostic.Error())
+	assert.True(t, errors.Is(diagnostic, ErrInvalidHCL))
+
+	var hclDiagnostic *hcl.Diagnostic
+	assert.True(t, errors.As(err, &hclDiagnostic))
+	assert.Equal(t, "Invalid expression", hclDiagnostic.Summary)
+}
+
+func TestCustomErrorMarshalJSON(t *testing.T) {
+	jsonBytes, err := json.Marshal(createInvalidModuleError([]error{
+		errors.New("module source ../outside is outside of the root directory"),
 	}))
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{
+		"message": "Unable to resolve module",
+		"code": "INVALID_MODULE",
+		"userError": true,
+		"diagnostics": [
+			{
+				"severity": "error",
+				"code": "INVALID_MODULE",
+				"summary": "module source ../outside is outside of the root directory"
+			}
+		]
+	}`, string(jsonBytes))
 }
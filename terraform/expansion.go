@@ -0,0 +1,214 @@
+package terraform
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	ctyconvert "github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+// maxResourceInstances is the maximum number of instances a resource or data block is expanded into,
+// above which the block is emitted as it is declared
+var maxResourceInstances = 1000
+
+// resourceInstance is a single instance of a resource or data block which uses count or for_each
+type resourceInstance struct {
+	key       string
+	variables ValueMap
+}
+
+// FormatInstanceName returns the name of a resource instance, in the same format used for the resources of a Terraform plan
+func FormatInstanceName(name string, key string) string {
+	return fmt.Sprintf(`%s["%s"]`, name, key)
+}
+
+func isResourceBlock(block *hclsyntax.Block) bool {
+	return (block.Type == "resource" || block.Type == "data") && len(block.Labels) == 2
+}
+
+// resourceInstances evaluates the count or for_each meta-argument of a resource
+// It returns false if the block uses neither of them or if their value is not known
+func (parser *Parser) resourceInstances(body *hclsyntax.Body) ([]resourceInstance, bool) {
+	if attr, ok := body.Attributes["count"]; ok {
+		return parser.countInstances(attr.Expr)
+	}
+	if attr, ok := body.Attributes["for_each"]; ok {
+		return parser.forEachInstances(attr.Expr)
+	}
+	return nil, false
+}
+
+// Logic inspired from https://github.com/hashicorp/terraform/blob/v1.0.0/internal/terraform/eval_count.go
+func (parser *Parser) countInstances(expr hclsyntax.Expression) ([]resourceInstance, bool) {
+	value, diags := expr.Value(parser.evalContext())
+	// like Terraform, sensitive values cannot be used as count
+	if diags.HasErrors() || !value.IsKnown() || value.IsNull() || value.ContainsMarked() {
+		return nil, false
+	}
+
+	value, err := ctyconvert.Convert(value, cty.Number)
+	if err != nil {
+		return nil, false
+	}
+	var count int
+	if err := gocty.FromCtyValue(value, &count); err != nil || count < 0 {
+		return nil, false
+	}
+	if count > maxResourceInstances {
+		parser.warnTooManyInstances("count", count, expr.Range())
+		return nil, false
+	}
+
+	instances := make([]resourceInstance, 0, count)
+	for i := 0; i < count; i++ {
+		instances = append(instances, resourceInstance{
+			key: fmt.Sprintf("%d", i),
+			variables: ValueMap{
+				"count": cty.ObjectVal(map[string]cty.Value{
+					"index": cty.NumberIntVal(int64(i)),
+				}),
+			},
+		})
+	}
+	return instances, true
+}
+
+// Logic inspired from https://github.com/hashicorp/terraform/blob/v1.0.0/internal/terraform/eval_for_each.go
+func (parser *Parser) forEachInstances(expr hclsyntax.Expression) ([]resourceInstance, bool) {
+	value, diags := expr.Value(parser.evalContext())
+	// like Terraform, sensitive values cannot be used as for_each
+	if diags.HasErrors() || !value.IsWhollyKnown() || value.IsNull() || value.ContainsMarked() {
+		return nil, false
+	}
+
+	// like Terraform, only maps and sets of strings can be used with for_each
+	valueType := value.Type()
+	isSet := valueType.IsSetType()
+	if !(isSet || valueType.IsMapType() || valueType.IsObjectType()) {
+		return nil, false
+	}
+
+	if length := value.LengthInt(); length > maxResourceInstances {
+		parser.warnTooManyInstances("for_each", length, expr.Range())
+		return nil, false
+	}
+
+	instances := make([]resourceInstance, 0, value.LengthInt())
+	for it := value.ElementIterator(); it.Next(); {
+		key, elem := it.Element()
+		if isSet {
+			key = elem
+		}
+		key, err := ctyconvert.Convert(key, cty.String)
+		if err != nil || key.IsNull() {
+			return nil, false
+		}
+		instances = append(instances, resourceInstance{
+			key: key.AsString(),
+			variables: ValueMap{
+				"each": cty.ObjectVal(map[string]cty.Value{
+					"key":   key,
+					"value": elem,
+				}),
+			},
+		})
+	}
+	return instances, true
+}
+
+// warnTooManyInstances records that a block is not expanded because its meta-argument declares too many instances
+func (parser *Parser) warnTooManyInstances(metaArgument string, count int, exprRange hcl.Range) {
+	sourceRange := newSourceRange(exprRange)
+	*parser.diagnostics = append(*parser.diagnostics, &Diagnostic{
+		Severity: WARNING_SEVERITY,
+		Code:     TOO_MANY_INSTANCES_WARNING,
+		Summary:  "Too many instances",
+		Detail:   fmt.Sprintf("The %s meta-argument declares %d instances, more than the maximum of %d, so the block is not expanded", metaArgument, count, maxResourceInstances),
+		Range:    &sourceRange,
+	})
+}
+
+// parseResourceInstances emits each instance of a resource under its instance name, without the count and for_each meta-arguments
+func (parser *Parser) parseResourceInstances(block *hclsyntax.Block, instances []resourceInstance, out JSON, path string) error {
+	for _, instance := range instances {
+		labels := []string{block.Labels[0], FormatInstanceName(block.Labels[1], instance.key)}
+		value, instancePath, err := parser.withVariables(instance.variables).parseLabelledBody(block.Type, labels, block.Body, block.Range(), out, path)
+		if err != nil {
+			return err
+		}
+
+		for _, metaArgument := range []string{"count", "for_each"} {
+			delete(value, metaArgument)
+			delete(parser.sourceMap, joinPath(instancePath, metaArgument))
+		}
+	}
+	return nil
+}
+
+func isDynamicBlock(block *hclsyntax.Block) bool {
+	return block.Type == "dynamic" && len(block.Labels) == 1
+}
+
+// parseDynamicBlock renders a dynamic block into one nested block of its labelled type per element of its for_each value
+// When the for_each value is not known, a single placeholder block holding the dynamic block under DYNAMIC_BLOCK_KEY is emitted instead
+// Logic inspired from https://github.com/hashicorp/hcl/tree/v2.6.0/ext/dynblock
+func (parser *Parser) parseDynamicBlock(block *hclsyntax.Block, out JSON, path string) error {
+	blockType := block.Labels[0]
+
+	iteratorName := blockType
+	if attr, ok := block.Body.Attributes["iterator"]; ok {
+		if name := hcl.ExprAsKeyword(attr.Expr); name != "" {
+			iteratorName = name
+		}
+	}
+
+	var content *hclsyntax.Block
+	for _, nestedBlock := range block.Body.Blocks {
+		if nestedBlock.Type == "content" {
+			content = nestedBlock
+		}
+	}
+
+	forEach, ok := block.Body.Attributes["for_each"]
+	if content == nil || !ok {
+		return parser.parseDynamicBlockPlaceholder(block, out, path)
+	}
+
+	value, diags := forEach.Expr.Value(parser.evalContext())
+	// the elements of a sensitive value cannot be iterated over, while the sensitive elements of a value are redacted in the content
+	if diags.HasErrors() || !value.IsKnown() || value.IsNull() || value.IsMarked() || !value.CanIterateElements() {
+		return parser.parseDynamicBlockPlaceholder(block, out, path)
+	}
+
+	for it := value.ElementIterator(); it.Next(); {
+		key, elem := it.Element()
+		iterator := cty.ObjectVal(map[string]cty.Value{
+			"key":   key,
+			"value": elem,
+		})
+		_, _, err := parser.withVariables(ValueMap{iteratorName: iterator}).parseLabelledBody(blockType, nil, content.Body, content.Range(), out, path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (parser *Parser) parseDynamicBlockPlaceholder(block *hclsyntax.Block, out JSON, path string) error {
+	value, blockPath, err := parser.parseLabelledBody(block.Labels[0], nil, block.Body, block.Range(), out, path)
+	if err != nil {
+		return err
+	}
+
+	dynamic := make(JSON, len(value))
+	for key, attribute := range value {
+		dynamic[key] = attribute
+		delete(value, key)
+		parser.sourceMap.movePrefix(joinPath(blockPath, key), joinPath(joinPath(blockPath, DYNAMIC_BLOCK_KEY), key))
+	}
+	value[DYNAMIC_BLOCK_KEY] = dynamic
+	return nil
+}
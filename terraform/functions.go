@@ -1,14 +1,18 @@
 package terraform
 
 import (
+	"github.com/hashicorp/hcl/v2/ext/tryfunc"
+	"github.com/zclconf/go-cty-yaml"
+	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/function"
 	"github.com/zclconf/go-cty/cty/function/stdlib"
 )
 
 // Function definitions were taken from https://github.com/tmccombs/hcl2json/tree/a80b1cd24d787567ec3e93b6806077b8d6ee4d3d/convert/stdlib.go
+// and https://github.com/hashicorp/terraform/blob/v1.5.0/internal/lang/functions.go
 
-// a subset of functions used in terraform
-// that can be used when simplifying during conversion
+// the functions used in terraform that can be used when simplifying during conversion
+// The filesystem functions (e.g. file or templatefile) and the impure functions (e.g. timestamp or uuid) are not part of them
 var terraformFunctions = map[string]function.Function{
 	// numeric
 	"abs":      stdlib.AbsoluteFunc,
@@ -22,34 +26,103 @@ var terraformFunctions = map[string]function.Function{
 	"signum":   stdlib.SignumFunc,
 
 	// string
-	"chomp":      stdlib.ChompFunc,
-	"format":     stdlib.FormatFunc,
-	"formatlist": stdlib.FormatListFunc,
-	"indent":     stdlib.IndentFunc,
-	"join":       stdlib.JoinFunc,
-	"split":      stdlib.SplitFunc,
-	"strrev":     stdlib.ReverseFunc,
-	"trim":       stdlib.TrimFunc,
-	"trimprefix": stdlib.TrimPrefixFunc,
-	"trimsuffix": stdlib.TrimSuffixFunc,
-	"trimspace":  stdlib.TrimSpaceFunc,
+	"chomp":       stdlib.ChompFunc,
+	"endswith":    endswithFunc,
+	"format":      stdlib.FormatFunc,
+	"formatlist":  stdlib.FormatListFunc,
+	"indent":      stdlib.IndentFunc,
+	"join":        stdlib.JoinFunc,
+	"lower":       stdlib.LowerFunc,
+	"regex":       stdlib.RegexFunc,
+	"regexall":    stdlib.RegexAllFunc,
+	"replace":     replaceFunc,
+	"split":       stdlib.SplitFunc,
+	"startswith":  startswithFunc,
+	"strcontains": strcontainsFunc,
+	"strrev":      stdlib.ReverseFunc,
+	"substr":      stdlib.SubstrFunc,
+	"title":       stdlib.TitleFunc,
+	"trim":        stdlib.TrimFunc,
+	"trimprefix":  stdlib.TrimPrefixFunc,
+	"trimsuffix":  stdlib.TrimSuffixFunc,
+	"trimspace":   stdlib.TrimSpaceFunc,
+	"upper":       stdlib.UpperFunc,
 
 	// collections
-	"chunklist": stdlib.ChunklistFunc,
-	"concat":    stdlib.ConcatFunc,
-	"distinct":  stdlib.DistinctFunc,
-	"flatten":   stdlib.FlattenFunc,
-	"length":    stdlib.LengthFunc,
-	"merge":     stdlib.MergeFunc,
-	"reverse":   stdlib.ReverseListFunc,
-	"sort":      stdlib.SortFunc,
+	"alltrue":         alltrueFunc,
+	"anytrue":         anytrueFunc,
+	"chunklist":       stdlib.ChunklistFunc,
+	"coalesce":        coalesceFunc,
+	"coalescelist":    stdlib.CoalesceListFunc,
+	"compact":         stdlib.CompactFunc,
+	"concat":          stdlib.ConcatFunc,
+	"contains":        stdlib.ContainsFunc,
+	"distinct":        stdlib.DistinctFunc,
+	"element":         stdlib.ElementFunc,
+	"flatten":         stdlib.FlattenFunc,
+	"index":           indexFunc,
+	"keys":            stdlib.KeysFunc,
+	"length":          lengthFunc,
+	"lookup":          lookupFunc,
+	"matchkeys":       matchkeysFunc,
+	"merge":           stdlib.MergeFunc,
+	"one":             oneFunc,
+	"range":           stdlib.RangeFunc,
+	"reverse":         stdlib.ReverseListFunc,
+	"setintersection": stdlib.SetIntersectionFunc,
+	"setproduct":      stdlib.SetProductFunc,
+	"setsubtract":     stdlib.SetSubtractFunc,
+	"setunion":        stdlib.SetUnionFunc,
+	"slice":           stdlib.SliceFunc,
+	"sort":            stdlib.SortFunc,
+	"sum":             sumFunc,
+	"transpose":       transposeFunc,
+	"values":          stdlib.ValuesFunc,
+	"zipmap":          stdlib.ZipmapFunc,
 
 	// encoding
-	"csvdecode":  stdlib.CSVDecodeFunc,
-	"jsondecode": stdlib.JSONDecodeFunc,
-	"jsonencode": stdlib.JSONEncodeFunc,
+	"base64decode":     base64DecodeFunc,
+	"base64encode":     base64EncodeFunc,
+	"base64gzip":       base64GzipFunc,
+	"csvdecode":        stdlib.CSVDecodeFunc,
+	"jsondecode":       stdlib.JSONDecodeFunc,
+	"jsonencode":       stdlib.JSONEncodeFunc,
+	"textdecodebase64": textDecodeBase64Func,
+	"textencodebase64": textEncodeBase64Func,
+	"urlencode":        urlEncodeFunc,
+	"yamldecode":       yaml.YAMLDecodeFunc,
+	"yamlencode":       yaml.YAMLEncodeFunc,
 
 	// time
 	"formatdate": stdlib.FormatDateFunc,
 	"timeadd":    stdlib.TimeAddFunc,
+	"timecmp":    timeCmpFunc,
+
+	// hash and crypto
+	"base64sha256": base64Sha256Func,
+	"base64sha512": base64Sha512Func,
+	"md5":          md5Func,
+	"rsadecrypt":   rsaDecryptFunc,
+	"sha1":         sha1Func,
+	"sha256":       sha256Func,
+	"sha512":       sha512Func,
+	"uuidv5":       uuidV5Func,
+
+	// ip network
+	"cidrhost":    cidrHostFunc,
+	"cidrnetmask": cidrNetmaskFunc,
+	"cidrsubnet":  cidrSubnetFunc,
+	"cidrsubnets": cidrSubnetsFunc,
+
+	// type conversion
+	"can":          tryfunc.CanFunc,
+	"nonsensitive": nonsensitiveFunc,
+	"sensitive":    sensitiveFunc,
+	"tobool":       stdlib.MakeToFunc(cty.Bool),
+	"tolist":       stdlib.MakeToFunc(cty.List(cty.DynamicPseudoType)),
+	"tomap":        stdlib.MakeToFunc(cty.Map(cty.DynamicPseudoType)),
+	"tonumber":     stdlib.MakeToFunc(cty.Number),
+	"toset":        stdlib.MakeToFunc(cty.Set(cty.DynamicPseudoType)),
+	"tostring":     stdlib.MakeToFunc(cty.String),
+	"try":          tryfunc.TryFunc,
 }
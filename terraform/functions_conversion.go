@@ -0,0 +1,54 @@
+package terraform
+
+import (
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// Function definitions were taken from https://github.com/hashicorp/terraform/blob/v1.5.0/internal/lang/funcs/sensitive.go
+
+// sensitiveFunc marks a value as sensitive, so that it is redacted like the values of the sensitive variables
+var sensitiveFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name:             "value",
+			Type:             cty.DynamicPseudoType,
+			AllowUnknown:     true,
+			AllowNull:        true,
+			AllowMarked:      true,
+			AllowDynamicType: true,
+		},
+	},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		return args[0].Type(), nil
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		value, _ := args[0].Unmark()
+		return value.Mark(sensitiveMark), nil
+	},
+})
+
+// nonsensitiveFunc removes the sensitive marking from a value
+var nonsensitiveFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name:             "value",
+			Type:             cty.DynamicPseudoType,
+			AllowUnknown:     true,
+			AllowNull:        true,
+			AllowMarked:      true,
+			AllowDynamicType: true,
+		},
+	},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		return args[0].Type(), nil
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		if args[0].IsKnown() && !args[0].HasMark(sensitiveMark) {
+			return cty.DynamicVal, function.NewArgErrorf(0, "the given value is not sensitive, so this call is redundant")
+		}
+		value, marks := args[0].Unmark()
+		delete(marks, sensitiveMark)
+		return value.WithMarks(marks), nil
+	},
+})
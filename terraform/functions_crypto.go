@@ -0,0 +1,148 @@
+package terraform
+
+import (
+	"crypto/md5"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// Function definitions were taken from https://github.com/hashicorp/terraform/blob/v1.0.0/internal/lang/funcs/crypto.go
+// The impure functions (bcrypt and uuid) and the ones reading files are not part of them
+
+// the namespaces defined by RFC 4122 for name-based UUIDs
+var uuidNamespaces = map[string]string{
+	"dns":  "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+	"url":  "6ba7b811-9dad-11d1-80b4-00c04fd430c8",
+	"oid":  "6ba7b812-9dad-11d1-80b4-00c04fd430c8",
+	"x500": "6ba7b814-9dad-11d1-80b4-00c04fd430c8",
+}
+
+// uuidV5Func generates a name-based UUID, as described in RFC 4122 section 4.3
+var uuidV5Func = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name: "namespace",
+			Type: cty.String,
+		},
+		{
+			Name: "name",
+			Type: cty.String,
+		},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		namespace := args[0].AsString()
+		if wellKnown, ok := uuidNamespaces[namespace]; ok {
+			namespace = wellKnown
+		}
+
+		namespaceBytes, err := hex.DecodeString(strings.Replace(namespace, "-", "", -1))
+		if err != nil || len(namespaceBytes) != 16 {
+			return cty.UnknownVal(cty.String), fmt.Errorf("uuidv5() doesn't support namespace %s", args[0].AsString())
+		}
+
+		hash := sha1.New()
+		hash.Write(namespaceBytes)
+		hash.Write([]byte(args[1].AsString()))
+		uuid := hash.Sum(nil)[:16]
+		uuid[6] = (uuid[6] & 0x0f) | 0x50 // version 5
+		uuid[8] = (uuid[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+		encoded := hex.EncodeToString(uuid)
+		return cty.StringVal(fmt.Sprintf("%s-%s-%s-%s-%s", encoded[0:8], encoded[8:12], encoded[12:16], encoded[16:20], encoded[20:32])), nil
+	},
+})
+
+// base64Sha256Func computes the SHA256 hash of a given string and encodes it with Base64
+var base64Sha256Func = makeStringHashFunction(sha256.New, base64.StdEncoding.EncodeToString)
+
+// base64Sha512Func computes the SHA512 hash of a given string and encodes it with Base64
+var base64Sha512Func = makeStringHashFunction(sha512.New, base64.StdEncoding.EncodeToString)
+
+// md5Func computes the MD5 hash of a given string and encodes it with hexadecimal digits
+var md5Func = makeStringHashFunction(md5.New, hex.EncodeToString)
+
+// sha1Func computes the SHA1 hash of a given string and encodes it with hexadecimal digits
+var sha1Func = makeStringHashFunction(sha1.New, hex.EncodeToString)
+
+// sha256Func computes the SHA256 hash of a given string and encodes it with hexadecimal digits
+var sha256Func = makeStringHashFunction(sha256.New, hex.EncodeToString)
+
+// sha512Func computes the SHA512 hash of a given string and encodes it with hexadecimal digits
+var sha512Func = makeStringHashFunction(sha512.New, hex.EncodeToString)
+
+func makeStringHashFunction(hf func() hash.Hash, enc func([]byte) string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "str",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			h := hf()
+			h.Write([]byte(args[0].AsString()))
+			return cty.StringVal(enc(h.Sum(nil))), nil
+		},
+	})
+}
+
+// rsaDecryptFunc decrypts an RSA-encrypted ciphertext, returning the corresponding cleartext
+// The private key must be PEM encoded, in either the PKCS #1 or the PKCS #8 format
+var rsaDecryptFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name: "ciphertext",
+			Type: cty.String,
+		},
+		{
+			Name: "privatekey",
+			Type: cty.String,
+		},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		b, err := base64.StdEncoding.DecodeString(args[0].AsString())
+		if err != nil {
+			return cty.UnknownVal(cty.String), function.NewArgErrorf(0, "failed to decode input %q: cipher text must be base64-encoded", args[0].AsString())
+		}
+
+		block, _ := pem.Decode([]byte(args[1].AsString()))
+		if block == nil {
+			return cty.UnknownVal(cty.String), function.NewArgErrorf(1, "invalid private key: no key found")
+		}
+
+		var privateKey *rsa.PrivateKey
+		if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+			privateKey = key
+		} else if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+			rsaKey, ok := key.(*rsa.PrivateKey)
+			if !ok {
+				return cty.UnknownVal(cty.String), function.NewArgErrorf(1, "invalid private key: not an RSA key")
+			}
+			privateKey = rsaKey
+		} else {
+			return cty.UnknownVal(cty.String), function.NewArgErrorf(1, "invalid private key: %s", err)
+		}
+
+		out, err := rsa.DecryptPKCS1v15(nil, privateKey, b)
+		if err != nil {
+			return cty.UnknownVal(cty.String), fmt.Errorf("failed to decrypt: %s", err)
+		}
+
+		return cty.StringVal(string(out)), nil
+	},
+})
@@ -0,0 +1,461 @@
+package terraform
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// Function definitions were taken from https://github.com/hashicorp/terraform/blob/v1.5.0/internal/lang/funcs/filesystem.go
+// Unlike Terraform, the functions only read from the filesystem of the module, never from the disk of the host:
+// the paths are relative to the root of the filesystem and cannot leave it, and symbolic links are not followed
+
+// maxFileFunctionSize is the size of the largest file the filesystem functions read
+var maxFileFunctionSize int64 = 4 * 1024 * 1024
+
+// maxFileFunctionTotalSize is the total size of the files the filesystem functions of a parser read across all their calls
+var maxFileFunctionTotalSize int64 = 16 * 1024 * 1024
+
+// maxFileSetMatches is the largest number of files a call to fileset returns
+var maxFileSetMatches = 10000
+
+// maxBraceExpansions is the largest number of patterns the alternatives of a fileset pattern expand into
+var maxBraceExpansions = 1000
+
+// moduleFunctions returns the functions available to the expressions of a module,
+// i.e. the terraform functions alongside the filesystem functions when the module has a filesystem
+func moduleFunctions(options Options) map[string]function.Function {
+	if options.FS == nil {
+		return terraformFunctions
+	}
+
+	functions := make(map[string]function.Function, len(terraformFunctions)+5)
+	for name, fn := range terraformFunctions {
+		functions[name] = fn
+	}
+	// the functions which read files share the same budget, so that many small reads cannot add up to a large one
+	budget := &fileReadBudget{remaining: maxFileFunctionTotalSize}
+	functions["file"] = makeFileFunc(options.FS, budget, false)
+	functions["filebase64"] = makeFileFunc(options.FS, budget, true)
+	functions["fileexists"] = makeFileExistsFunc(options.FS)
+	functions["fileset"] = makeFileSetFunc(options.FS)
+	functions["templatefile"] = makeTemplateFileFunc(options.FS, budget, func() map[string]function.Function {
+		return functions
+	})
+	return functions
+}
+
+// pathVariables returns the values of path.module, path.root and path.cwd for the module in the provided directory
+// The paths are relative to the root of the filesystem, which is also the root module and the working directory
+func pathVariables(moduleDir string, options Options) ValueMap {
+	if options.FS == nil {
+		return nil
+	}
+	return ValueMap{
+		"module": cty.StringVal(path.Clean(moduleDir)),
+		"root":   cty.StringVal("."),
+		"cwd":    cty.StringVal("."),
+	}
+}
+
+func makeFileFunc(fsys fs.FS, budget *fileReadBudget, encodeBase64 bool) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "path",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			filePath := args[0].AsString()
+			src, err := readFileFromFS(fsys, budget, filePath)
+			if err != nil {
+				return cty.UnknownVal(cty.String), err
+			}
+
+			if encodeBase64 {
+				return cty.StringVal(base64.StdEncoding.EncodeToString(src)), nil
+			}
+			if !utf8.Valid(src) {
+				return cty.UnknownVal(cty.String), fmt.Errorf("contents of %s are not valid UTF-8; use the filebase64 function to obtain the Base64 encoded contents", filePath)
+			}
+			return cty.StringVal(string(src)), nil
+		},
+	})
+}
+
+func makeFileExistsFunc(fsys fs.FS) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "path",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.Bool),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			filePath, err := resolveFilePath(fsys, args[0].AsString())
+			if err != nil {
+				return cty.UnknownVal(cty.Bool), err
+			}
+
+			info, err := fs.Stat(fsys, filePath)
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return cty.False, nil
+				}
+				return cty.UnknownVal(cty.Bool), fmt.Errorf("failed to stat %s", args[0].AsString())
+			}
+			if !info.Mode().IsRegular() {
+				return cty.UnknownVal(cty.Bool), fmt.Errorf("%s is not a regular file, but %q", args[0].AsString(), info.Mode().String())
+			}
+			return cty.True, nil
+		},
+	})
+}
+
+func makeFileSetFunc(fsys fs.FS) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "path",
+				Type: cty.String,
+			},
+			{
+				Name: "pattern",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.Set(cty.String)),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			dir, err := resolveFilePath(fsys, args[0].AsString())
+			if err != nil {
+				return cty.UnknownVal(retType), err
+			}
+			patterns, err := expandBraces(path.Clean(args[1].AsString()))
+			if err != nil {
+				return cty.UnknownVal(retType), fmt.Errorf("failed to glob pattern %s: %s", args[1].AsString(), err)
+			}
+			for _, pattern := range patterns {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return cty.UnknownVal(retType), fmt.Errorf("failed to glob pattern %s: %s", args[1].AsString(), err)
+				}
+			}
+
+			var matches []cty.Value
+			err = fs.WalkDir(fsys, dir, func(filePath string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					if filePath == dir {
+						return err
+					}
+					// like Terraform, the entries which cannot be read are skipped
+					return nil
+				}
+				// only regular files are returned, which also means that symbolic links are not followed
+				if !entry.Type().IsRegular() {
+					return nil
+				}
+
+				relativePath := filePath
+				if dir != "." {
+					relativePath = strings.TrimPrefix(filePath, dir+"/")
+				}
+				for _, pattern := range patterns {
+					if matchGlob(strings.Split(pattern, "/"), strings.Split(relativePath, "/")) {
+						if len(matches) == maxFileSetMatches {
+							return fmt.Errorf("fileset matches more than %d files", maxFileSetMatches)
+						}
+						matches = append(matches, cty.StringVal(relativePath))
+						break
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return cty.SetValEmpty(cty.String), nil
+				}
+				return cty.UnknownVal(retType), fmt.Errorf("failed to glob pattern %s: %s", args[1].AsString(), err)
+			}
+
+			if len(matches) == 0 {
+				return cty.SetValEmpty(cty.String), nil
+			}
+			return cty.SetVal(matches), nil
+		},
+	})
+}
+
+func makeTemplateFileFunc(fsys fs.FS, budget *fileReadBudget, funcsCb func() map[string]function.Function) function.Function {
+	params := []function.Parameter{
+		{
+			Name: "path",
+			Type: cty.String,
+		},
+		{
+			Name: "vars",
+			Type: cty.DynamicPseudoType,
+		},
+	}
+
+	loadTemplate := func(filePath string) (hcl.Expression, error) {
+		src, err := readFileFromFS(fsys, budget, filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		expr, diags := hclsyntax.ParseTemplate(src, filePath, hcl.Pos{Line: 1, Column: 1})
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		return expr, nil
+	}
+
+	renderTemplate := func(expr hcl.Expression, varsVal cty.Value) (cty.Value, error) {
+		if varsTy := varsVal.Type(); !(varsTy.IsMapType() || varsTy.IsObjectType()) {
+			return cty.DynamicVal, function.NewArgErrorf(1, "invalid vars value: must be a map")
+		}
+
+		ctx := &hcl.EvalContext{
+			Variables: varsVal.AsValueMap(),
+		}
+
+		// the template can only reference the variables it is given
+		for _, traversal := range expr.Variables() {
+			root := traversal.RootName()
+			if _, ok := ctx.Variables[root]; !ok {
+				return cty.DynamicVal, function.NewArgErrorf(1, "vars map does not contain key %q, referenced at %s", root, traversal[0].SourceRange())
+			}
+		}
+
+		// a template cannot call templatefile itself, so that it cannot recurse forever
+		givenFuncs := funcsCb()
+		funcs := make(map[string]function.Function, len(givenFuncs))
+		for name, fn := range givenFuncs {
+			if name == "templatefile" {
+				funcs[name] = function.New(&function.Spec{
+					Params: params,
+					Type: func(args []cty.Value) (cty.Type, error) {
+						return cty.NilType, fmt.Errorf("cannot recursively call templatefile from inside templatefile call")
+					},
+				})
+				continue
+			}
+			funcs[name] = fn
+		}
+		ctx.Functions = funcs
+
+		value, diags := expr.Value(ctx)
+		if diags.HasErrors() {
+			return cty.DynamicVal, diags
+		}
+		return value, nil
+	}
+
+	return function.New(&function.Spec{
+		Params: params,
+		Type: func(args []cty.Value) (cty.Type, error) {
+			if !(args[0].IsKnown() && args[1].IsKnown()) {
+				return cty.DynamicPseudoType, nil
+			}
+
+			// the template has to be rendered to know the type of its result, e.g. a single interpolation of a list
+			expr, err := loadTemplate(args[0].AsString())
+			if err != nil {
+				return cty.DynamicPseudoType, err
+			}
+			value, err := renderTemplate(expr, args[1])
+			return value.Type(), err
+		},
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			expr, err := loadTemplate(args[0].AsString())
+			if err != nil {
+				return cty.DynamicVal, err
+			}
+			return renderTemplate(expr, args[1])
+		},
+	})
+}
+
+// fileReadBudget holds the number of bytes the filesystem functions of a parser can still read
+type fileReadBudget struct {
+	remaining int64
+}
+
+// readFileFromFS reads a file of the filesystem, as long as it is not larger than maxFileFunctionSize
+// nor than what is left of the read budget, which the size of the file is then taken from
+func readFileFromFS(fsys fs.FS, budget *fileReadBudget, rawPath string) ([]byte, error) {
+	filePath, err := resolveFilePath(fsys, rawPath)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := fsys.Open(filePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("no file exists at %s", rawPath)
+		}
+		return nil, fmt.Errorf("failed to read %s", rawPath)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s", rawPath)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory, not a file", rawPath)
+	}
+	if info.Size() > maxFileFunctionSize {
+		return nil, fmt.Errorf("%s is larger than the limit of %d bytes", rawPath, maxFileFunctionSize)
+	}
+	if info.Size() > budget.remaining {
+		return nil, fmt.Errorf("reading %s exceeds the limit of %d bytes read by the filesystem functions", rawPath, maxFileFunctionTotalSize)
+	}
+
+	// the size reported by the filesystem is not trusted, so the read is also limited
+	limit := maxFileFunctionSize
+	if budget.remaining < limit {
+		limit = budget.remaining
+	}
+	src, err := io.ReadAll(io.LimitReader(file, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s", rawPath)
+	}
+	if int64(len(src)) > maxFileFunctionSize {
+		return nil, fmt.Errorf("%s is larger than the limit of %d bytes", rawPath, maxFileFunctionSize)
+	}
+	if int64(len(src)) > budget.remaining {
+		return nil, fmt.Errorf("reading %s exceeds the limit of %d bytes read by the filesystem functions", rawPath, maxFileFunctionTotalSize)
+	}
+	budget.remaining -= int64(len(src))
+	return src, nil
+}
+
+// resolveFilePath cleans a path relative to the root of the filesystem, e.g. ./modules/vpc/../policy.json,
+// and makes sure it neither leaves the filesystem nor goes through a symbolic link
+func resolveFilePath(fsys fs.FS, rawPath string) (string, error) {
+	filePath := path.Clean(rawPath)
+	if !fs.ValidPath(filePath) {
+		return "", fmt.Errorf("path %s is outside of the module filesystem", rawPath)
+	}
+	if filePath == "." {
+		return filePath, nil
+	}
+
+	dir := "."
+	for _, name := range strings.Split(filePath, "/") {
+		entries, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			// the path does not exist, which the caller reports
+			break
+		}
+		for _, entry := range entries {
+			if entry.Name() == name && entry.Type()&fs.ModeSymlink != 0 {
+				return "", fmt.Errorf("path %s goes through a symbolic link", rawPath)
+			}
+		}
+		dir = path.Join(dir, name)
+	}
+	return filePath, nil
+}
+
+// matchGlob matches the segments of a path against the segments of a pattern, where ** matches any number of directories
+// The matches of the rest of the pattern against the rest of the path are memoized, as consecutive ** would
+// otherwise match the same segments an exponential number of times
+func matchGlob(pattern []string, segments []string) bool {
+	matches := map[[2]int]bool{}
+	var match func(patternIndex int, segmentIndex int) bool
+	match = func(patternIndex int, segmentIndex int) bool {
+		if patternIndex == len(pattern) {
+			return segmentIndex == len(segments)
+		}
+		key := [2]int{patternIndex, segmentIndex}
+		if matched, ok := matches[key]; ok {
+			return matched
+		}
+
+		matched := false
+		if pattern[patternIndex] == "**" {
+			for i := segmentIndex; i <= len(segments) && !matched; i++ {
+				matched = match(patternIndex+1, i)
+			}
+		} else if segmentIndex < len(segments) {
+			segmentMatched, err := path.Match(pattern[patternIndex], segments[segmentIndex])
+			matched = err == nil && segmentMatched && match(patternIndex+1, segmentIndex+1)
+		}
+		matches[key] = matched
+		return matched
+	}
+	return match(0, 0)
+}
+
+// expandBraces expands the alternatives of a pattern, e.g. *.{tf,tfvars} into *.tf and *.tfvars
+// It fails when the pattern expands into more than maxBraceExpansions patterns, as each group of alternatives
+// multiplies the number of patterns
+func expandBraces(pattern string) ([]string, error) {
+	patterns := []string{}
+	if err := appendBraceExpansions(&patterns, pattern); err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
+
+func appendBraceExpansions(patterns *[]string, pattern string) error {
+	start := strings.Index(pattern, "{")
+	if start == -1 {
+		return appendBraceExpansion(patterns, pattern)
+	}
+
+	depth := 0
+	end := -1
+	alternatives := []string{}
+	alternativeStart := start + 1
+	for i := start; i < len(pattern) && end == -1; i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				alternatives = append(alternatives, pattern[alternativeStart:i])
+				end = i
+			}
+		case ',':
+			if depth == 1 {
+				alternatives = append(alternatives, pattern[alternativeStart:i])
+				alternativeStart = i + 1
+			}
+		}
+	}
+	// an unclosed brace is matched literally
+	if end == -1 {
+		return appendBraceExpansion(patterns, pattern)
+	}
+
+	for _, alternative := range alternatives {
+		if err := appendBraceExpansions(patterns, pattern[:start]+alternative+pattern[end+1:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendBraceExpansion(patterns *[]string, pattern string) error {
+	if len(*patterns) == maxBraceExpansions {
+		return fmt.Errorf("pattern expands into more than %d patterns", maxBraceExpansions)
+	}
+	*patterns = append(*patterns, pattern)
+	return nil
+}
@@ -0,0 +1,248 @@
+package terraform
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+var testModuleFS = fstest.MapFS{
+	"main.tf":                         {Data: []byte(`locals {}`)},
+	"policy.json":                     {Data: []byte(`{"Version": "2012-10-17"}`)},
+	"binary.dat":                      {Data: []byte{0xff, 0xfe}},
+	"user_data.tpl":                   {Data: []byte(`#!/bin/bash\necho ${name}%{ for port in ports } ${port}%{ endfor }`)},
+	"list.tpl":                        {Data: []byte(`${items}`)},
+	"recursive.tpl":                   {Data: []byte(`${templatefile("list.tpl", { items = [] })}`)},
+	"templates/a.tpl":                 {Data: []byte(`a`)},
+	"templates/nested/b.tpl":          {Data: []byte(`b`)},
+	"templates/nested/c.json":         {Data: []byte(`{}`)},
+	"link.json":                       {Data: []byte(`/etc/passwd`), Mode: fs.ModeSymlink},
+	"modules/vpc/main.tf":             {Data: []byte(`locals {}`)},
+	"modules/vpc/templates/vpc.json":  {Data: []byte(`{"cidr": "${cidr}"}`)},
+	"modules/vpc/templates/empty.txt": {Data: []byte(``)},
+}
+
+func TestFileFunctions(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		{`file("policy.json")`, `"{\"Version\": \"2012-10-17\"}"`},
+		{`file("./modules/../policy.json")`, `"{\"Version\": \"2012-10-17\"}"`},
+		{`file("modules/vpc/templates/empty.txt")`, `""`},
+		{`filebase64("binary.dat")`, `"//4="`},
+		{`fileexists("policy.json")`, `true`},
+		{`fileexists("missing.json")`, `false`},
+		{`fileset(".", "templates/*.tpl")`, `["templates/a.tpl"]`},
+		{`fileset("templates", "**/*.tpl")`, `["a.tpl","nested/b.tpl"]`},
+		{`fileset("templates", "**/*.{tpl,json}")`, `["a.tpl","nested/b.tpl","nested/c.json"]`},
+		{`fileset("templates", "*.json")`, `[]`},
+		{`fileset("missing", "*")`, `[]`},
+		{`templatefile("user_data.tpl", { name = "web", ports = [80, 443] })`, `"#!/bin/bash\\necho web 80 443"`},
+		{`templatefile("list.tpl", { items = ["a", "b"] })`, `["a","b"]`},
+		{`templatefile("modules/vpc/templates/vpc.json", { cidr = "10.0.0.0/16" })`, `"{\"cidr\": \"10.0.0.0/16\"}"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.expr, func(t *testing.T) {
+			value, hclDiags := evaluateFileFunctionCall(t, tc.expr)
+			require.False(t, hclDiags.HasErrors(), hclDiags.Error())
+
+			jsonBytes, err := ctyjson.SimpleJSONValue{Value: value}.MarshalJSON()
+			require.Nil(t, err)
+			assert.Equal(t, tc.expected, string(jsonBytes))
+		})
+	}
+}
+
+func TestFileFunctionsFailure(t *testing.T) {
+	tests := []struct {
+		expr          string
+		expectedError string
+	}{
+		{`file("../secret.txt")`, "path ../secret.txt is outside of the module filesystem"},
+		{`file("modules/../../secret.txt")`, "path modules/../../secret.txt is outside of the module filesystem"},
+		{`file("/etc/passwd")`, "path /etc/passwd is outside of the module filesystem"},
+		{`fileexists("../main.tf")`, "path ../main.tf is outside of the module filesystem"},
+		{`fileset("..", "*")`, "path .. is outside of the module filesystem"},
+		{`fileset(".", "{a,b}{a,b}{a,b}{a,b}{a,b}{a,b}{a,b}{a,b}{a,b}{a,b}.tf")`, "pattern expands into more than 1000 patterns"},
+		{`templatefile("../user_data.tpl", {})`, "path ../user_data.tpl is outside of the module filesystem"},
+		{`file("link.json")`, "path link.json goes through a symbolic link"},
+		{`file("missing.json")`, "no file exists at missing.json"},
+		{`file("templates")`, "templates is a directory, not a file"},
+		{`fileexists("templates")`, "templates is not a regular file"},
+		{`file("binary.dat")`, "contents of binary.dat are not valid UTF-8"},
+		{`templatefile("user_data.tpl", { name = "web" })`, `vars map does not contain key "ports"`},
+		{`templatefile("recursive.tpl", {})`, "cannot recursively call templatefile from inside templatefile call"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.expr, func(t *testing.T) {
+			_, hclDiags := evaluateFileFunctionCall(t, tc.expr)
+			require.True(t, hclDiags.HasErrors())
+			assert.Contains(t, hclDiags.Error(), tc.expectedError)
+		})
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	assert.True(t, matchGlob(strings.Split("**/*.tpl", "/"), strings.Split("a.tpl", "/")))
+	assert.True(t, matchGlob(strings.Split("**/nested/**/*.tpl", "/"), strings.Split("a/nested/b/c/d.tpl", "/")))
+	assert.False(t, matchGlob(strings.Split("**/nested/*.tpl", "/"), strings.Split("a/nested/b/d.tpl", "/")))
+
+	// consecutive ** match the segments of a deep path without backtracking exponentially
+	pattern := strings.Split(strings.Repeat("**/", 20)+"missing", "/")
+	segments := strings.Split(strings.Repeat("a/", 40)+"b", "/")
+	assert.False(t, matchGlob(pattern, segments))
+}
+
+func TestFileFunctionsSizeLimit(t *testing.T) {
+	defaultMaxFileFunctionSize := maxFileFunctionSize
+	maxFileFunctionSize = 8
+	defer func() {
+		maxFileFunctionSize = defaultMaxFileFunctionSize
+	}()
+
+	_, hclDiags := evaluateFileFunctionCall(t, `file("policy.json")`)
+	require.True(t, hclDiags.HasErrors())
+	assert.Contains(t, hclDiags.Error(), "policy.json is larger than the limit of 8 bytes")
+
+	value, hclDiags := evaluateFileFunctionCall(t, `file("templates/a.tpl")`)
+	require.False(t, hclDiags.HasErrors(), hclDiags.Error())
+	assert.Equal(t, "a", value.AsString())
+}
+
+func TestFileFunctionsTotalSizeLimit(t *testing.T) {
+	defaultMaxFileFunctionTotalSize := maxFileFunctionTotalSize
+	maxFileFunctionTotalSize = 30
+	defer func() {
+		maxFileFunctionTotalSize = defaultMaxFileFunctionTotalSize
+	}()
+
+	options := DefaultOptions()
+	options.FS = testModuleFS
+	ctx := &hcl.EvalContext{Functions: moduleFunctions(options)}
+	evaluate := func(exprString string) (cty.Value, hcl.Diagnostics) {
+		expr, hclDiags := hclsyntax.ParseExpression([]byte(exprString), "test.tf", hcl.Pos{Line: 1, Column: 1})
+		require.False(t, hclDiags.HasErrors(), hclDiags.Error())
+		return expr.Value(ctx)
+	}
+
+	// the 25 bytes of policy.json and the byte of a.tpl fit in the budget, which the template then exceeds
+	_, hclDiags := evaluate(`file("policy.json")`)
+	require.False(t, hclDiags.HasErrors(), hclDiags.Error())
+	_, hclDiags = evaluate(`filebase64("templates/a.tpl")`)
+	require.False(t, hclDiags.HasErrors(), hclDiags.Error())
+	_, hclDiags = evaluate(`templatefile("modules/vpc/templates/vpc.json", { cidr = "10.0.0.0/16" })`)
+	require.True(t, hclDiags.HasErrors())
+	assert.Contains(t, hclDiags.Error(), "reading modules/vpc/templates/vpc.json exceeds the limit of 30 bytes read by the filesystem functions")
+
+	// the functions of another parser have their own budget
+	value, hclDiags := evaluateFileFunctionCall(t, `file("policy.json")`)
+	require.False(t, hclDiags.HasErrors(), hclDiags.Error())
+	assert.Equal(t, `{"Version": "2012-10-17"}`, value.AsString())
+}
+
+func TestFileFunctionsWithoutFS(t *testing.T) {
+	functions := moduleFunctions(DefaultOptions())
+	for _, name := range []string{"file", "filebase64", "fileexists", "fileset", "templatefile"} {
+		assert.NotContains(t, functions, name)
+	}
+}
+
+func TestParseModuleTreeWithFileFunctions(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.tf": {Data: []byte(`
+module "vpc" {
+	source = "./modules/vpc"
+}
+
+resource "aws_iam_policy" "this" {
+	policy = file("${path.module}/policy.json")
+	paths  = [path.root, path.cwd]
+}`)},
+		"policy.json": {Data: []byte(`{}`)},
+		"modules/vpc/main.tf": {Data: []byte(`
+locals {
+	config = templatefile("${path.module}/templates/vpc.json", { cidr = "10.0.0.0/16" })
+}
+
+resource "aws_vpc" "this" {
+	config = local.config
+	exists = fileexists("${path.module}/main.tf")
+	files  = fileset(path.module, "templates/*")
+	module = path.module
+}`)},
+		"modules/vpc/templates/vpc.json": {Data: []byte(`{"cidr": "${cidr}"}`)},
+	}
+
+	actual, err := ParseModuleTree(fsys, DefaultOptions())
+	require.Nil(t, err)
+
+	assert.Equal(t, JSON{
+		"module": map[string]interface{}{
+			"vpc": map[string]interface{}{
+				"source": "./modules/vpc",
+			},
+		},
+		"resource": map[string]interface{}{
+			"aws_iam_policy": map[string]interface{}{
+				"this": map[string]interface{}{
+					"policy": "{}",
+					"paths":  []interface{}{".", "."},
+				},
+			},
+		},
+	}, actual.Modules[""].ParsedFiles["main.tf"])
+	assert.Equal(t, JSON{
+		"locals": map[string]interface{}{
+			"config": `{"cidr": "10.0.0.0/16"}`,
+		},
+		"resource": map[string]interface{}{
+			"aws_vpc": map[string]interface{}{
+				"this": map[string]interface{}{
+					"config": `{"cidr": "10.0.0.0/16"}`,
+					"exists": true,
+					"files":  []interface{}{"templates/vpc.json"},
+					"module": "modules/vpc",
+				},
+			},
+		},
+	}, actual.Modules["module.vpc"].ParsedFiles["modules/vpc/main.tf"])
+}
+
+func TestParseModuleFilesWithoutFS(t *testing.T) {
+	actual := ParseModuleFiles(map[string][]byte{
+		"main.tf": []byte(`
+resource "aws_iam_policy" "this" {
+	policy = file("${path.module}/policy.json")
+}`),
+	}, DefaultOptions())
+
+	assert.Equal(t, JSON{
+		"resource": map[string]interface{}{
+			"aws_iam_policy": map[string]interface{}{
+				"this": map[string]interface{}{
+					"policy": "${file(\"${path.module}/policy.json\")}",
+				},
+			},
+		},
+	}, actual.ParsedFiles["main.tf"])
+}
+
+func evaluateFileFunctionCall(t *testing.T, exprString string) (cty.Value, hcl.Diagnostics) {
+	expr, hclDiags := hclsyntax.ParseExpression([]byte(exprString), "test.tf", hcl.Pos{Line: 1, Column: 1})
+	require.False(t, hclDiags.HasErrors(), hclDiags.Error())
+
+	options := DefaultOptions()
+	options.FS = testModuleFS
+	return expr.Value(&hcl.EvalContext{Functions: moduleFunctions(options)})
+}
@@ -0,0 +1,75 @@
+package terraform
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// Function definitions were taken from https://github.com/hashicorp/terraform/blob/v1.5.0/internal/lang/funcs/string.go
+
+// replaceFunc searches a given string for another given substring, and replaces all occurrences with a given replacement string
+// The substring is a regular expression when it is wrapped in forward slashes, e.g. /w.*d/
+var replaceFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name: "str",
+			Type: cty.String,
+		},
+		{
+			Name: "substr",
+			Type: cty.String,
+		},
+		{
+			Name: "replace",
+			Type: cty.String,
+		},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		str := args[0].AsString()
+		substr := args[1].AsString()
+		replace := args[2].AsString()
+
+		if len(substr) > 1 && substr[0] == '/' && substr[len(substr)-1] == '/' {
+			re, err := regexp.Compile(substr[1 : len(substr)-1])
+			if err != nil {
+				return cty.UnknownVal(cty.String), err
+			}
+
+			return cty.StringVal(re.ReplaceAllString(str, replace)), nil
+		}
+
+		return cty.StringVal(strings.Replace(str, substr, replace, -1)), nil
+	},
+})
+
+// startswithFunc checks whether a string starts with the given prefix
+var startswithFunc = newStringPredicateFunc("prefix", strings.HasPrefix)
+
+// endswithFunc checks whether a string ends with the given suffix
+var endswithFunc = newStringPredicateFunc("suffix", strings.HasSuffix)
+
+// strcontainsFunc checks whether a string contains the given substring
+var strcontainsFunc = newStringPredicateFunc("substr", strings.Contains)
+
+func newStringPredicateFunc(paramName string, predicate func(string, string) bool) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "str",
+				Type: cty.String,
+			},
+			{
+				Name: paramName,
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.Bool),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			return cty.BoolVal(predicate(args[0].AsString(), args[1].AsString())), nil
+		},
+	})
+}
@@ -0,0 +1,251 @@
+package terraform
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/stretchr/testify/assert"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// the examples are taken from the documentation of each function at https://www.terraform.io/language/functions
+func TestTerraformFunctions(t *testing.T) {
+	testCases := []struct {
+		expr     string
+		expected string
+	}{
+		// numeric
+		{`abs(23)`, `23`},
+		{`abs(0)`, `0`},
+		{`abs(-12.4)`, `12.4`},
+		{`ceil(5)`, `5`},
+		{`ceil(5.1)`, `6`},
+		{`floor(5)`, `5`},
+		{`floor(4.9)`, `4`},
+		{`log(16, 2)`, `4`},
+		{`max(12, 54, 3)`, `54`},
+		{`max([12, 54, 3]...)`, `54`},
+		{`min(12, 54, 3)`, `3`},
+		{`min([12, 54, 3]...)`, `3`},
+		{`parseint("100", 10)`, `100`},
+		{`parseint("FF", 16)`, `255`},
+		{`parseint("-10", 16)`, `-16`},
+		{`parseint("1011111011101111", 2)`, `48879`},
+		{`parseint("aA", 62)`, `656`},
+		{`pow(3, 2)`, `9`},
+		{`pow(4, 0)`, `1`},
+		{`signum(-13)`, `-1`},
+		{`signum(0)`, `0`},
+		{`signum(344)`, `1`},
+
+		// string
+		{`chomp("hello\n")`, `"hello"`},
+		{`chomp("hello\r\n")`, `"hello"`},
+		{`chomp("hello\n\n")`, `"hello"`},
+		{`endswith("hello world", "world")`, `true`},
+		{`format("Hello, %s!", "Ander")`, `"Hello, Ander!"`},
+		{`format("There are %d lights", 4)`, `"There are 4 lights"`},
+		{`format("%-5s", "hi")`, `"hi   "`},
+		{`format("%.2f", 3.14159)`, `"3.14"`},
+		{`format("%#v", "hello")`, `"\"hello\""`},
+		{`format("%q", "hello")`, `"\"hello\""`},
+		{`formatlist("Hello, %s!", ["Valentina", "Ander", "Olivia", "Sam"])`, `["Hello, Valentina!","Hello, Ander!","Hello, Olivia!","Hello, Sam!"]`},
+		{`formatlist("%s, %s!", "Salutations", ["Valentina", "Ander", "Olivia", "Sam"])`, `["Salutations, Valentina!","Salutations, Ander!","Salutations, Olivia!","Salutations, Sam!"]`},
+		{`"items: ${indent(2, "[\n  foo,\n  bar,\n]\n")}"`, `"items: [\n    foo,\n    bar,\n  ]\n  "`},
+		{`join("-", ["foo", "bar", "baz"])`, `"foo-bar-baz"`},
+		{`join(", ", ["foo", "bar", "baz"])`, `"foo, bar, baz"`},
+		{`join(", ", ["foo"])`, `"foo"`},
+		{`lower("HELLO")`, `"hello"`},
+		{`regex("[a-z]+", "53453453.345345aaabbbccc23454")`, `"aaabbbccc"`},
+		{`regex("(\\d\\d\\d\\d)-(\\d\\d)-(\\d\\d)", "2019-02-01")`, `["2019","02","01"]`},
+		{`regexall("[a-z]+", "1234abcd5678efgh9")`, `["abcd","efgh"]`},
+		{`replace("1 + 2 + 3", "+", "-")`, `"1 - 2 - 3"`},
+		{`replace("hello world", "/w.*d/", "everybody")`, `"hello everybody"`},
+		{`split(",", "foo,bar,baz")`, `["foo","bar","baz"]`},
+		{`split(",", "foo")`, `["foo"]`},
+		{`split(",", "")`, `[""]`},
+		{`startswith("hello world", "hello")`, `true`},
+		{`startswith("hello world", "world")`, `false`},
+		{`strcontains("hello world", "wor")`, `true`},
+		{`strrev("hello")`, `"olleh"`},
+		{`strrev("a ☃")`, `"☃ a"`},
+		{`substr("hello world", 1, 4)`, `"ello"`},
+		{`title("hello world")`, `"Hello World"`},
+		{`trim("?!hello?!", "!?")`, `"hello"`},
+		{`trim("foobar", "far")`, `"oob"`},
+		{`trim("   hello! world.!  ", "! ")`, `"hello! world."`},
+		{`trimprefix("helloworld", "hello")`, `"world"`},
+		{`trimprefix("helloworld", "cat")`, `"helloworld"`},
+		{`trimsuffix("helloworld", "world")`, `"hello"`},
+		{`trimspace("  hello\n\n")`, `"hello"`},
+		{`upper("hello")`, `"HELLO"`},
+
+		// collections
+		{`alltrue(["true", true])`, `true`},
+		{`alltrue([true, false])`, `false`},
+		{`anytrue(["true"])`, `true`},
+		{`anytrue([])`, `false`},
+		{`chunklist(["a", "b", "c", "d", "e"], 2)`, `[["a","b"],["c","d"],["e"]]`},
+		{`chunklist(["a", "b", "c", "d", "e"], 1)`, `[["a"],["b"],["c"],["d"],["e"]]`},
+		{`coalesce("a", "b")`, `"a"`},
+		{`coalesce("", "b")`, `"b"`},
+		{`coalesce(1, 2)`, `1`},
+		{`coalescelist(["a", "b"], ["c", "d"])`, `["a","b"]`},
+		{`coalescelist([], ["c", "d"])`, `["c","d"]`},
+		{`compact(["a", "", "b", "c"])`, `["a","b","c"]`},
+		{`concat(["a", ""], ["b", "c"])`, `["a","","b","c"]`},
+		{`contains(["a", "b", "c"], "a")`, `true`},
+		{`contains(["a", "b", "c"], "d")`, `false`},
+		{`distinct(["a", "b", "a", "c", "d", "b"])`, `["a","b","c","d"]`},
+		{`element(["a", "b", "c"], 1)`, `"b"`},
+		{`element(["a", "b", "c"], 3)`, `"a"`},
+		{`flatten([["a", "b"], [], ["c"]])`, `["a","b","c"]`},
+		{`flatten([[["a", "b"], []], ["c"]])`, `["a","b","c"]`},
+		{`index(["a", "b", "c"], "b")`, `1`},
+		{`keys({a=1, c=2, d=3})`, `["a","c","d"]`},
+		{`length([])`, `0`},
+		{`length(["a", "b"])`, `2`},
+		{`length({"a" = "b"})`, `1`},
+		{`length("hello")`, `5`},
+		{`length("👾🕹️")`, `2`},
+		{`lookup({a="ay", b="bee"}, "a", "what?")`, `"ay"`},
+		{`lookup({a="ay", b="bee"}, "c", "what?")`, `"what?"`},
+		{`matchkeys(["i-123", "i-abc", "i-def"], ["us-west", "us-east", "us-east"], ["us-east"])`, `["i-abc","i-def"]`},
+		{`merge({a="b", c="d"}, {e="f", c="z"})`, `{"a":"b","c":"z","e":"f"}`},
+		{`merge({a="b"}, {a=[1,2], c="z"}, {d=3})`, `{"a":[1,2],"c":"z","d":3}`},
+		{`one([])`, `null`},
+		{`one(["hello"])`, `"hello"`},
+		{`range(3)`, `[0,1,2]`},
+		{`range(1, 4)`, `[1,2,3]`},
+		{`range(1, 8, 2)`, `[1,3,5,7]`},
+		{`reverse([1, 2, 3])`, `[3,2,1]`},
+		{`setintersection(["a", "b"], ["b", "c"], ["b", "d"])`, `["b"]`},
+		{`length(setproduct(["development", "staging", "production"], ["app1", "app2"]))`, `6`},
+		{`setsubtract(["a", "b", "c"], ["a", "c"])`, `["b"]`},
+		{`setunion(["a", "b"], ["b", "c"], ["d"])`, `["a","b","c","d"]`},
+		{`slice(["a", "b", "c", "d"], 1, 3)`, `["b","c"]`},
+		{`sort(["e", "d", "a", "x"])`, `["a","d","e","x"]`},
+		{`sum([10, 13, 6, 4.5])`, `33.5`},
+		{`transpose({"a" = ["1", "2"], "b" = ["2", "3"]})`, `{"1":["a"],"2":["a","b"],"3":["b"]}`},
+		{`values({a=3, c=2, d=1})`, `[3,2,1]`},
+		{`zipmap(["a", "b"], [1, 2])`, `{"a":1,"b":2}`},
+
+		// encoding
+		{`base64decode("SGVsbG8gV29ybGQ=")`, `"Hello World"`},
+		{`base64encode("Hello World")`, `"SGVsbG8gV29ybGQ="`},
+		{`csvdecode("a,b,c\n1,2,3\n4,5,6")`, `[{"a":"1","b":"2","c":"3"},{"a":"4","b":"5","c":"6"}]`},
+		{`jsondecode("{\"hello\": \"world\"}")`, `{"hello":"world"}`},
+		{`jsondecode("true")`, `true`},
+		{`jsonencode({"hello"="world"})`, `"{\"hello\":\"world\"}"`},
+		{`textencodebase64("Hello World", "UTF-16LE")`, `"SABlAGwAbABvACAAVwBvAHIAbABkAA=="`},
+		{`textdecodebase64("SABlAGwAbABvACAAVwBvAHIAbABkAA==", "UTF-16LE")`, `"Hello World"`},
+		{`urlencode("Hello World!")`, `"Hello+World%21"`},
+		{`urlencode("☃")`, `"%E2%98%83"`},
+		{`urlencode("foo:bar@localhost?foo=bar&bar=baz")`, `"foo%3Abar%40localhost%3Ffoo%3Dbar%26bar%3Dbaz"`},
+		{`yamldecode("hello: world")`, `{"hello":"world"}`},
+		{`yamlencode({"a":"b", "c":"d"})`, `"\"a\": \"b\"\n\"c\": \"d\"\n"`},
+
+		// time
+		{`formatdate("DD MMM YYYY hh:mm ZZZ", "2018-01-02T23:12:01Z")`, `"02 Jan 2018 23:12 UTC"`},
+		{`formatdate("EEEE, DD-MMM-YY hh:mm:ss ZZZ", "2018-01-02T23:12:01Z")`, `"Tuesday, 02-Jan-18 23:12:01 UTC"`},
+		{`formatdate("EEE, DD MMM YYYY hh:mm:ss ZZZ", "2018-01-02T23:12:01-08:00")`, `"Tue, 02 Jan 2018 23:12:01 -0800"`},
+		{`formatdate("MMM DD, YYYY", "2018-01-02T23:12:01Z")`, `"Jan 02, 2018"`},
+		{`formatdate("HH:mmaa", "2018-01-02T23:12:01Z")`, `"11:12pm"`},
+		{`formatdate("h:mm", "2018-01-02T23:12:01Z")`, `"23:12"`},
+		{`timeadd("2017-11-22T00:00:00Z", "10m")`, `"2017-11-22T00:10:00Z"`},
+		{`timecmp("2017-11-22T00:00:00Z", "2017-11-22T00:00:00Z")`, `0`},
+		{`timecmp("2017-11-22T00:00:00Z", "2017-11-22T01:00:00Z")`, `-1`},
+		{`timecmp("2017-11-22T01:00:00-01:00", "2017-11-22T01:00:00Z")`, `1`},
+
+		// hash and crypto
+		{`base64sha256("hello world")`, `"uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek="`},
+		{`base64sha512("hello world")`, `"MJ7MSJwS1utMxA9QyQLytNDtd+5RGnx6m808qG1M2G+YndNbxf9JlnDaNCVbRbDP2DDoH2Bdz33FVC6TrpzXbw=="`},
+		{`md5("hello world")`, `"5eb63bbbe01eeed093cb22bb8f5acdc3"`},
+		{`sha1("hello world")`, `"2aae6c35c94fcfb415dbe95f408b9ce91ee846ed"`},
+		{`sha256("hello world")`, `"b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"`},
+		{`sha512("hello world")`, `"309ecc489c12d6eb4cc40f50c902f2b4d0ed77ee511a7c7a9bcd3ca86d4cd86f989dd35bc5ff499670da34255b45b0cfd830e81f605dcf7dc5542e93ae9cd76f"`},
+		{`uuidv5("dns", "www.terraform.io")`, `"a5008fae-b28c-5ba5-96cd-82b4c53552d6"`},
+		{`uuidv5("url", "https://www.terraform.io/")`, `"9db6f67c-dd95-5ea0-aa5b-e70e5c5f7cf5"`},
+		{`uuidv5("6ba7b810-9dad-11d1-80b4-00c04fd430c8", "www.terraform.io")`, `"a5008fae-b28c-5ba5-96cd-82b4c53552d6"`},
+
+		// ip network
+		{`cidrhost("10.12.112.0/20", 16)`, `"10.12.112.16"`},
+		{`cidrhost("10.12.112.0/20", 268)`, `"10.12.113.12"`},
+		{`cidrhost("fd00:fd12:3456:7890:00a2::/72", 34)`, `"fd00:fd12:3456:7890::22"`},
+		{`cidrnetmask("172.16.0.0/12")`, `"255.240.0.0"`},
+		{`cidrsubnet("172.16.0.0/12", 4, 2)`, `"172.18.0.0/16"`},
+		{`cidrsubnet("10.1.2.0/24", 4, 15)`, `"10.1.2.240/28"`},
+		{`cidrsubnet("fd00:fd12:3456:7890::/56", 16, 162)`, `"fd00:fd12:3456:7800:a200::/72"`},
+		{`cidrsubnets("10.1.0.0/16", 4, 4, 8, 4)`, `["10.1.0.0/20","10.1.16.0/20","10.1.32.0/24","10.1.48.0/20"]`},
+		{`cidrsubnets("fd00:fd12:3456:7890::/56", 16, 16, 16, 32)`, `["fd00:fd12:3456:7800::/72","fd00:fd12:3456:7800:100::/72","fd00:fd12:3456:7800:200::/72","fd00:fd12:3456:7800:300::/88"]`},
+
+		// type conversion
+		{`can(regex("^ami-", "ami-123"))`, `true`},
+		{`can(regex("^ami-", "img-123"))`, `false`},
+		{`try(tonumber("hello"), 0)`, `0`},
+		{`nonsensitive(sensitive("secret"))`, `"secret"`},
+		{`tobool("true")`, `true`},
+		{`tolist(["a", "b", 3])`, `["a","b","3"]`},
+		{`tomap({"a" = 1, "b" = 2})`, `{"a":1,"b":2}`},
+		{`tonumber("1")`, `1`},
+		{`toset(["a", "b", "a"])`, `["a","b"]`},
+		{`tostring(1)`, `"1"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			assert.Equal(t, tc.expected, evaluateFunctionCall(t, tc.expr))
+		})
+	}
+}
+
+func TestBase64GzipFunction(t *testing.T) {
+	var encoded string
+	assert.Nil(t, json.Unmarshal([]byte(evaluateFunctionCall(t, `base64gzip("test")`)), &encoded))
+
+	compressed, err := base64.StdEncoding.DecodeString(encoded)
+	assert.Nil(t, err)
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	assert.Nil(t, err)
+	decompressed, err := ioutil.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, "test", string(decompressed))
+}
+
+func TestRsaDecryptFunction(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	ciphertext, err := rsa.EncryptPKCS1v15(rand.Reader, &privateKey.PublicKey, []byte("message"))
+	assert.Nil(t, err)
+	privateKeyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+
+	actual, err := rsaDecryptFunc.Call([]cty.Value{
+		cty.StringVal(base64.StdEncoding.EncodeToString(ciphertext)),
+		cty.StringVal(string(privateKeyPem)),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, cty.StringVal("message"), actual)
+}
+
+func evaluateFunctionCall(t *testing.T, exprString string) string {
+	expr, hclDiags := hclsyntax.ParseExpression([]byte(exprString), "test.tf", hcl.Pos{Line: 1, Column: 1})
+	assert.False(t, hclDiags.HasErrors(), hclDiags.Error())
+
+	value, hclDiags := expr.Value(&hcl.EvalContext{Functions: terraformFunctions})
+	assert.False(t, hclDiags.HasErrors(), hclDiags.Error())
+
+	jsonBytes, err := ctyjson.SimpleJSONValue{Value: value}.MarshalJSON()
+	assert.Nil(t, err)
+	return string(jsonBytes)
+}
@@ -0,0 +1,211 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+type GraphNodeKind string
+
+// The kinds of the nodes of the dependency graph of a module
+const (
+	RESOURCE_NODE GraphNodeKind = "resource"
+	DATA_NODE     GraphNodeKind = "data"
+	VARIABLE_NODE GraphNodeKind = "variable"
+	LOCAL_NODE    GraphNodeKind = "local"
+	OUTPUT_NODE   GraphNodeKind = "output"
+	MODULE_NODE   GraphNodeKind = "module"
+)
+
+// the shapes of the nodes in the DOT export, by kind
+var graphNodeShapes = map[GraphNodeKind]string{
+	RESOURCE_NODE: "box",
+	DATA_NODE:     "box",
+	VARIABLE_NODE: "ellipse",
+	LOCAL_NODE:    "ellipse",
+	OUTPUT_NODE:   "note",
+	MODULE_NODE:   "component",
+}
+
+// ModuleGraph is the dependency graph of a module, in which an edge goes from a block to a block it references
+type ModuleGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphNode is a resource, data source, variable, local value, output or module call of a module
+type GraphNode struct {
+	// ID is the address the block is referenced with, e.g. aws_s3_bucket.logs, data.aws_ami.ubuntu, var.region,
+	// local.tags, module.vpc, or output.bucket for the outputs which cannot be referenced within their module
+	ID   string        `json:"id"`
+	Kind GraphNodeKind `json:"kind"`
+	// Range is where the block, or the attribute of a local value, is declared
+	Range SourceRange `json:"range"`
+}
+
+// GraphEdge is a reference from the node From to the node To, e.g. from aws_s3_bucket_public_access_block.logs to aws_s3_bucket.logs
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// BuildModuleGraph returns the dependency graph of the provided files of a module, keyed by their file names
+// The edges come from the references found in the expressions of each block, and only the references to the blocks
+// of the module are kept, e.g. count.index or each.value are not
+// The files which cannot be parsed are left out of the graph
+func BuildModuleGraph(rawFiles map[string][]byte) *ModuleGraph {
+	files := processFiles(rawFiles, DefaultOptions(), newParseModuleResult())
+
+	fileNames := make([]string, 0, len(files))
+	for fileName := range files {
+		fileNames = append(fileNames, fileName)
+	}
+	sortOverrideFilesLast(fileNames)
+
+	nodes := make(map[string]GraphNode)
+	traversalsByNode := make(map[string][]hcl.Traversal)
+	addNode := func(id string, kind GraphNodeKind, r hcl.Range, traversals []hcl.Traversal) {
+		// the blocks of the override files are merged into the blocks they override
+		if _, ok := nodes[id]; !ok {
+			nodes[id] = GraphNode{ID: id, Kind: kind, Range: newSourceRange(r)}
+		}
+		traversalsByNode[id] = append(traversalsByNode[id], traversals...)
+	}
+
+	for _, fileName := range fileNames {
+		if !isValidTerraformFile(fileName) {
+			continue
+		}
+
+		bodyContent, _, _ := files[fileName].hclFile.Body.PartialContent(tfFileGraphSchema)
+		for _, block := range bodyContent.Blocks {
+			switch block.Type {
+			case "resource":
+				addNode(block.Labels[0]+"."+block.Labels[1], RESOURCE_NODE, block.DefRange, bodyTraversals(block.Body))
+			case "data":
+				addNode("data."+block.Labels[0]+"."+block.Labels[1], DATA_NODE, block.DefRange, bodyTraversals(block.Body))
+			case "variable":
+				addNode("var."+block.Labels[0], VARIABLE_NODE, block.DefRange, nil)
+			case "output":
+				addNode("output."+block.Labels[0], OUTPUT_NODE, block.DefRange, bodyTraversals(block.Body))
+			case "module":
+				addNode("module."+block.Labels[0], MODULE_NODE, block.DefRange, bodyTraversals(block.Body))
+			case "locals":
+				attrs, _ := block.Body.JustAttributes()
+				for name, attr := range attrs {
+					addNode("local."+name, LOCAL_NODE, attr.NameRange, attr.Expr.Variables())
+				}
+			}
+		}
+	}
+
+	graph := &ModuleGraph{
+		Nodes: make([]GraphNode, 0, len(nodes)),
+		Edges: []GraphEdge{},
+	}
+	for _, node := range nodes {
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		return graph.Nodes[i].ID < graph.Nodes[j].ID
+	})
+
+	for _, node := range graph.Nodes {
+		seen := make(map[string]bool)
+		for _, traversal := range traversalsByNode[node.ID] {
+			target, ok := graphNodeID(traversal)
+			if _, exists := nodes[target]; !ok || !exists || target == node.ID || seen[target] {
+				continue
+			}
+			seen[target] = true
+			graph.Edges = append(graph.Edges, GraphEdge{From: node.ID, To: target})
+		}
+	}
+	sort.SliceStable(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].From != graph.Edges[j].From {
+			return graph.Edges[i].From < graph.Edges[j].From
+		}
+		return graph.Edges[i].To < graph.Edges[j].To
+	})
+
+	return graph
+}
+
+// JSON returns the graph as an indented JSON document
+func (graph *ModuleGraph) JSON() (string, error) {
+	jsonBytes, err := json.MarshalIndent(graph, "", "\t")
+	if err != nil {
+		return "", createInternalJSONParsingError([]error{err})
+	}
+	return string(jsonBytes), nil
+}
+
+// DOT returns the graph in the DOT language of Graphviz
+func (graph *ModuleGraph) DOT() string {
+	var builder strings.Builder
+	builder.WriteString("digraph module {\n")
+	for _, node := range graph.Nodes {
+		builder.WriteString(fmt.Sprintf("\t%q [kind=%q, shape=%s];\n", node.ID, node.Kind, graphNodeShapes[node.Kind]))
+	}
+	for _, edge := range graph.Edges {
+		builder.WriteString(fmt.Sprintf("\t%q -> %q;\n", edge.From, edge.To))
+	}
+	builder.WriteString("}\n")
+	return builder.String()
+}
+
+// bodyTraversals returns the traversals of all the expressions of a body, including the ones of its nested blocks
+func bodyTraversals(body hcl.Body) []hcl.Traversal {
+	var traversals []hcl.Traversal
+	if syntaxBody, ok := body.(*hclsyntax.Body); ok {
+		for _, attr := range syntaxBody.Attributes {
+			traversals = append(traversals, attr.Expr.Variables()...)
+		}
+		for _, block := range syntaxBody.Blocks {
+			traversals = append(traversals, bodyTraversals(block.Body)...)
+		}
+		return traversals
+	}
+
+	// the nested blocks of the JSON syntax are attributes holding objects
+	attrs, _ := body.JustAttributes()
+	for _, attr := range attrs {
+		traversals = append(traversals, attr.Expr.Variables()...)
+	}
+	return traversals
+}
+
+// graphNodeID returns the ID of the node a traversal references, e.g. aws_s3_bucket.logs for aws_s3_bucket.logs.id
+func graphNodeID(traversal hcl.Traversal) (string, bool) {
+	// the address is made of the names the traversal starts with, e.g. aws_instance.web for aws_instance.web[0].id
+	var names []string
+	for _, step := range traversal {
+		if root, ok := step.(hcl.TraverseRoot); ok {
+			names = append(names, root.Name)
+		} else if attr, ok := step.(hcl.TraverseAttr); ok {
+			names = append(names, attr.Name)
+		} else {
+			break
+		}
+	}
+
+	switch traversal.RootName() {
+	case "count", "each", "self", "path", "terraform":
+		return "", false
+	case "data":
+		if len(names) < 3 {
+			return "", false
+		}
+		return strings.Join(names[:3], "."), true
+	default:
+		if len(names) < 2 {
+			return "", false
+		}
+		return strings.Join(names[:2], "."), true
+	}
+}
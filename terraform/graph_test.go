@@ -0,0 +1,162 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildModuleGraph(t *testing.T) {
+	graph := BuildModuleGraph(map[string][]byte{
+		"main.tf": []byte(`
+resource "aws_s3_bucket" "logs" {
+	bucket = "${local.prefix}-logs"
+	count  = var.enabled ? 1 : 0
+}
+
+resource "aws_s3_bucket_public_access_block" "logs" {
+	bucket = aws_s3_bucket.logs[0].id
+	dynamic "rule" {
+		for_each = data.aws_iam_policy_document.logs.statement
+		content {
+			value = rule.value
+		}
+	}
+}
+
+data "aws_iam_policy_document" "logs" {
+	statement {
+		resources = [for arn in aws_s3_bucket.logs[*].arn : "${arn}/*"]
+	}
+}
+
+module "vpc" {
+	source = "./modules/vpc"
+	name   = local.prefix
+}
+
+output "bucket" {
+	value = aws_s3_bucket.logs[0].bucket
+}
+
+variable "enabled" {}
+
+locals {
+	prefix = "${var.name}-${terraform.workspace}"
+}`),
+		"variables.tf.json": []byte(`{
+	"variable": {
+		"name": {}
+	},
+	"resource": {
+		"aws_kms_key": {
+			"main": {
+				"description": "${module.vpc.name} key",
+				"policy": {"bucket": "${aws_s3_bucket.logs[0].arn}"}
+			}
+		}
+	}
+}`),
+		"invalid.tf": []byte(`resource "aws_instance" {`),
+	})
+
+	ids := make([]string, 0, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		ids = append(ids, node.ID)
+	}
+	assert.Equal(t, []string{
+		"aws_kms_key.main",
+		"aws_s3_bucket.logs",
+		"aws_s3_bucket_public_access_block.logs",
+		"data.aws_iam_policy_document.logs",
+		"local.prefix",
+		"module.vpc",
+		"output.bucket",
+		"var.enabled",
+		"var.name",
+	}, ids)
+	assert.Equal(t, GraphNode{
+		ID:   "aws_s3_bucket.logs",
+		Kind: RESOURCE_NODE,
+		Range: SourceRange{
+			FileName: "main.tf",
+			Start:    SourcePos{Line: 2, Column: 1},
+			End:      SourcePos{Line: 2, Column: 32},
+		},
+	}, graph.Nodes[1])
+
+	assert.Equal(t, []GraphEdge{
+		{From: "aws_kms_key.main", To: "aws_s3_bucket.logs"},
+		{From: "aws_kms_key.main", To: "module.vpc"},
+		{From: "aws_s3_bucket.logs", To: "local.prefix"},
+		{From: "aws_s3_bucket.logs", To: "var.enabled"},
+		{From: "aws_s3_bucket_public_access_block.logs", To: "aws_s3_bucket.logs"},
+		{From: "aws_s3_bucket_public_access_block.logs", To: "data.aws_iam_policy_document.logs"},
+		{From: "data.aws_iam_policy_document.logs", To: "aws_s3_bucket.logs"},
+		{From: "local.prefix", To: "var.name"},
+		{From: "module.vpc", To: "local.prefix"},
+		{From: "output.bucket", To: "aws_s3_bucket.logs"},
+	}, graph.Edges)
+}
+
+func TestModuleGraphExport(t *testing.T) {
+	graph := BuildModuleGraph(map[string][]byte{
+		"main.tf": []byte(`
+resource "aws_s3_bucket" "logs" {
+	bucket = var.name
+}
+
+variable "name" {}`),
+	})
+
+	actual, err := graph.JSON()
+	require.Nil(t, err)
+	assert.Equal(t, `{
+	"nodes": [
+		{
+			"id": "aws_s3_bucket.logs",
+			"kind": "resource",
+			"range": {
+				"fileName": "main.tf",
+				"start": {
+					"line": 2,
+					"column": 1
+				},
+				"end": {
+					"line": 2,
+					"column": 32
+				}
+			}
+		},
+		{
+			"id": "var.name",
+			"kind": "variable",
+			"range": {
+				"fileName": "main.tf",
+				"start": {
+					"line": 6,
+					"column": 1
+				},
+				"end": {
+					"line": 6,
+					"column": 16
+				}
+			}
+		}
+	],
+	"edges": [
+		{
+			"from": "aws_s3_bucket.logs",
+			"to": "var.name"
+		}
+	]
+}`, actual)
+
+	assert.Equal(t, `digraph module {
+	"aws_s3_bucket.logs" [kind="resource", shape=box];
+	"var.name" [kind="variable", shape=ellipse];
+	"aws_s3_bucket.logs" -> "var.name";
+}
+`, graph.DOT())
+}
@@ -1,121 +1,483 @@
 package terraform
 
 import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"path"
+
 	"github.com/hashicorp/hcl/v2"
-	"github.com/hashicorp/hcl/v2/hclsyntax"
 )
 
 type File struct {
 	fileName    string
 	fileContent string
 	hclFile     *hcl.File
+	// recovered tells whether hclFile only holds the part of the file which parsed cleanly
+	recovered bool
 }
 
+// ParseModuleResult holds the outcome of parsing all the files in a module
 type ParseModuleResult struct {
-	parsedFiles map[string]interface{}
-	failedFiles map[string]interface{} // will contain files alongside user errors
-	debugLogs   map[string]interface{}
+	// ParsedFiles holds the parsed document of each Terraform file, made of plain Go values
+	ParsedFiles map[string]JSON
+	// FailedFiles will contain files alongside user errors
+	FailedFiles map[string]error
+	// DebugLogs will contain files alongside the details of both user and internal errors
+	DebugLogs map[string]string
+	// Diagnostics will contain files alongside the typed diagnostics of both user and internal errors,
+	// with their code and file range when they are known, and the warnings of the parsed files,
+	// e.g. the resources which declare too many instances to be expanded
+	Diagnostics map[string][]*Diagnostic
+	// SourceMaps holds the source map of each parsed file
+	SourceMaps map[string]SourceMap
+	// Overrides will contain, for each parsed file, the JSON paths replaced by an override file alongside the name of that file
+	// The override files themselves are merged into the files they override and are not part of the parsed files
+	Overrides map[string]map[string]string
+	// VariableSourceErrors will contain the errors of the variable sources keyed by the source they come from,
+	// e.g. -var "region", prod.tfvars or TF_VAR_region
+	VariableSourceErrors map[string]error
+	// VariableDiagnostics will contain the input variables whose type constraint or value was rejected alongside the reason,
+	// and the values of the -var-file files and of the TF_VAR_ environment variables for undeclared variables, which are ignored
+	VariableDiagnostics []VariableDiagnostic
+	// Outputs holds the output blocks of the module keyed by their name
+	Outputs map[string]Output
+	// RedactedPaths will contain, for each parsed file, the JSON paths of the values which were redacted
+	// because they are derived from sensitive variables
+	RedactedPaths map[string][]string
+	// DamagedRanges will contain, for each file with syntax errors which was recovered, the ranges which were dropped from it
+	// The errors themselves are still returned under the debug logs
+	DamagedRanges map[string][]SourceRange
+	// Comments will contain, for each parsed file, its comments keyed by the JSON path of the block or attribute
+	// they are attached to, when the PreserveComments option is set
+	Comments map[string]Comments
+}
+
+// VariableDiagnostic describes why the type constraint or the value of an input variable was rejected
+type VariableDiagnostic struct {
+	Variable string       `json:"variable"`
+	Summary  string       `json:"summary"`
+	Detail   string       `json:"detail"`
+	Range    *SourceRange `json:"range,omitempty"`
+	// ErrorMessage is the error_message of the validation block which failed, if any
+	ErrorMessage string `json:"errorMessage,omitempty"`
+}
+
+func newVariableDiagnostic(variable string, summary string, detail string, r *hcl.Range) VariableDiagnostic {
+	diagnostic := VariableDiagnostic{
+		Variable: variable,
+		Summary:  summary,
+		Detail:   detail,
+	}
+	if r != nil {
+		sourceRange := newSourceRange(*r)
+		diagnostic.Range = &sourceRange
+	}
+	return diagnostic
+}
+
+func newParseModuleResult() *ParseModuleResult {
+	return &ParseModuleResult{
+		ParsedFiles:          make(map[string]JSON),
+		FailedFiles:          make(map[string]error),
+		DebugLogs:            make(map[string]string),
+		Diagnostics:          make(map[string][]*Diagnostic),
+		SourceMaps:           make(map[string]SourceMap),
+		Overrides:            make(map[string]map[string]string),
+		Outputs:              make(map[string]Output),
+		RedactedPaths:        make(map[string][]string),
+		Comments:             make(map[string]Comments),
+		DamagedRanges:        make(map[string][]SourceRange),
+		VariableSourceErrors: make(map[string]error),
+	}
 }
 
-// ParseModule iterates through all the provided files in a module (.tf, terraform.tfvars, and *.auto.tfvars files)
+// ParseModule iterates through all the provided files in a module (.tf, .tf.json, terraform.tfvars, terraform.tfvars.json,
+// *.auto.tfvars, and *.auto.tfvars.json files)
 // It extracts the variables from each one, merges them, and dereferences them one by one
-func ParseModule(rawFiles map[string]interface{}) map[string]interface{} {
-	parseRes := &ParseModuleResult{
-		failedFiles: make(map[string]interface{}),
-		parsedFiles: make(map[string]interface{}),
-		debugLogs:   make(map[string]interface{}),
+// Override files are merged into the files they override, and the overridden paths are returned under overrides
+// The output blocks of the module are returned under outputs, with their evaluated value, sensitive flag and description
+// The values derived from the variables declared with sensitive = true are redacted, and their paths are returned under redactedPaths
+// Alongside the parsed files, it returns a JSON source map for each of them under sourceMaps
+// The failed files hold the message of the errors and the debug logs their details, while the diagnostics are JSON strings
+// holding the code and the file range of each of the errors
+// The optional variable sources are the -var and -var-file options and the environment variables passed to the Terraform CLI,
+// e.g. {"type": "var", "value": "region=eu-west-1"}, in the order they are provided, and their errors are returned under variableSourceErrors
+// It is a thin adapter over ParseModuleFiles for callers which can only exchange untyped maps and strings
+func ParseModule(rawFiles map[string]interface{}, rawVariableSources ...interface{}) map[string]interface{} {
+	files := make(map[string][]byte, len(rawFiles))
+	for fileName, fileContentInterface := range rawFiles {
+		fileContent, ok := fileContentInterface.(string)
+		if !ok {
+			continue
+		}
+		files[fileName] = []byte(fileContent)
 	}
 
-	files := processFiles(rawFiles, parseRes)
+	options := DefaultOptions()
+	options.VariableSources = parseVariableSources(rawVariableSources)
 
-	vars := extractModuleVariables(files, parseRes)
+	return ParseModuleFiles(files, options).toJSON()
+}
 
-	parseModuleFiles(files, vars, parseRes)
+// ParseModuleFiles iterates through all the provided files in a module, keyed by their file names
+// It extracts the variables from each one, merges them, and dereferences them one by one
+func ParseModuleFiles(rawFiles map[string][]byte, options Options) *ParseModuleResult {
+	parseRes, _, _ := parseModule(rawFiles, ".", nil, options)
+	return parseRes
+}
 
-	return JSON{
-		"parsedFiles": parseRes.parsedFiles,
-		"failedFiles": parseRes.failedFiles,
-		"debugLogs":   parseRes.debugLogs,
+// ParseModuleFS parses the module found at the root of the provided filesystem
+// Only the files at the root are part of the module, the same way Terraform ignores nested directories
+// The filesystem functions read from the provided filesystem unless the options hold another one
+func ParseModuleFS(fsys fs.FS, options Options) (*ParseModuleResult, error) {
+	rawFiles, err := readModuleFiles(fsys, ".", true)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.FS == nil {
+		options.FS = fsys
 	}
+
+	return ParseModuleFiles(rawFiles, options), nil
 }
 
-func processFiles(rawFiles map[string]interface{}, parseRes *ParseModuleResult) map[string]File {
-	files := make(map[string]File)
+// parseModule parses the files of the module in the provided directory of the filesystem, using the provided input values
+// over the ones found in the files, and also returns the processed files and the module variables so that the modules
+// it calls can be resolved
+func parseModule(rawFiles map[string][]byte, moduleDir string, inputs ValueMap, options Options) (*ParseModuleResult, map[string]File, ModuleVariables) {
+	parseRes := newParseModuleResult()
 
-	for fileName, fileContentInterface := range rawFiles {
-		fileContent, ok := fileContentInterface.(string)
-		if !ok {
+	files := processFiles(rawFiles, options, parseRes)
+
+	vars := extractModuleVariables(files, moduleDir, inputs, options, parseRes)
+
+	parseModuleFiles(files, vars, options, parseRes)
+
+	applyOverrides(parseRes)
+
+	if options.ResolveReferences {
+		resolveReferences(parseRes)
+	}
+
+	extractOutputs(parseRes)
+
+	recordRedactedPaths(parseRes)
+
+	return parseRes, files, vars
+}
+
+// readModuleFiles reads the files of the module in the provided directory, keyed by their path in the filesystem
+// Variable definition files are only read when requested, as Terraform only loads them for the root module
+func readModuleFiles(fsys fs.FS, dir string, withVariableFiles bool) (map[string][]byte, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	rawFiles := make(map[string][]byte)
+	for _, entry := range entries {
+		fileName := path.Join(dir, entry.Name())
+		if entry.IsDir() {
+			continue
+		}
+		if !isValidTerraformFile(fileName) && !(withVariableFiles && isValidInputVariablesFile(fileName)) {
+			continue
+		}
+
+		fileContent, err := fs.ReadFile(fsys, fileName)
+		if err != nil {
+			return nil, err
+		}
+		rawFiles[fileName] = fileContent
+	}
+
+	return rawFiles, nil
+}
+
+// toJSON converts the result into the untyped shape returned by ParseModule
+func (parseRes *ParseModuleResult) toJSON() JSON {
+	parsedFiles := make(map[string]interface{})
+	failedFiles := make(map[string]interface{})
+	debugLogs := make(map[string]interface{})
+	diagnostics := make(map[string]interface{})
+	sourceMaps := make(map[string]interface{})
+	overrides := make(map[string]interface{})
+	redactedPaths := make(map[string]interface{})
+	comments := make(map[string]interface{})
+	damagedRanges := make(map[string]interface{})
+	variableSourceErrors := make(map[string]interface{})
+
+	// the errors of converting the result into JSON strings are internal errors, which are only logged
+	recordInternalError := func(fileName string, err error) {
+		internalErr := createInternalJSONParsingError([]error{err})
+		debugLogs[fileName] = GenerateDebugLogs(internalErr)
+		diagnostics[fileName] = diagnosticsJSON(diagnosticsOf(internalErr))
+	}
+
+	for fileName, err := range parseRes.FailedFiles {
+		failedFiles[fileName] = err.Error()
+	}
+	for sourceName, err := range parseRes.VariableSourceErrors {
+		variableSourceErrors[sourceName] = errorJSON(err)
+	}
+	for fileName, debugLog := range parseRes.DebugLogs {
+		debugLogs[fileName] = debugLog
+	}
+	for fileName, fileDiagnostics := range parseRes.Diagnostics {
+		diagnostics[fileName] = diagnosticsJSON(fileDiagnostics)
+	}
+	for fileName, document := range parseRes.ParsedFiles {
+		jsonBytes, err := json.MarshalIndent(document, "", "\t")
+		if err != nil {
+			recordInternalError(fileName, err)
+			continue
+		}
+		parsedFiles[fileName] = string(jsonBytes)
+
+		jsonBytes, err = json.MarshalIndent(parseRes.SourceMaps[fileName], "", "\t")
+		if err != nil {
+			recordInternalError(fileName, err)
 			continue
 		}
+		sourceMaps[fileName] = string(jsonBytes)
+	}
+	for fileName, overriddenPaths := range parseRes.Overrides {
+		jsonBytes, err := json.MarshalIndent(overriddenPaths, "", "\t")
+		if err != nil {
+			recordInternalError(fileName, err)
+			continue
+		}
+		overrides[fileName] = string(jsonBytes)
+	}
+	for fileName, paths := range parseRes.RedactedPaths {
+		jsonBytes, err := json.MarshalIndent(paths, "", "\t")
+		if err != nil {
+			recordInternalError(fileName, err)
+			continue
+		}
+		redactedPaths[fileName] = string(jsonBytes)
+	}
+	for fileName, fileComments := range parseRes.Comments {
+		jsonBytes, err := json.MarshalIndent(fileComments, "", "\t")
+		if err != nil {
+			recordInternalError(fileName, err)
+			continue
+		}
+		comments[fileName] = string(jsonBytes)
+	}
+	for fileName, ranges := range parseRes.DamagedRanges {
+		jsonBytes, err := json.MarshalIndent(ranges, "", "\t")
+		if err != nil {
+			recordInternalError(fileName, err)
+			continue
+		}
+		damagedRanges[fileName] = string(jsonBytes)
+	}
+
+	variableDiagnostics := "[]"
+	if len(parseRes.VariableDiagnostics) > 0 {
+		jsonBytes, err := json.MarshalIndent(parseRes.VariableDiagnostics, "", "\t")
+		if err == nil {
+			variableDiagnostics = string(jsonBytes)
+		}
+	}
 
-		hclFile, hclDiags := hclsyntax.ParseConfig([]byte(fileContent), fileName, hcl.Pos{Line: 1, Column: 1})
+	// the outputs are keyed by their name, like the other keys are by file name
+	outputs := make(map[string]interface{})
+	for name, output := range parseRes.Outputs {
+		jsonBytes, err := json.MarshalIndent(output, "", "\t")
+		if err != nil {
+			recordInternalError(output.FileName, err)
+			continue
+		}
+		outputs[name] = string(jsonBytes)
+	}
+
+	return JSON{
+		"parsedFiles":   parsedFiles,
+		"failedFiles":   failedFiles,
+		"debugLogs":     debugLogs,
+		"diagnostics":   diagnostics,
+		"sourceMaps":    sourceMaps,
+		"overrides":     overrides,
+		"redactedPaths": redactedPaths,
+		"comments":      comments,
+		"damagedRanges": damagedRanges,
+		// the errors of the variable sources are not specific to a file of the module
+		"variableSourceErrors": variableSourceErrors,
+		// the diagnostics are not specific to a file so they are returned as a single JSON string
+		"variableDiagnostics": variableDiagnostics,
+		"outputs":             outputs,
+	}
+}
+
+// errorJSON returns the message, code and diagnostics of an error as a JSON string
+func errorJSON(err error) string {
+	var customError *CustomError
+	if !errors.As(err, &customError) {
+		customError = &CustomError{message: err.Error(), errors: []error{err}}
+	}
+	jsonBytes, jsonErr := json.MarshalIndent(customError, "", "\t")
+	if jsonErr != nil {
+		return err.Error()
+	}
+	return string(jsonBytes)
+}
+
+// diagnosticsJSON returns the diagnostics of the errors of a file as a JSON string
+func diagnosticsJSON(diagnostics []*Diagnostic) string {
+	jsonBytes, err := json.MarshalIndent(diagnostics, "", "\t")
+	if err != nil {
+		return "[]"
+	}
+	return string(jsonBytes)
+}
+
+// recordDebugLog records the details and the diagnostics of an error of a file, be it a user or an internal error
+func recordDebugLog(parseRes *ParseModuleResult, fileName string, err error) {
+	parseRes.DebugLogs[fileName] = GenerateDebugLogs(err)
+	parseRes.Diagnostics[fileName] = diagnosticsOf(err)
+}
+
+func processFiles(rawFiles map[string][]byte, options Options, parseRes *ParseModuleResult) map[string]File {
+	files := make(map[string]File)
+
+	for fileName, fileContent := range rawFiles {
+		hclFile, hclDiags := parseHclFile(fileName, fileContent)
+		recovered := false
 		if hclDiags.HasErrors() {
 			err := createInvalidHCLError(hclDiags.Errs())
-			parseRes.debugLogs[fileName] = GenerateDebugLogs(err)
-			parseRes.failedFiles[fileName] = err.Error()
-			continue
+			recordDebugLog(parseRes, fileName, err)
+
+			var damagedRanges []SourceRange
+			if options.RecoverFromErrors {
+				hclFile, damagedRanges, recovered = recoverHclFile(hclFile, hclDiags)
+			}
+			if !recovered {
+				parseRes.FailedFiles[fileName] = err
+				continue
+			}
+			parseRes.DamagedRanges[fileName] = damagedRanges
 		}
 
 		files[fileName] = File{
 			fileName:    fileName,
-			fileContent: fileContent,
+			fileContent: string(fileContent),
 			hclFile:     hclFile,
+			recovered:   recovered,
 		}
 	}
 
 	return files
 }
 
-func parseModuleFiles(files map[string]File, vars ModuleVariables, parseRes *ParseModuleResult) {
+func parseModuleFiles(files map[string]File, vars ModuleVariables, options Options, parseRes *ParseModuleResult) {
 	for fileName, file := range files {
 		// failedFiles contains user errors so if the file failed at extract time, we don't try to parse it
-		if _, ok := parseRes.failedFiles[fileName]; isValidTerraformFile(fileName) && !ok {
-			parsedJson, err := parseHclToJson(fileName, file.fileContent, vars)
+		if _, ok := parseRes.FailedFiles[fileName]; isValidTerraformFile(fileName) && !ok {
+			var document JSON
+			var sourceMap SourceMap
+			var diagnostics []*Diagnostic
+			var err error
+			if file.recovered {
+				document, sourceMap, diagnostics, err = parseHclFileToDocument(fileName, file.hclFile, vars, options)
+			} else {
+				document, sourceMap, diagnostics, err = parseHclToDocument(fileName, file.fileContent, vars, options)
+			}
 			if err != nil {
 				// skip non-user errors
-				if isUserError(err) {
-					parseRes.failedFiles[fileName] = err.Error()
+				var customError *CustomError
+				if errors.As(err, &customError) && customError.IsUserError() {
+					parseRes.FailedFiles[fileName] = err
 				}
 				// but still log them
-				parseRes.debugLogs[fileName] = GenerateDebugLogs(err)
+				recordDebugLog(parseRes, fileName, err)
 				continue
 			}
-			parseRes.parsedFiles[fileName] = parsedJson
+			parseRes.ParsedFiles[fileName] = document
+			parseRes.SourceMaps[fileName] = sourceMap
+			if len(diagnostics) > 0 {
+				// the warnings of a recovered file follow the errors it was recovered from
+				parseRes.Diagnostics[fileName] = append(parseRes.Diagnostics[fileName], diagnostics...)
+			}
+			if options.PreserveComments {
+				if comments := ExtractComments(fileName, file.fileContent, sourceMap); len(comments) > 0 {
+					parseRes.Comments[fileName] = comments
+				}
+			}
 		}
 	}
 }
 
-func extractModuleVariables(files map[string]File, parseRes *ParseModuleResult) ModuleVariables {
+func extractModuleVariables(files map[string]File, moduleDir string, inputOverrides ValueMap, options Options, parseRes *ParseModuleResult) ModuleVariables {
 	inputsByFile := InputVariablesByFile{}
 	localExprsMap := ExpressionMap{}
+	declarations := VariableDeclarations{}
 
-	for fileName, file := range files {
+	// locals declared in override files replace the ones declared in the other files
+	fileNames := make([]string, 0, len(files))
+	for fileName := range files {
+		fileNames = append(fileNames, fileName)
+	}
+	sortOverrideFilesLast(fileNames)
+
+	for _, fileName := range fileNames {
+		file := files[fileName]
 		inputsMap, localsMap, err := extractVariables(file)
 		if err != nil {
 			// skip non-user errors
-			if isUserError(err) {
-				parseRes.debugLogs[fileName] = GenerateDebugLogs(err)
-				parseRes.failedFiles[fileName] = err.Error()
+			var customError *CustomError
+			if errors.As(err, &customError) && customError.IsUserError() {
+				recordDebugLog(parseRes, fileName, err)
+				parseRes.FailedFiles[fileName] = err
 			}
 		}
 		inputsByFile[fileName] = inputsMap
 		for localName, localVal := range localsMap {
 			localExprsMap[localName] = localVal
 		}
+
+		if isValidTerraformFile(fileName) {
+			fileDeclarations, _ := extractVariableDeclarationsFromTfFile(file.hclFile)
+			for name, declaration := range fileDeclarations {
+				declarations[name] = declaration
+			}
+		}
 	}
 
+	// the declared types tell how to parse the raw values of the variable sources
+	inputSources := extractInputVariablesFromSources(options.VariableSources, declarations, parseRes)
+
 	// merge inputs so they can be prioritised and used across multiple files
-	inputs := mergeInputVariables(inputsByFile)
+	inputs := mergeInputVariables(inputsByFile, inputSources)
+	for name, value := range inputOverrides {
+		inputs[name] = value
+	}
+
+	inputs, diagnostics := convertInputVariables(inputs, declarations)
+	parseRes.VariableDiagnostics = append(parseRes.VariableDiagnostics, diagnostics...)
+
+	// the values derived from the sensitive variables are redacted from the parsed files and from the validation diagnostics,
+	// so they are marked before the variables are validated
+	inputs = markSensitiveInputVariables(inputs, declarations)
+
+	if options.ValidateVariables {
+		parseRes.VariableDiagnostics = append(parseRes.VariableDiagnostics, validateInputVariables(inputs, declarations)...)
+	}
 
 	// dereference locals in case they reference each other or other input variables
-	locals := dereferenceLocals(localExprsMap, inputs)
+	paths := pathVariables(moduleDir, options)
+	locals := dereferenceLocals(localExprsMap, inputs, paths, moduleFunctions(options))
 
 	return ModuleVariables{
 		inputs: inputs,
 		locals: locals,
+		path:   paths,
 	}
 }
 
 // used for mocking in the tests
-var parseHclToJson = ParseHclToJson
+var parseHclToDocument = parseHclSourceToDocument
 var extractVariables = ExtractVariables
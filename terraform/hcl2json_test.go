@@ -1,13 +1,17 @@
 package terraform
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParseModuleSuccess(t *testing.T) {
@@ -40,6 +44,18 @@ variable "dummy" {
 	}
 }`
 
+	sourceMapOutput := func(fileName string) string {
+		return sourceMapJSON(SourceMap{
+			"resource.aws_security_group.allow_ssh":             testSourceRange(fileName, 2, 1, 6, 2),
+			"resource.aws_security_group.allow_ssh.cidr_blocks": testSourceRange(fileName, 5, 2, 5, 25),
+			"resource.aws_security_group.allow_ssh.description": testSourceRange(fileName, 4, 2, 4, 49),
+			"resource.aws_security_group.allow_ssh.name":        testSourceRange(fileName, 3, 2, 3, 27),
+			"variable.dummy":         testSourceRange(fileName, 8, 1, 11, 2),
+			"variable.dummy.default": testSourceRange(fileName, 10, 2, 10, 19),
+			"variable.dummy.type":    testSourceRange(fileName, 9, 2, 9, 17),
+		})
+	}
+
 	filesystemFiles, filesystemExpected, err := setupFilesystemTests()
 	assert.Nil(t, err)
 
@@ -63,7 +79,19 @@ variable "dummy" {
 					"test1.tf": jsonOutput,
 					"test2.tf": jsonOutput,
 				},
-				"debugLogs": map[string]interface{}{},
+				"debugLogs":   map[string]interface{}{},
+				"diagnostics": map[string]interface{}{},
+				"sourceMaps": map[string]interface{}{
+					"test1.tf": sourceMapOutput("test1.tf"),
+					"test2.tf": sourceMapOutput("test2.tf"),
+				},
+				"overrides":            map[string]interface{}{},
+				"redactedPaths":        map[string]interface{}{},
+				"comments":             map[string]interface{}{},
+				"damagedRanges":        map[string]interface{}{},
+				"variableSourceErrors": map[string]interface{}{},
+				"outputs":              map[string]interface{}{},
+				"variableDiagnostics":  "[]",
 			},
 		}, {
 			name: "A valid .tf and terraform.tfvars file with no overlapping variables and no error",
@@ -92,6 +120,22 @@ resource "aws_security_group" "allow_ssh" {
 	}
 }`,
 				},
+				"diagnostics": map[string]interface{}{},
+				"sourceMaps": map[string]interface{}{
+					"test.tf": sourceMapJSON(SourceMap{
+						"resource.aws_security_group.allow_ssh":             testSourceRange("test.tf", 2, 1, 6, 2),
+						"resource.aws_security_group.allow_ssh.cidr_blocks": testSourceRange("test.tf", 5, 2, 5, 25),
+						"resource.aws_security_group.allow_ssh.description": testSourceRange("test.tf", 4, 2, 4, 49),
+						"resource.aws_security_group.allow_ssh.name":        testSourceRange("test.tf", 3, 2, 3, 27),
+					}),
+				},
+				"overrides":            map[string]interface{}{},
+				"redactedPaths":        map[string]interface{}{},
+				"comments":             map[string]interface{}{},
+				"damagedRanges":        map[string]interface{}{},
+				"variableSourceErrors": map[string]interface{}{},
+				"outputs":              map[string]interface{}{},
+				"variableDiagnostics":  "[]",
 			},
 		}, {
 			name: "A valid .tf and random .tfvars file with overlapping variables and no error",
@@ -131,6 +175,17 @@ variable "dummy" {
 	}
 }`,
 				},
+				"diagnostics": map[string]interface{}{},
+				"sourceMaps": map[string]interface{}{
+					"test.tf": sourceMapOutput("test.tf"),
+				},
+				"overrides":            map[string]interface{}{},
+				"redactedPaths":        map[string]interface{}{},
+				"comments":             map[string]interface{}{},
+				"damagedRanges":        map[string]interface{}{},
+				"variableSourceErrors": map[string]interface{}{},
+				"outputs":              map[string]interface{}{},
+				"variableDiagnostics":  "[]",
 			},
 		}, {
 			name: "A valid .tf and terraform.tfvars file with overlapping variables and no error",
@@ -170,6 +225,17 @@ variable "dummy" {
 	}
 }`,
 				},
+				"diagnostics": map[string]interface{}{},
+				"sourceMaps": map[string]interface{}{
+					"test.tf": sourceMapOutput("test.tf"),
+				},
+				"overrides":            map[string]interface{}{},
+				"redactedPaths":        map[string]interface{}{},
+				"comments":             map[string]interface{}{},
+				"damagedRanges":        map[string]interface{}{},
+				"variableSourceErrors": map[string]interface{}{},
+				"outputs":              map[string]interface{}{},
+				"variableDiagnostics":  "[]",
 			},
 		}, {
 			name: "A valid .tf, terraform.tfvars, and *.auto.tfvars file with overlapping variables and no error",
@@ -211,6 +277,17 @@ variable "dummy" {
 	}
 }`,
 				},
+				"diagnostics": map[string]interface{}{},
+				"sourceMaps": map[string]interface{}{
+					"test.tf": sourceMapOutput("test.tf"),
+				},
+				"overrides":            map[string]interface{}{},
+				"redactedPaths":        map[string]interface{}{},
+				"comments":             map[string]interface{}{},
+				"damagedRanges":        map[string]interface{}{},
+				"variableSourceErrors": map[string]interface{}{},
+				"outputs":              map[string]interface{}{},
+				"variableDiagnostics":  "[]",
 			},
 		}, {
 			name:     "Multiple valid .tf files with default variables, a terraform.tfvars file and multiple *.auto.tfvars files",
@@ -240,6 +317,25 @@ variable "dummy" {
 				"debugLogs": map[string]interface{}{
 					"fail.tf": "\nTest",
 				},
+				"diagnostics": map[string]interface{}{
+					"fail.tf": `[
+	{
+		"severity": "error",
+		"code": "",
+		"summary": "Test"
+	}
+]`,
+				},
+				"sourceMaps": map[string]interface{}{
+					"test2.tf": sourceMapOutput("test2.tf"),
+				},
+				"overrides":            map[string]interface{}{},
+				"redactedPaths":        map[string]interface{}{},
+				"comments":             map[string]interface{}{},
+				"damagedRanges":        map[string]interface{}{},
+				"variableSourceErrors": map[string]interface{}{},
+				"outputs":              map[string]interface{}{},
+				"variableDiagnostics":  "[]",
 			},
 		},
 		{
@@ -261,7 +357,19 @@ variable "dummy" {
 					"fail.tf":  jsonOutput, // it's intentional for files that fail with internal errors at extraction time to still try to parse as the internal error can be a flake
 					"test2.tf": jsonOutput,
 				},
-				"debugLogs": map[string]interface{}{},
+				"debugLogs":   map[string]interface{}{},
+				"diagnostics": map[string]interface{}{},
+				"sourceMaps": map[string]interface{}{
+					"fail.tf":  sourceMapOutput("fail.tf"),
+					"test2.tf": sourceMapOutput("test2.tf"),
+				},
+				"overrides":            map[string]interface{}{},
+				"redactedPaths":        map[string]interface{}{},
+				"comments":             map[string]interface{}{},
+				"damagedRanges":        map[string]interface{}{},
+				"variableSourceErrors": map[string]interface{}{},
+				"outputs":              map[string]interface{}{},
+				"variableDiagnostics":  "[]",
 			},
 		},
 		{
@@ -287,6 +395,25 @@ variable "dummy" {
 				"debugLogs": map[string]interface{}{
 					"fail.tf": "\nTest",
 				},
+				"diagnostics": map[string]interface{}{
+					"fail.tf": `[
+	{
+		"severity": "error",
+		"code": "",
+		"summary": "Test"
+	}
+]`,
+				},
+				"sourceMaps": map[string]interface{}{
+					"test2.tf": sourceMapOutput("test2.tf"),
+				},
+				"overrides":            map[string]interface{}{},
+				"redactedPaths":        map[string]interface{}{},
+				"comments":             map[string]interface{}{},
+				"damagedRanges":        map[string]interface{}{},
+				"variableSourceErrors": map[string]interface{}{},
+				"outputs":              map[string]interface{}{},
+				"variableDiagnostics":  "[]",
 			},
 		},
 		{
@@ -310,6 +437,25 @@ variable "dummy" {
 				"debugLogs": map[string]interface{}{
 					"fail.tf": "\nTest",
 				},
+				"diagnostics": map[string]interface{}{
+					"fail.tf": `[
+	{
+		"severity": "error",
+		"code": "",
+		"summary": "Test"
+	}
+]`,
+				},
+				"sourceMaps": map[string]interface{}{
+					"test2.tf": sourceMapOutput("test2.tf"),
+				},
+				"overrides":            map[string]interface{}{},
+				"redactedPaths":        map[string]interface{}{},
+				"comments":             map[string]interface{}{},
+				"damagedRanges":        map[string]interface{}{},
+				"variableSourceErrors": map[string]interface{}{},
+				"outputs":              map[string]interface{}{},
+				"variableDiagnostics":  "[]",
 			},
 		},
 		// Test added for https://snyksec.atlassian.net/browse/IAC-3138
@@ -356,6 +502,25 @@ DOC
 				"debugLogs": map[string]interface{}{
 					"fail.tf": "\npanic: value is null",
 				},
+				"diagnostics": map[string]interface{}{
+					"fail.tf": `[
+	{
+		"severity": "error",
+		"code": "INTERNAL_HCL_PARSING",
+		"summary": "panic: value is null"
+	}
+]`,
+				},
+				"sourceMaps": map[string]interface{}{
+					"test2.tf": sourceMapOutput("test2.tf"),
+				},
+				"overrides":            map[string]interface{}{},
+				"redactedPaths":        map[string]interface{}{},
+				"comments":             map[string]interface{}{},
+				"damagedRanges":        map[string]interface{}{},
+				"variableSourceErrors": map[string]interface{}{},
+				"outputs":              map[string]interface{}{},
+				"variableDiagnostics":  "[]",
 			},
 		},
 		{
@@ -390,7 +555,25 @@ DOC
 	}
 }`,
 				},
-				"debugLogs": map[string]interface{}{},
+				"debugLogs":   map[string]interface{}{},
+				"diagnostics": map[string]interface{}{},
+				"sourceMaps": map[string]interface{}{
+					"test.tf": sourceMapJSON(SourceMap{
+						"locals":                                testSourceRange("test.tf", 8, 5, 10, 6),
+						"locals.dummy":                          testSourceRange("test.tf", 9, 6, 9, 27),
+						"resource.aws_security_group.allow_ssh": testSourceRange("test.tf", 2, 5, 6, 6),
+						"resource.aws_security_group.allow_ssh.cidr_blocks": testSourceRange("test.tf", 5, 6, 5, 31),
+						"resource.aws_security_group.allow_ssh.description": testSourceRange("test.tf", 4, 6, 4, 53),
+						"resource.aws_security_group.allow_ssh.name":        testSourceRange("test.tf", 3, 6, 3, 31),
+					}),
+				},
+				"overrides":            map[string]interface{}{},
+				"redactedPaths":        map[string]interface{}{},
+				"comments":             map[string]interface{}{},
+				"damagedRanges":        map[string]interface{}{},
+				"variableSourceErrors": map[string]interface{}{},
+				"outputs":              map[string]interface{}{},
+				"variableDiagnostics":  "[]",
 			},
 		},
 		{
@@ -424,7 +607,27 @@ DOC
 		}
 	}
 }`},
-				"debugLogs": map[string]interface{}{},
+				"debugLogs":   map[string]interface{}{},
+				"diagnostics": map[string]interface{}{},
+				"sourceMaps": map[string]interface{}{
+					"test.tf": sourceMapJSON(SourceMap{
+						"locals":       testSourceRange("test.tf", 2, 5, 4, 6),
+						"locals.dummy": testSourceRange("test.tf", 3, 6, 3, 27),
+					}),
+					"test2.tf": sourceMapJSON(SourceMap{
+						"resource.aws_security_group.allow_ssh":             testSourceRange("test2.tf", 2, 5, 6, 6),
+						"resource.aws_security_group.allow_ssh.cidr_blocks": testSourceRange("test2.tf", 5, 6, 5, 31),
+						"resource.aws_security_group.allow_ssh.description": testSourceRange("test2.tf", 4, 6, 4, 53),
+						"resource.aws_security_group.allow_ssh.name":        testSourceRange("test2.tf", 3, 6, 3, 31),
+					}),
+				},
+				"overrides":            map[string]interface{}{},
+				"redactedPaths":        map[string]interface{}{},
+				"comments":             map[string]interface{}{},
+				"damagedRanges":        map[string]interface{}{},
+				"variableSourceErrors": map[string]interface{}{},
+				"outputs":              map[string]interface{}{},
+				"variableDiagnostics":  "[]",
 			},
 		},
 		{
@@ -459,7 +662,25 @@ DOC
 	}
 }`,
 				},
-				"debugLogs": map[string]interface{}{},
+				"debugLogs":   map[string]interface{}{},
+				"diagnostics": map[string]interface{}{},
+				"sourceMaps": map[string]interface{}{
+					"test.tf": sourceMapJSON(SourceMap{
+						"locals":                                testSourceRange("test.tf", 8, 5, 10, 6),
+						"locals.dummy":                          testSourceRange("test.tf", 9, 6, 9, 27),
+						"resource.aws_security_group.allow_ssh": testSourceRange("test.tf", 2, 5, 6, 6),
+						"resource.aws_security_group.allow_ssh.cidr_blocks": testSourceRange("test.tf", 5, 6, 5, 31),
+						"resource.aws_security_group.allow_ssh.description": testSourceRange("test.tf", 4, 6, 4, 53),
+						"resource.aws_security_group.allow_ssh.name":        testSourceRange("test.tf", 3, 6, 3, 31),
+					}),
+				},
+				"overrides":            map[string]interface{}{},
+				"redactedPaths":        map[string]interface{}{},
+				"comments":             map[string]interface{}{},
+				"damagedRanges":        map[string]interface{}{},
+				"variableSourceErrors": map[string]interface{}{},
+				"outputs":              map[string]interface{}{},
+				"variableDiagnostics":  "[]",
 			},
 		},
 		{
@@ -503,7 +724,28 @@ DOC
 		}
 	}
 }`},
-				"debugLogs": map[string]interface{}{},
+				"debugLogs":   map[string]interface{}{},
+				"diagnostics": map[string]interface{}{},
+				"sourceMaps": map[string]interface{}{
+					"test.tf": sourceMapJSON(SourceMap{
+						"locals":                                testSourceRange("test.tf", 8, 5, 10, 6),
+						"locals.dummy":                          testSourceRange("test.tf", 9, 6, 9, 23),
+						"resource.aws_security_group.allow_ssh": testSourceRange("test.tf", 2, 5, 6, 6),
+						"resource.aws_security_group.allow_ssh.cidr_blocks": testSourceRange("test.tf", 5, 6, 5, 31),
+						"resource.aws_security_group.allow_ssh.description": testSourceRange("test.tf", 4, 6, 4, 53),
+						"resource.aws_security_group.allow_ssh.name":        testSourceRange("test.tf", 3, 6, 3, 31),
+						"variable.dummy":         testSourceRange("test.tf", 12, 5, 15, 6),
+						"variable.dummy.default": testSourceRange("test.tf", 13, 6, 13, 29),
+						"variable.dummy.type":    testSourceRange("test.tf", 14, 6, 14, 23),
+					}),
+				},
+				"overrides":            map[string]interface{}{},
+				"redactedPaths":        map[string]interface{}{},
+				"comments":             map[string]interface{}{},
+				"damagedRanges":        map[string]interface{}{},
+				"variableSourceErrors": map[string]interface{}{},
+				"outputs":              map[string]interface{}{},
+				"variableDiagnostics":  "[]",
 			},
 		},
 		{
@@ -571,7 +813,39 @@ locals {
 		"d9": 9
 	}
 }`},
-				"debugLogs": map[string]interface{}{},
+				"debugLogs":   map[string]interface{}{},
+				"diagnostics": map[string]interface{}{},
+				"sourceMaps": map[string]interface{}{
+					"test1.tf": sourceMapJSON(SourceMap{
+						"locals":                                testSourceRange("test1.tf", 8, 1, 13, 2),
+						"locals.d2":                             testSourceRange("test1.tf", 12, 2, 12, 19),
+						"locals.d4":                             testSourceRange("test1.tf", 11, 2, 11, 19),
+						"locals.d6":                             testSourceRange("test1.tf", 10, 2, 10, 19),
+						"locals.d8":                             testSourceRange("test1.tf", 9, 2, 9, 19),
+						"resource.aws_security_group.allow_ssh": testSourceRange("test1.tf", 2, 1, 6, 2),
+						"resource.aws_security_group.allow_ssh.cidr_blocks": testSourceRange("test1.tf", 5, 2, 5, 24),
+						"resource.aws_security_group.allow_ssh.description": testSourceRange("test1.tf", 4, 2, 4, 49),
+						"resource.aws_security_group.allow_ssh.name":        testSourceRange("test1.tf", 3, 2, 3, 27),
+						"variable.dummy":         testSourceRange("test1.tf", 15, 1, 18, 2),
+						"variable.dummy.default": testSourceRange("test1.tf", 16, 2, 16, 13),
+						"variable.dummy.type":    testSourceRange("test1.tf", 17, 2, 17, 17),
+					}),
+					"test2.tf": sourceMapJSON(SourceMap{
+						"locals":    testSourceRange("test2.tf", 2, 1, 8, 2),
+						"locals.d1": testSourceRange("test2.tf", 7, 2, 7, 16),
+						"locals.d3": testSourceRange("test2.tf", 6, 2, 6, 19),
+						"locals.d5": testSourceRange("test2.tf", 5, 2, 5, 19),
+						"locals.d7": testSourceRange("test2.tf", 4, 2, 4, 19),
+						"locals.d9": testSourceRange("test2.tf", 3, 2, 3, 19),
+					}),
+				},
+				"overrides":            map[string]interface{}{},
+				"redactedPaths":        map[string]interface{}{},
+				"comments":             map[string]interface{}{},
+				"damagedRanges":        map[string]interface{}{},
+				"variableSourceErrors": map[string]interface{}{},
+				"outputs":              map[string]interface{}{},
+				"variableDiagnostics":  "[]",
 			},
 		},
 		{
@@ -608,7 +882,27 @@ locals {
 		}
 	}
 }`},
-				"debugLogs": map[string]interface{}{},
+				"debugLogs":   map[string]interface{}{},
+				"diagnostics": map[string]interface{}{},
+				"sourceMaps": map[string]interface{}{
+					"test1.tf": sourceMapJSON(SourceMap{
+						"locals":                                testSourceRange("test1.tf", 8, 1, 12, 2),
+						"locals.d1":                             testSourceRange("test1.tf", 11, 2, 11, 8),
+						"locals.d2":                             testSourceRange("test1.tf", 10, 2, 10, 8),
+						"locals.d3":                             testSourceRange("test1.tf", 9, 2, 9, 74),
+						"resource.aws_security_group.allow_ssh": testSourceRange("test1.tf", 2, 1, 6, 2),
+						"resource.aws_security_group.allow_ssh.cidr_blocks": testSourceRange("test1.tf", 5, 2, 5, 24),
+						"resource.aws_security_group.allow_ssh.description": testSourceRange("test1.tf", 4, 2, 4, 49),
+						"resource.aws_security_group.allow_ssh.name":        testSourceRange("test1.tf", 3, 2, 3, 27),
+					}),
+				},
+				"overrides":            map[string]interface{}{},
+				"redactedPaths":        map[string]interface{}{},
+				"comments":             map[string]interface{}{},
+				"damagedRanges":        map[string]interface{}{},
+				"variableSourceErrors": map[string]interface{}{},
+				"outputs":              map[string]interface{}{},
+				"variableDiagnostics":  "[]",
 			},
 		},
 		{
@@ -709,7 +1003,58 @@ locals {
 	}
 }`,
 				},
-				"debugLogs": map[string]interface{}{},
+				"debugLogs":   map[string]interface{}{},
+				"diagnostics": map[string]interface{}{},
+				"sourceMaps": map[string]interface{}{
+					"test1.tf": sourceMapJSON(SourceMap{
+						"locals":                                testSourceRange("test1.tf", 8, 1, 43, 2),
+						"locals.d1":                             testSourceRange("test1.tf", 42, 2, 42, 8),
+						"locals.d10":                            testSourceRange("test1.tf", 33, 2, 33, 20),
+						"locals.d11":                            testSourceRange("test1.tf", 32, 2, 32, 21),
+						"locals.d12":                            testSourceRange("test1.tf", 31, 2, 31, 21),
+						"locals.d13":                            testSourceRange("test1.tf", 30, 2, 30, 21),
+						"locals.d14":                            testSourceRange("test1.tf", 29, 2, 29, 21),
+						"locals.d15":                            testSourceRange("test1.tf", 28, 2, 28, 21),
+						"locals.d16":                            testSourceRange("test1.tf", 27, 2, 27, 21),
+						"locals.d17":                            testSourceRange("test1.tf", 26, 2, 26, 21),
+						"locals.d18":                            testSourceRange("test1.tf", 25, 2, 25, 21),
+						"locals.d19":                            testSourceRange("test1.tf", 24, 2, 24, 21),
+						"locals.d2":                             testSourceRange("test1.tf", 41, 2, 41, 19),
+						"locals.d20":                            testSourceRange("test1.tf", 23, 2, 23, 21),
+						"locals.d21":                            testSourceRange("test1.tf", 22, 2, 22, 21),
+						"locals.d22":                            testSourceRange("test1.tf", 21, 2, 21, 21),
+						"locals.d23":                            testSourceRange("test1.tf", 20, 2, 20, 21),
+						"locals.d24":                            testSourceRange("test1.tf", 19, 2, 19, 21),
+						"locals.d25":                            testSourceRange("test1.tf", 18, 2, 18, 21),
+						"locals.d26":                            testSourceRange("test1.tf", 17, 2, 17, 21),
+						"locals.d27":                            testSourceRange("test1.tf", 16, 2, 16, 21),
+						"locals.d28":                            testSourceRange("test1.tf", 15, 2, 15, 21),
+						"locals.d29":                            testSourceRange("test1.tf", 14, 2, 14, 21),
+						"locals.d3":                             testSourceRange("test1.tf", 40, 2, 40, 19),
+						"locals.d30":                            testSourceRange("test1.tf", 13, 2, 13, 21),
+						"locals.d31":                            testSourceRange("test1.tf", 12, 2, 12, 21),
+						"locals.d32":                            testSourceRange("test1.tf", 11, 2, 11, 21),
+						"locals.d33":                            testSourceRange("test1.tf", 10, 2, 10, 21),
+						"locals.d34":                            testSourceRange("test1.tf", 9, 2, 9, 21),
+						"locals.d4":                             testSourceRange("test1.tf", 39, 2, 39, 19),
+						"locals.d5":                             testSourceRange("test1.tf", 38, 2, 38, 19),
+						"locals.d6":                             testSourceRange("test1.tf", 37, 2, 37, 19),
+						"locals.d7":                             testSourceRange("test1.tf", 36, 2, 36, 19),
+						"locals.d8":                             testSourceRange("test1.tf", 35, 2, 35, 19),
+						"locals.d9":                             testSourceRange("test1.tf", 34, 2, 34, 19),
+						"resource.aws_security_group.allow_ssh": testSourceRange("test1.tf", 2, 1, 6, 2),
+						"resource.aws_security_group.allow_ssh.cidr_blocks": testSourceRange("test1.tf", 5, 2, 5, 25),
+						"resource.aws_security_group.allow_ssh.description": testSourceRange("test1.tf", 4, 2, 4, 49),
+						"resource.aws_security_group.allow_ssh.name":        testSourceRange("test1.tf", 3, 2, 3, 27),
+					}),
+				},
+				"overrides":            map[string]interface{}{},
+				"redactedPaths":        map[string]interface{}{},
+				"comments":             map[string]interface{}{},
+				"damagedRanges":        map[string]interface{}{},
+				"variableSourceErrors": map[string]interface{}{},
+				"outputs":              map[string]interface{}{},
+				"variableDiagnostics":  "[]",
 			},
 		},
 	}
@@ -717,15 +1062,15 @@ locals {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			if tc.parseErr != nil {
-				oldParseHclToJson := parseHclToJson
+				oldParseHclToDocument := parseHclToDocument
 				defer func() {
-					parseHclToJson = oldParseHclToJson
+					parseHclToDocument = oldParseHclToDocument
 				}()
-				parseHclToJson = func(fileName string, fileContent string, variableMap ModuleVariables) (string, error) {
+				parseHclToDocument = func(fileName string, fileContent string, variableMap ModuleVariables, options Options) (JSON, SourceMap, []*Diagnostic, error) {
 					if fileName == "fail.tf" {
-						return "", tc.parseErr
+						return nil, nil, nil, tc.parseErr
 					}
-					return oldParseHclToJson(fileName, fileContent, variableMap)
+					return oldParseHclToDocument(fileName, fileContent, variableMap, options)
 				}
 			}
 			if tc.extractErr != nil {
@@ -747,13 +1092,81 @@ locals {
 }
 
 func setupFilesystemTests() (map[string]interface{}, map[string]interface{}, error) {
+	testFile := fmt.Sprintf("fixtures%ctest.tf", os.PathSeparator)
+	variablesFile := fmt.Sprintf("fixtures%cvariables.tf", os.PathSeparator)
 	filesystemExpected := map[string]interface{}{
 		"debugLogs":   map[string]interface{}{},
 		"failedFiles": map[string]interface{}{},
 		"parsedFiles": map[string]interface{}{},
+		"diagnostics": map[string]interface{}{},
+		"sourceMaps": map[string]interface{}{
+			testFile: sourceMapJSON(SourceMap{
+				"resource.aws_security_group.allow_ssh":                                      testSourceRange(testFile, 1, 1, 11, 2),
+				"resource.aws_security_group.allow_ssh.description":                          testSourceRange(testFile, 3, 3, 3, 50),
+				"resource.aws_security_group.allow_ssh.ingress":                              testSourceRange(testFile, 5, 3, 10, 4),
+				"resource.aws_security_group.allow_ssh.ingress.cidr_blocks":                  testSourceRange(testFile, 9, 5, 9, 39),
+				"resource.aws_security_group.allow_ssh.ingress.from_port":                    testSourceRange(testFile, 6, 5, 6, 21),
+				"resource.aws_security_group.allow_ssh.ingress.protocol":                     testSourceRange(testFile, 8, 5, 8, 24),
+				"resource.aws_security_group.allow_ssh.ingress.to_port":                      testSourceRange(testFile, 7, 5, 7, 21),
+				"resource.aws_security_group.allow_ssh.name":                                 testSourceRange(testFile, 2, 3, 2, 28),
+				"resource.aws_security_group.allow_ssh.vpc_id":                               testSourceRange(testFile, 4, 3, 4, 37),
+				"resource.aws_security_group.allow_ssh_a_auto_tfvars":                        testSourceRange(testFile, 23, 1, 33, 2),
+				"resource.aws_security_group.allow_ssh_a_auto_tfvars.description":            testSourceRange(testFile, 25, 3, 25, 50),
+				"resource.aws_security_group.allow_ssh_a_auto_tfvars.ingress":                testSourceRange(testFile, 27, 3, 32, 4),
+				"resource.aws_security_group.allow_ssh_a_auto_tfvars.ingress.cidr_blocks":    testSourceRange(testFile, 31, 5, 31, 53),
+				"resource.aws_security_group.allow_ssh_a_auto_tfvars.ingress.from_port":      testSourceRange(testFile, 28, 5, 28, 21),
+				"resource.aws_security_group.allow_ssh_a_auto_tfvars.ingress.protocol":       testSourceRange(testFile, 30, 5, 30, 24),
+				"resource.aws_security_group.allow_ssh_a_auto_tfvars.ingress.to_port":        testSourceRange(testFile, 29, 5, 29, 21),
+				"resource.aws_security_group.allow_ssh_a_auto_tfvars.name":                   testSourceRange(testFile, 24, 3, 24, 28),
+				"resource.aws_security_group.allow_ssh_a_auto_tfvars.vpc_id":                 testSourceRange(testFile, 26, 3, 26, 37),
+				"resource.aws_security_group.allow_ssh_b_auto_tfvars":                        testSourceRange(testFile, 34, 1, 44, 2),
+				"resource.aws_security_group.allow_ssh_b_auto_tfvars.description":            testSourceRange(testFile, 36, 3, 36, 50),
+				"resource.aws_security_group.allow_ssh_b_auto_tfvars.ingress":                testSourceRange(testFile, 38, 3, 43, 4),
+				"resource.aws_security_group.allow_ssh_b_auto_tfvars.ingress.cidr_blocks":    testSourceRange(testFile, 42, 5, 42, 53),
+				"resource.aws_security_group.allow_ssh_b_auto_tfvars.ingress.from_port":      testSourceRange(testFile, 39, 5, 39, 21),
+				"resource.aws_security_group.allow_ssh_b_auto_tfvars.ingress.protocol":       testSourceRange(testFile, 41, 5, 41, 24),
+				"resource.aws_security_group.allow_ssh_b_auto_tfvars.ingress.to_port":        testSourceRange(testFile, 40, 5, 40, 21),
+				"resource.aws_security_group.allow_ssh_b_auto_tfvars.name":                   testSourceRange(testFile, 35, 3, 35, 28),
+				"resource.aws_security_group.allow_ssh_b_auto_tfvars.vpc_id":                 testSourceRange(testFile, 37, 3, 37, 37),
+				"resource.aws_security_group.allow_ssh_terraform_tfvars":                     testSourceRange(testFile, 12, 1, 22, 2),
+				"resource.aws_security_group.allow_ssh_terraform_tfvars.description":         testSourceRange(testFile, 14, 3, 14, 50),
+				"resource.aws_security_group.allow_ssh_terraform_tfvars.ingress":             testSourceRange(testFile, 16, 3, 21, 4),
+				"resource.aws_security_group.allow_ssh_terraform_tfvars.ingress.cidr_blocks": testSourceRange(testFile, 20, 5, 20, 56),
+				"resource.aws_security_group.allow_ssh_terraform_tfvars.ingress.from_port":   testSourceRange(testFile, 17, 5, 17, 21),
+				"resource.aws_security_group.allow_ssh_terraform_tfvars.ingress.protocol":    testSourceRange(testFile, 19, 5, 19, 24),
+				"resource.aws_security_group.allow_ssh_terraform_tfvars.ingress.to_port":     testSourceRange(testFile, 18, 5, 18, 21),
+				"resource.aws_security_group.allow_ssh_terraform_tfvars.name":                testSourceRange(testFile, 13, 3, 13, 28),
+				"resource.aws_security_group.allow_ssh_terraform_tfvars.vpc_id":              testSourceRange(testFile, 15, 3, 15, 37),
+			}),
+			variablesFile: sourceMapJSON(SourceMap{
+				"variable.remote_user_addr":                             testSourceRange(variablesFile, 1, 1, 4, 2),
+				"variable.remote_user_addr.default":                     testSourceRange(variablesFile, 3, 3, 3, 26),
+				"variable.remote_user_addr.default[0]":                  testSourceRange(variablesFile, 3, 14, 3, 25),
+				"variable.remote_user_addr.type":                        testSourceRange(variablesFile, 2, 3, 2, 22),
+				"variable.remote_user_addr_a_auto_tfvars":               testSourceRange(variablesFile, 9, 1, 12, 2),
+				"variable.remote_user_addr_a_auto_tfvars.default":       testSourceRange(variablesFile, 11, 3, 11, 27),
+				"variable.remote_user_addr_a_auto_tfvars.default[0]":    testSourceRange(variablesFile, 11, 14, 11, 26),
+				"variable.remote_user_addr_a_auto_tfvars.type":          testSourceRange(variablesFile, 10, 3, 10, 22),
+				"variable.remote_user_addr_b_auto_tfvars":               testSourceRange(variablesFile, 13, 1, 16, 2),
+				"variable.remote_user_addr_b_auto_tfvars.default":       testSourceRange(variablesFile, 15, 3, 15, 27),
+				"variable.remote_user_addr_b_auto_tfvars.default[0]":    testSourceRange(variablesFile, 15, 14, 15, 26),
+				"variable.remote_user_addr_b_auto_tfvars.type":          testSourceRange(variablesFile, 14, 3, 14, 22),
+				"variable.remote_user_addr_terraform_tfvars":            testSourceRange(variablesFile, 5, 1, 8, 2),
+				"variable.remote_user_addr_terraform_tfvars.default":    testSourceRange(variablesFile, 7, 3, 7, 27),
+				"variable.remote_user_addr_terraform_tfvars.default[0]": testSourceRange(variablesFile, 7, 14, 7, 26),
+				"variable.remote_user_addr_terraform_tfvars.type":       testSourceRange(variablesFile, 6, 3, 6, 22),
+			}),
+		},
+		"overrides":            map[string]interface{}{},
+		"redactedPaths":        map[string]interface{}{},
+		"comments":             map[string]interface{}{},
+		"damagedRanges":        map[string]interface{}{},
+		"variableSourceErrors": map[string]interface{}{},
+		"outputs":              map[string]interface{}{},
+		"variableDiagnostics":  "[]",
 	}
 
-	filesystemExpected["parsedFiles"].(map[string]interface{})[fmt.Sprintf("fixtures%cvariables.tf", os.PathSeparator)] = `{
+	filesystemExpected["parsedFiles"].(map[string]interface{})[variablesFile] = `{
 	"variable": {
 		"remote_user_addr": {
 			"default": [
@@ -781,7 +1194,7 @@ func setupFilesystemTests() (map[string]interface{}, map[string]interface{}, err
 		}
 	}
 }`
-	filesystemExpected["parsedFiles"].(map[string]interface{})[fmt.Sprintf("fixtures%ctest.tf", os.PathSeparator)] = `{
+	filesystemExpected["parsedFiles"].(map[string]interface{})[testFile] = `{
 	"resource": {
 		"aws_security_group": {
 			"allow_ssh": {
@@ -861,3 +1274,1059 @@ func setupFilesystemTests() (map[string]interface{}, map[string]interface{}, err
 
 	return filesystemFiles, filesystemExpected, nil
 }
+
+// sourceMapJSON returns a source map in the JSON format of the sourceMaps returned by ParseModule
+func sourceMapJSON(sourceMap SourceMap) string {
+	jsonBytes, _ := json.MarshalIndent(sourceMap, "", "\t")
+	return string(jsonBytes)
+}
+
+func testSourceRange(fileName string, startLine int, startColumn int, endLine int, endColumn int) SourceRange {
+	return SourceRange{
+		FileName: fileName,
+		Start:    SourcePos{Line: startLine, Column: startColumn},
+		End:      SourcePos{Line: endLine, Column: endColumn},
+	}
+}
+
+func TestParseModuleSourceMaps(t *testing.T) {
+	actual := ParseModule(map[string]interface{}{
+		"main.tf": `
+resource "aws_s3_bucket" "x" {
+	acl = var.acl
+}`,
+		"terraform.tfvars": `acl = "private"`,
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"main.tf": `{
+	"resource.aws_s3_bucket.x": {
+		"fileName": "main.tf",
+		"start": {
+			"line": 2,
+			"column": 1
+		},
+		"end": {
+			"line": 4,
+			"column": 2
+		}
+	},
+	"resource.aws_s3_bucket.x.acl": {
+		"fileName": "main.tf",
+		"start": {
+			"line": 3,
+			"column": 2
+		},
+		"end": {
+			"line": 3,
+			"column": 15
+		}
+	}
+}`,
+	}, actual["sourceMaps"])
+}
+
+func TestParseModuleFiles(t *testing.T) {
+	actual := ParseModuleFiles(map[string][]byte{
+		"main.tf": []byte(`
+resource "aws_security_group" "allow_ssh" {
+	name      = var.name
+	from_port = 22
+	tags      = ["a", "b"]
+}`),
+		"terraform.tfvars": []byte(`name = "allow_ssh"`),
+		"invalid.tf":       []byte(`resource "aws_security_group" "allow_ssh" {`),
+	}, DefaultOptions())
+
+	assert.Equal(t, map[string]JSON{
+		"main.tf": {
+			"resource": map[string]interface{}{
+				"aws_security_group": map[string]interface{}{
+					"allow_ssh": map[string]interface{}{
+						"name":      "allow_ssh",
+						"from_port": json.Number("22"),
+						"tags":      []interface{}{"a", "b"},
+					},
+				},
+			},
+		},
+	}, actual.ParsedFiles)
+
+	assert.Len(t, actual.FailedFiles, 1)
+	assert.Equal(t, "Invalid HCL provided", actual.FailedFiles["invalid.tf"].Error())
+	assert.True(t, errors.Is(actual.FailedFiles["invalid.tf"], ErrInvalidHCL))
+	assert.Contains(t, actual.DebugLogs["invalid.tf"], "Argument or block definition required")
+	require.Len(t, actual.Diagnostics["invalid.tf"], 1)
+	assert.Equal(t, INVALID_HCL_ERROR, actual.Diagnostics["invalid.tf"][0].Code)
+	assert.Equal(t, "Argument or block definition required", actual.Diagnostics["invalid.tf"][0].Summary)
+	assert.Equal(t, "invalid.tf", actual.Diagnostics["invalid.tf"][0].Range.FileName)
+	assert.Contains(t, actual.SourceMaps["main.tf"], "resource.aws_security_group.allow_ssh.from_port")
+}
+
+func TestParseModuleFilesRecoversFromErrors(t *testing.T) {
+	files := map[string][]byte{
+		"main.tf": []byte(`
+variable "name" {
+	default = "logs"
+}
+
+resource "aws_s3_bucket" "broken" {
+	acl = 
+}
+
+locals {
+	bucket = "${var.name}-bucket"
+}
+
+resource "aws_s3_bucket" "logs" {
+	bucket = local.bucket
+}`),
+	}
+
+	actual := ParseModuleFiles(files, DefaultOptions())
+	assert.Empty(t, actual.ParsedFiles)
+	assert.True(t, errors.Is(actual.FailedFiles["main.tf"], ErrInvalidHCL))
+
+	options := DefaultOptions()
+	options.RecoverFromErrors = true
+	actual = ParseModuleFiles(files, options)
+
+	assert.Empty(t, actual.FailedFiles)
+	assert.Equal(t, JSON{
+		"variable": map[string]interface{}{
+			"name": map[string]interface{}{
+				"default": "logs",
+			},
+		},
+		"locals": map[string]interface{}{
+			"bucket": "logs-bucket",
+		},
+		"resource": map[string]interface{}{
+			"aws_s3_bucket": map[string]interface{}{
+				"logs": map[string]interface{}{
+					"bucket": "logs-bucket",
+				},
+			},
+		},
+	}, actual.ParsedFiles["main.tf"])
+	assert.Equal(t, map[string][]SourceRange{
+		"main.tf": {
+			{
+				FileName: "main.tf",
+				Start:    SourcePos{Line: 6, Column: 1},
+				End:      SourcePos{Line: 8, Column: 2},
+			},
+		},
+	}, actual.DamagedRanges)
+	assert.Contains(t, actual.DebugLogs["main.tf"], "Invalid expression")
+}
+
+func TestParseModuleFS(t *testing.T) {
+	actual, err := ParseModuleFS(fstest.MapFS{
+		"main.tf":                {Data: []byte(`locals { name = var.name }`)},
+		"variables.auto.tfvars":  {Data: []byte(`name = "test"`)},
+		"README.md":              {Data: []byte(`# Not Terraform`)},
+		"modules/child/child.tf": {Data: []byte(`locals { nested = true }`)},
+	}, DefaultOptions())
+	assert.Nil(t, err)
+
+	assert.Equal(t, map[string]JSON{
+		"main.tf": {
+			"locals": map[string]interface{}{
+				"name": "test",
+			},
+		},
+	}, actual.ParsedFiles)
+	assert.Empty(t, actual.FailedFiles)
+}
+
+func TestParseModuleWithJsonFiles(t *testing.T) {
+	actual := ParseModuleFiles(map[string][]byte{
+		"main.tf.json": []byte(`{
+	"resource": {
+		"aws_s3_bucket": {
+			"logs": {
+				"//": "buckets are private by default",
+				"bucket": "${var.prefix}-logs",
+				"acl": "${var.acl}",
+				"versioning": [
+					{"enabled": true},
+					{"enabled": false}
+				]
+			}
+		}
+	},
+	"variable": {
+		"prefix": {
+			"default": "default"
+		},
+		"acl": {
+			"default": "private"
+		}
+	}
+}`),
+		"terraform.tfvars":      []byte(`prefix = "tfvars"`),
+		"terraform.tfvars.json": []byte(`{"prefix": "tfvars-json", "acl": "${literal}"}`),
+		"a.auto.tfvars.json":    []byte(`{"prefix": "auto-json"}`),
+		"invalid.tf.json":       []byte(`{"resource": `),
+	}, DefaultOptions())
+
+	assert.Equal(t, map[string]JSON{
+		"main.tf.json": {
+			"resource": map[string]interface{}{
+				"aws_s3_bucket": map[string]interface{}{
+					"logs": map[string]interface{}{
+						"bucket": "auto-json-logs",
+						"acl":    "${literal}",
+						"versioning": []interface{}{
+							map[string]interface{}{"enabled": true},
+							map[string]interface{}{"enabled": false},
+						},
+					},
+				},
+			},
+			"variable": map[string]interface{}{
+				"prefix": map[string]interface{}{
+					"default": "default",
+				},
+				"acl": map[string]interface{}{
+					"default": "private",
+				},
+			},
+		},
+	}, actual.ParsedFiles)
+	assert.Equal(t, "Invalid HCL provided", actual.FailedFiles["invalid.tf.json"].Error())
+	// the JSON syntax counts a tab as two columns, the same way as the diagnostics of Terraform
+	assert.Equal(t, SourceRange{
+		FileName: "main.tf.json",
+		Start:    SourcePos{Line: 6, Column: 19},
+		End:      SourcePos{Line: 6, Column: 39},
+	}, actual.SourceMaps["main.tf.json"]["resource.aws_s3_bucket.logs.bucket"])
+	assert.Contains(t, actual.SourceMaps["main.tf.json"], "resource.aws_s3_bucket.logs.versioning[1].enabled")
+	assert.NotContains(t, actual.SourceMaps["main.tf.json"], "resource.aws_s3_bucket.logs.//")
+}
+
+func TestParseModuleWithJsonFilesDeclaringLabelsInArrays(t *testing.T) {
+	actual := ParseModuleFiles(map[string][]byte{
+		"main.tf.json": []byte(`{
+  "resource": [
+    {"aws_s3_bucket": {"logs": {"bucket": "logs"}}},
+    {"aws_s3_bucket": [{"data": {"bucket": "data"}}]}
+  ],
+  "provider": {
+    "aws": [
+      {"region": "us-east-1"},
+      {"region": "eu-west-1", "alias": "eu"}
+    ]
+  }
+}`),
+		"invalid.tf.json": []byte(`{"resource": {"aws_s3_bucket": ["logs"]}}`),
+	}, DefaultOptions())
+
+	assert.Equal(t, map[string]JSON{
+		"main.tf.json": {
+			"resource": map[string]interface{}{
+				"aws_s3_bucket": map[string]interface{}{
+					"logs": map[string]interface{}{"bucket": "logs"},
+					"data": map[string]interface{}{"bucket": "data"},
+				},
+			},
+			"provider": map[string]interface{}{
+				"aws": []interface{}{
+					map[string]interface{}{"region": "us-east-1"},
+					map[string]interface{}{"region": "eu-west-1", "alias": "eu"},
+				},
+			},
+		},
+	}, actual.ParsedFiles)
+	require.Contains(t, actual.FailedFiles, "invalid.tf.json")
+	assert.True(t, errors.Is(actual.FailedFiles["invalid.tf.json"], ErrInvalidHCL))
+	require.NotEmpty(t, actual.Diagnostics["invalid.tf.json"])
+	assert.Equal(t, "Incorrect JSON value type", actual.Diagnostics["invalid.tf.json"][0].Summary)
+}
+
+func TestParseModuleWithEquivalentJsonFiles(t *testing.T) {
+	nativeFiles := map[string][]byte{
+		"main.tf": []byte(`variable "ports" {
+	type    = list(number)
+	default = [22, 443]
+}
+variable "zones" {
+	type    = set(string)
+	default = ["a", "b"]
+}
+resource "aws_subnet" "this" {
+	for_each          = var.zones
+	availability_zone = "eu-west-1${each.key}"
+	depends_on        = [aws_vpc.this]
+}
+resource "aws_instance" "web" {
+	count = 2
+	ami   = "ami-${count.index}"
+	tags = {
+		Name = "web"
+	}
+}
+resource "aws_security_group" "sg" {
+	vpc_id = aws_vpc.this.id
+	dynamic "ingress" {
+		for_each = var.ports
+		iterator = port
+		content {
+			from_port = port.value
+			to_port   = port.value
+		}
+	}
+	egress {
+		from_port = 0
+	}
+	egress {
+		from_port = 1
+	}
+}`),
+	}
+	jsonFiles := map[string][]byte{
+		"main.tf.json": []byte(`{
+	"variable": {
+		"ports": {
+			"type": "list(number)",
+			"default": [22, 443]
+		},
+		"zones": {
+			"type": "set(string)",
+			"default": ["a", "b"]
+		}
+	},
+	"resource": {
+		"aws_subnet": {
+			"this": {
+				"for_each": "${var.zones}",
+				"availability_zone": "eu-west-1${each.key}",
+				"depends_on": ["aws_vpc.this"]
+			}
+		},
+		"aws_instance": {
+			"web": {
+				"count": 2,
+				"ami": "ami-${count.index}",
+				"tags": {
+					"Name": "web"
+				}
+			}
+		},
+		"aws_security_group": {
+			"sg": {
+				"vpc_id": "${aws_vpc.this.id}",
+				"dynamic": {
+					"ingress": {
+						"for_each": "${var.ports}",
+						"iterator": "port",
+						"content": {
+							"from_port": "${port.value}",
+							"to_port": "${port.value}"
+						}
+					}
+				},
+				"egress": [
+					{"from_port": 0},
+					{"from_port": 1}
+				]
+			}
+		}
+	}
+}`),
+	}
+
+	for _, options := range []Options{DefaultOptions(), {Simplify: true, ExpandCountAndForEach: true}, {Simplify: true, UnknownMarkers: true}} {
+		nativeResult := ParseModuleFiles(nativeFiles, options)
+		jsonResult := ParseModuleFiles(jsonFiles, options)
+		require.Empty(t, nativeResult.DebugLogs)
+		require.Empty(t, jsonResult.DebugLogs)
+		assert.Equal(t, nativeResult.ParsedFiles["main.tf"], jsonResult.ParsedFiles["main.tf.json"])
+	}
+}
+
+func TestParseModuleWithOverrides(t *testing.T) {
+	actual := ParseModuleFiles(map[string][]byte{
+		"main.tf": []byte(`resource "aws_s3_bucket" "logs" {
+	bucket = local.name
+	acl    = "public-read"
+	lifecycle {
+		prevent_destroy       = true
+		create_before_destroy = true
+	}
+}
+locals {
+	name = "logs"
+}
+variable "region" {
+	default = "eu-west-1"
+}
+provider "aws" {
+	region = var.region
+}`),
+		"override.tf": []byte(`resource "aws_s3_bucket" "logs" {
+	acl = "private"
+	lifecycle {
+		prevent_destroy = false
+	}
+}
+locals {
+	name = "private-logs"
+}
+variable "region" {
+	default = "us-east-1"
+}`),
+		"missing_override.tf.json": []byte(`{"resource": {"aws_s3_bucket": {"missing": {"acl": "private"}}}}`),
+	}, DefaultOptions())
+
+	assert.Equal(t, map[string]JSON{
+		"main.tf": {
+			"resource": map[string]interface{}{
+				"aws_s3_bucket": map[string]interface{}{
+					"logs": map[string]interface{}{
+						"bucket": "private-logs",
+						"acl":    "private",
+						"lifecycle": map[string]interface{}{
+							"prevent_destroy":       false,
+							"create_before_destroy": true,
+						},
+					},
+				},
+			},
+			"locals": map[string]interface{}{
+				"name": "private-logs",
+			},
+			"variable": map[string]interface{}{
+				"region": map[string]interface{}{
+					"default": "us-east-1",
+				},
+			},
+			"provider": map[string]interface{}{
+				"aws": map[string]interface{}{
+					"region": "us-east-1",
+				},
+			},
+		},
+	}, actual.ParsedFiles)
+	assert.Equal(t, map[string]map[string]string{
+		"main.tf": {
+			"resource.aws_s3_bucket.logs.acl":                       "override.tf",
+			"resource.aws_s3_bucket.logs.lifecycle.prevent_destroy": "override.tf",
+			"locals.name":             "override.tf",
+			"variable.region.default": "override.tf",
+		},
+	}, actual.Overrides)
+	assert.Equal(t, SourceRange{
+		FileName: "override.tf",
+		Start:    SourcePos{Line: 2, Column: 2},
+		End:      SourcePos{Line: 2, Column: 17},
+	}, actual.SourceMaps["main.tf"]["resource.aws_s3_bucket.logs.acl"])
+	assert.NotContains(t, actual.SourceMaps, "override.tf")
+	assert.Contains(t, actual.DebugLogs["missing_override.tf.json"], "Missing base resource.aws_s3_bucket.missing for override")
+}
+
+func TestParseModuleWithVariableSources(t *testing.T) {
+	actual := ParseModule(map[string]interface{}{
+		"main.tf": `variable "region" {
+	default = "default"
+}
+variable "zones" {
+	type = list(string)
+}
+variable "env" {}
+provider "aws" {
+	region = var.region
+	zones  = var.zones
+	env    = var.env
+}`,
+		"terraform.tfvars": `env = "tfvars"`,
+	},
+		map[string]interface{}{"type": "env", "environment": map[string]interface{}{"TF_VAR_env": "env", "TF_VAR_region": "env", "TF_VAR_undeclared": "env"}},
+		map[string]interface{}{"type": "varFile", "fileName": "prod.tfvars.json", "fileContent": `{"region": "var-file", "zones": ["a"]}`},
+		map[string]interface{}{"type": "var", "value": `zones=["b", "c"]`},
+		map[string]interface{}{"type": "var", "value": "invalid"},
+	)
+
+	assert.Equal(t, `{
+	"provider": {
+		"aws": {
+			"env": "tfvars",
+			"region": "var-file",
+			"zones": [
+				"b",
+				"c"
+			]
+		}
+	},
+	"variable": {
+		"env": {},
+		"region": {
+			"default": "default"
+		},
+		"zones": {
+			"type": "${list(string)}"
+		}
+	}
+}`, actual["parsedFiles"].(map[string]interface{})["main.tf"])
+	assert.Empty(t, actual["failedFiles"])
+	assert.Empty(t, actual["debugLogs"])
+	variableSourceErrors := actual["variableSourceErrors"].(map[string]interface{})
+	assert.Len(t, variableSourceErrors, 1)
+	var variableSourceError JSON
+	assert.Nil(t, json.Unmarshal([]byte(variableSourceErrors[`-var "invalid"`].(string)), &variableSourceError))
+	assert.Equal(t, "Invalid variable value provided", variableSourceError["message"])
+	assert.Equal(t, string(INVALID_VARIABLE_ERROR), variableSourceError["code"])
+}
+
+func TestParseModuleReportsUndeclaredVariableSources(t *testing.T) {
+	options := DefaultOptions()
+	options.VariableSources = []VariableSource{
+		{Type: ENV_SOURCE, Environment: map[string]string{"TF_VAR_region": "env", "TF_VAR_undeclared_env": "env", "HOME": "/root"}},
+		{Type: VAR_FILE_SOURCE, FileName: "prod.tfvars", FileContent: []byte(`region = "var-file"
+undeclared_file = "var-file"`)},
+		{Type: VAR_SOURCE, Value: "undeclared_var=var"},
+	}
+	actual := ParseModuleFiles(map[string][]byte{
+		"main.tf": []byte(`variable "region" {}
+provider "aws" {
+	region = var.region
+	file   = var.undeclared_file
+}`),
+	}, options)
+
+	assert.Equal(t, JSON{
+		"aws": map[string]interface{}{
+			"region": "var-file",
+			"file":   "${var.undeclared_file}",
+		},
+	}, actual.ParsedFiles["main.tf"]["provider"])
+
+	require.Len(t, actual.VariableSourceErrors, 1)
+	err := actual.VariableSourceErrors[`-var "undeclared_var"`]
+	assert.True(t, errors.Is(err, ErrInvalidVariable))
+	var diagnostic *Diagnostic
+	require.True(t, errors.As(err, &diagnostic))
+	assert.Equal(t, "Value for undeclared variable", diagnostic.Summary)
+
+	assert.Equal(t, []VariableDiagnostic{
+		{
+			Variable: "undeclared_env",
+			Summary:  "Value for undeclared variable",
+			Detail:   `The root module does not declare a variable named "undeclared_env" but a value was found in the environment variable TF_VAR_undeclared_env. If you meant to use this value, add a "variable" block to the configuration.`,
+		},
+		{
+			Variable: "undeclared_file",
+			Summary:  "Value for undeclared variable",
+			Detail:   `The root module does not declare a variable named "undeclared_file" but a value was found in file "prod.tfvars". If you meant to use this value, add a "variable" block to the configuration.`,
+			Range: &SourceRange{
+				FileName: "prod.tfvars",
+				Start:    SourcePos{Line: 2, Column: 1},
+				End:      SourcePos{Line: 2, Column: 16},
+			},
+		},
+	}, actual.VariableDiagnostics)
+}
+
+func TestParseModuleConvertsVariables(t *testing.T) {
+	actual := ParseModuleFiles(map[string][]byte{
+		"main.tf": []byte(`variable "port" {
+	type = number
+}
+variable "listener" {
+	type = object({
+		protocol = optional(string, "HTTPS")
+		port     = number
+	})
+	default = {
+		port = "443"
+	}
+}
+variable "zones" {
+	type = list(string)
+}
+variable "invalid" {
+	type = text
+}
+resource "aws_lb_listener" "web" {
+	port     = var.port
+	protocol = var.listener.protocol
+	zones    = var.zones
+}`),
+		"terraform.tfvars": []byte(`port = "8080"
+zones = { a = "b" }
+invalid = "invalid"`),
+	}, DefaultOptions())
+
+	assert.Equal(t, JSON{
+		"aws_lb_listener": map[string]interface{}{
+			"web": map[string]interface{}{
+				"port":     json.Number("8080"),
+				"protocol": "HTTPS",
+				"zones":    "${var.zones}",
+			},
+		},
+	}, actual.ParsedFiles["main.tf"]["resource"])
+	assert.Equal(t, []VariableDiagnostic{
+		{
+			Variable: "invalid",
+			Summary:  "Invalid type specification",
+			Detail:   `The keyword "text" is not a valid type specification.`,
+			Range: &SourceRange{
+				FileName: "main.tf",
+				Start:    SourcePos{Line: 17, Column: 9},
+				End:      SourcePos{Line: 17, Column: 13},
+			},
+		},
+		{
+			Variable: "zones",
+			Summary:  "Invalid value for input variable",
+			Detail:   "The given value is not suitable for var.zones: list of string required.",
+			Range: &SourceRange{
+				FileName: "main.tf",
+				Start:    SourcePos{Line: 13, Column: 1},
+				End:      SourcePos{Line: 13, Column: 17},
+			},
+		},
+	}, actual.VariableDiagnostics)
+}
+
+func TestParseModuleValidatesVariables(t *testing.T) {
+	files := map[string][]byte{
+		"main.tf": []byte(`variable "port" {
+	type = number
+	validation {
+		condition     = var.port > 1024
+		error_message = "The port must be unprivileged, got ${var.port}."
+	}
+	validation {
+		condition     = var.port < 65536
+		error_message = "The port must be valid."
+	}
+}
+variable "region" {
+	validation {
+		condition     = contains(var.region, "eu")
+		error_message = "The region must be in Europe."
+	}
+}`),
+		"terraform.tfvars": []byte(`port = 80
+region = "us-east-1"`),
+	}
+
+	actual := ParseModuleFiles(files, DefaultOptions())
+	assert.Empty(t, actual.VariableDiagnostics)
+
+	options := DefaultOptions()
+	options.ValidateVariables = true
+	actual = ParseModuleFiles(files, options)
+	assert.Equal(t, 2, len(actual.VariableDiagnostics))
+	assert.Equal(t, VariableDiagnostic{
+		Variable:     "port",
+		Summary:      "Invalid value for variable",
+		Detail:       "The port must be unprivileged, got 80.",
+		ErrorMessage: "The port must be unprivileged, got 80.",
+		Range: &SourceRange{
+			FileName: "main.tf",
+			Start:    SourcePos{Line: 4, Column: 19},
+			End:      SourcePos{Line: 4, Column: 34},
+		},
+	}, actual.VariableDiagnostics[0])
+	assert.Equal(t, "region", actual.VariableDiagnostics[1].Variable)
+	assert.Equal(t, "Unable to evaluate validation condition", actual.VariableDiagnostics[1].Summary)
+	assert.Equal(t, "The region must be in Europe.", actual.VariableDiagnostics[1].ErrorMessage)
+}
+
+func TestParseModuleValidatesSensitiveVariables(t *testing.T) {
+	files := map[string][]byte{
+		"main.tf": []byte(`variable "password" {
+	type      = string
+	sensitive = true
+	validation {
+		condition     = length(var.password) > 8
+		error_message = "Password ${var.password} is too short."
+	}
+}`),
+		"terraform.tfvars": []byte(`password = "hunter2"`),
+	}
+
+	options := DefaultOptions()
+	options.ValidateVariables = true
+	actual := ParseModuleFiles(files, options)
+	require.Equal(t, 1, len(actual.VariableDiagnostics))
+	assert.Equal(t, "Invalid value for variable", actual.VariableDiagnostics[0].Summary)
+	assert.Equal(t, `"Password ${var.password} is too short."`, actual.VariableDiagnostics[0].Detail)
+	assert.Equal(t, `"Password ${var.password} is too short."`, actual.VariableDiagnostics[0].ErrorMessage)
+}
+
+func TestParseModuleResolvesReferences(t *testing.T) {
+	files := map[string][]byte{
+		"main.tf": []byte(`
+resource "aws_s3_bucket" "logs" {
+	bucket = "${var.prefix}-logs"
+	versioning {
+		enabled = true
+	}
+}
+
+resource "aws_s3_bucket_server_side_encryption_configuration" "logs" {
+	bucket     = aws_s3_bucket.logs.bucket
+	bucket_id  = aws_s3_bucket.logs.id
+	versioned  = aws_s3_bucket.logs.versioning[0].enabled
+	kms_key_id = aws_kms_key.main.arn
+	key_alias  = aws_kms_key.main.alias
+	ami        = data.aws_ami.ubuntu.name
+	web        = aws_instance.web[1].name
+	role       = aws_iam_role.external.arn
+}`),
+		"kms.tf": []byte(`
+resource "aws_kms_key" "main" {
+	alias = aws_kms_alias.main.name
+}
+
+resource "aws_kms_alias" "main" {
+	name = aws_kms_key.main.alias
+}
+
+resource "aws_instance" "web" {
+	count = 2
+	name  = "web-${count.index}"
+}
+
+data "aws_ami" "ubuntu" {
+	name = var.ami
+}
+
+variable "prefix" {
+	default = "acme"
+}`),
+	}
+
+	options := DefaultOptions()
+	options.ExpandCountAndForEach = true
+	actual := ParseModuleFiles(files, options)
+	assert.Equal(t, "${aws_s3_bucket.logs.id}", actual.ParsedFiles["main.tf"]["resource"].(JSON)["aws_s3_bucket_server_side_encryption_configuration"].(JSON)["logs"].(JSON)["bucket_id"])
+
+	options.ResolveReferences = true
+	actual = ParseModuleFiles(files, options)
+	assert.Empty(t, actual.FailedFiles)
+
+	typedReference := func(address string, attribute string, expression string) JSON {
+		return JSON{
+			REFERENCE_KEY: map[string]interface{}{
+				"address":    address,
+				"attribute":  attribute,
+				"expression": expression,
+			},
+		}
+	}
+	assert.Equal(t, JSON{
+		"bucket":     "acme-logs",
+		"bucket_id":  typedReference("aws_s3_bucket.logs", "id", "aws_s3_bucket.logs.id"),
+		"versioned":  true,
+		"kms_key_id": typedReference("aws_kms_key.main", "arn", "aws_kms_key.main.arn"),
+		"key_alias":  typedReference("aws_kms_key.main", "alias", "aws_kms_key.main.alias"),
+		"ami":        typedReference("data.aws_ami.ubuntu", "name", "data.aws_ami.ubuntu.name"),
+		"web":        "web-1",
+		"role":       "${aws_iam_role.external.arn}",
+	}, actual.ParsedFiles["main.tf"]["resource"].(JSON)["aws_s3_bucket_server_side_encryption_configuration"].(JSON)["logs"])
+
+	// the structured unknown markers are resolved the same way
+	options.UnknownMarkers = true
+	actual = ParseModuleFiles(files, options)
+	resolved := actual.ParsedFiles["main.tf"]["resource"].(JSON)["aws_s3_bucket_server_side_encryption_configuration"].(JSON)["logs"].(JSON)
+	assert.Equal(t, typedReference("aws_s3_bucket.logs", "id", "aws_s3_bucket.logs.id"), resolved["bucket_id"])
+	assert.Equal(t, "web-1", resolved["web"])
+	assert.Equal(t, "aws_iam_role.external.arn", resolved["role"].(JSON)[UNKNOWN_VALUE_KEY].(JSON)["expression"])
+}
+
+func TestParseModuleResolvesChainsOfReferences(t *testing.T) {
+	// each attribute refers several times to the attribute of the previous resource, which would be resolved
+	// an exponential number of times if the resolved references were not cached
+	var content strings.Builder
+	content.WriteString(`resource "null_resource" "r0" {
+	value = "start"
+}
+`)
+	for i := 1; i <= 40; i++ {
+		previous := fmt.Sprintf("null_resource.r%d.value", i-1)
+		fmt.Fprintf(&content, `resource "null_resource" "r%d" {
+	value = %s
+	list  = [%s, %s, %s]
+}
+`, i, previous, previous, previous, previous)
+	}
+	content.WriteString(`resource "null_resource" "a" {
+	value = null_resource.b.value
+}
+resource "null_resource" "b" {
+	value = null_resource.a.value
+}
+resource "null_resource" "c" {
+	value = null_resource.a.value
+}
+`)
+
+	options := DefaultOptions()
+	options.ResolveReferences = true
+	actual := ParseModuleFiles(map[string][]byte{"main.tf": []byte(content.String())}, options)
+	require.Empty(t, actual.FailedFiles)
+
+	resources := actual.ParsedFiles["main.tf"]["resource"].(JSON)["null_resource"].(JSON)
+	assert.Equal(t, "start", resources["r40"].(JSON)["value"])
+	assert.Equal(t, []interface{}{"start", "start", "start"}, resources["r40"].(JSON)["list"])
+
+	// the references which refer to each other resolve to a typed reference to themselves
+	typedReference := func(address string) JSON {
+		return JSON{
+			REFERENCE_KEY: JSON{
+				"address":    address,
+				"attribute":  "value",
+				"expression": address + ".value",
+			},
+		}
+	}
+	assert.Equal(t, typedReference("null_resource.b"), resources["a"].(JSON)["value"])
+	assert.Equal(t, typedReference("null_resource.a"), resources["b"].(JSON)["value"])
+	assert.Equal(t, typedReference("null_resource.a"), resources["c"].(JSON)["value"])
+}
+
+func TestParseModuleWithOutputs(t *testing.T) {
+	files := map[string]interface{}{
+		"main.tf": `variable "password" {
+	default = "secret"
+}
+locals {
+	endpoint = "db.${var.domain}"
+}
+variable "domain" {
+	default = "example.com"
+}
+resource "aws_db_instance" "db" {
+	password = var.password
+}
+output "endpoint" {
+	value       = local.endpoint
+	description = "The endpoint of the database"
+}
+output "password" {
+	value     = var.password
+	sensitive = true
+}
+output "arn" {
+	value = aws_db_instance.db.arn
+}`,
+		"outputs_override.tf": `output "arn" {
+	sensitive = "true"
+}`,
+	}
+
+	actual := ParseModule(files)
+	assert.Equal(t, map[string]interface{}{
+		"arn": `{
+	"value": "${aws_db_instance.db.arn}",
+	"sensitive": true,
+	"fileName": "main.tf"
+}`,
+		"endpoint": `{
+	"value": "db.example.com",
+	"sensitive": false,
+	"description": "The endpoint of the database",
+	"fileName": "main.tf"
+}`,
+		"password": `{
+	"value": "secret",
+	"sensitive": true,
+	"fileName": "main.tf"
+}`,
+	}, actual["outputs"])
+
+	actual = ParseModule(map[string]interface{}{"main.tf": `locals {}`})
+	assert.Equal(t, map[string]interface{}{}, actual["outputs"])
+}
+
+func TestParseModuleRedactsSensitiveVariables(t *testing.T) {
+	actual := ParseModule(map[string]interface{}{
+		"main.tf": `variable "password" {
+	type      = string
+	sensitive = true
+}
+variable "settings" {
+	type = object({ name = string, token = string })
+}
+variable "username" {
+	default = "admin"
+}
+locals {
+	connection = "${var.username}:${var.password}@db"
+	token      = sensitive(var.settings.token)
+}
+resource "aws_db_instance" "db" {
+	username = var.username
+	password = var.password
+	settings = {
+		name  = var.settings.name
+		token = local.token
+		hash  = md5(var.password)
+	}
+	tokens = [local.token, "public"]
+	plain  = nonsensitive(var.password)
+}
+output "connection" {
+	value = local.connection
+}`,
+		"terraform.tfvars": `password = "hunter2"
+settings = { name = "db", token = "secret" }`,
+	})
+
+	assert.Equal(t, `{
+	"locals": {
+		"connection": {
+			"__redacted__": "sensitive"
+		},
+		"token": {
+			"__redacted__": "sensitive"
+		}
+	},
+	"output": {
+		"connection": {
+			"value": {
+				"__redacted__": "sensitive"
+			}
+		}
+	},
+	"resource": {
+		"aws_db_instance": {
+			"db": {
+				"password": {
+					"__redacted__": "sensitive"
+				},
+				"plain": "hunter2",
+				"settings": {
+					"hash": {
+						"__redacted__": "sensitive"
+					},
+					"name": "db",
+					"token": {
+						"__redacted__": "sensitive"
+					}
+				},
+				"tokens": [
+					{
+						"__redacted__": "sensitive"
+					},
+					"public"
+				],
+				"username": "admin"
+			}
+		}
+	},
+	"variable": {
+		"password": {
+			"sensitive": true,
+			"type": "${string}"
+		},
+		"settings": {
+			"type": "${object({ name = string, token = string })}"
+		},
+		"username": {
+			"default": "admin"
+		}
+	}
+}`, actual["parsedFiles"].(map[string]interface{})["main.tf"])
+	assert.Equal(t, `[
+	"locals.connection",
+	"locals.token",
+	"output.connection.value",
+	"resource.aws_db_instance.db.password",
+	"resource.aws_db_instance.db.settings.hash",
+	"resource.aws_db_instance.db.settings.token",
+	"resource.aws_db_instance.db.tokens[0]"
+]`, actual["redactedPaths"].(map[string]interface{})["main.tf"])
+	assert.NotContains(t, actual["outputs"].(map[string]interface{})["connection"], "hunter2")
+}
+
+func TestParseModuleRedactsSensitiveVariableDefaults(t *testing.T) {
+	files := map[string][]byte{
+		"main.tf": []byte(`variable "password" {
+	sensitive = true
+	default   = "hunter2"
+}
+variable "username" {
+	default = "admin"
+}
+resource "aws_db_instance" "db" {
+	guess = var.password == "hunter2" ? "yes" : "no"
+	names = [for name in ["a", "b"] : var.password == name ? name : "none"]
+	user  = var.username == "admin" ? "yes" : "no"
+}`),
+	}
+
+	actual := ParseModuleFiles(files, DefaultOptions())
+	require.Empty(t, actual.FailedFiles)
+
+	redacted := JSON{REDACTED_VALUE_KEY: SENSITIVE_REDACTION_REASON}
+	// the default of a sensitive variable is redacted from the variable block itself
+	assert.Equal(t, JSON{
+		"default":   redacted,
+		"sensitive": true,
+	}, actual.ParsedFiles["main.tf"]["variable"].(JSON)["password"])
+	assert.Equal(t, "admin", actual.ParsedFiles["main.tf"]["variable"].(JSON)["username"].(JSON)["default"])
+
+	// the branch picked by a condition on a sensitive value is redacted, as it would disclose the value
+	db := actual.ParsedFiles["main.tf"]["resource"].(JSON)["aws_db_instance"].(JSON)["db"].(JSON)
+	assert.Equal(t, redacted, db["guess"])
+	assert.Equal(t, redacted, db["names"])
+	assert.Equal(t, "yes", db["user"])
+
+	assert.Equal(t, []string{
+		"resource.aws_db_instance.db.guess",
+		"resource.aws_db_instance.db.names",
+		"variable.password.default",
+	}, actual.RedactedPaths["main.tf"])
+}
+
+func TestParseModuleDoesNotExpandTooManyInstances(t *testing.T) {
+	oldMaxResourceInstances := maxResourceInstances
+	defer func() {
+		maxResourceInstances = oldMaxResourceInstances
+	}()
+	maxResourceInstances = 2
+
+	options := DefaultOptions()
+	options.ExpandCountAndForEach = true
+	actual := ParseModuleFiles(map[string][]byte{
+		"main.tf": []byte(`resource "aws_instance" "web" {
+	count = 3
+}
+
+resource "aws_s3_bucket" "buckets" {
+	for_each = toset(["a", "b", "c"])
+	bucket   = each.key
+}
+
+resource "aws_instance" "db" {
+	count = 2
+}`),
+	}, options)
+
+	assert.Empty(t, actual.FailedFiles)
+	resources := actual.ParsedFiles["main.tf"]["resource"].(JSON)
+	assert.Equal(t, JSON{
+		"web":     map[string]interface{}{"count": json.Number("3")},
+		`db["0"]`: map[string]interface{}{},
+		`db["1"]`: map[string]interface{}{},
+	}, resources["aws_instance"])
+	assert.Contains(t, resources["aws_s3_bucket"], "buckets")
+
+	require.Len(t, actual.Diagnostics["main.tf"], 2)
+	for _, diagnostic := range actual.Diagnostics["main.tf"] {
+		assert.Equal(t, WARNING_SEVERITY, diagnostic.Severity)
+		assert.Equal(t, TOO_MANY_INSTANCES_WARNING, diagnostic.Code)
+	}
+	assert.Equal(t, &SourceRange{
+		FileName: "main.tf",
+		Start:    SourcePos{Line: 2, Column: 10},
+		End:      SourcePos{Line: 2, Column: 11},
+	}, actual.Diagnostics["main.tf"][0].Range)
+}
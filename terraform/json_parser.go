@@ -0,0 +1,222 @@
+package terraform
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Logic inspired from https://github.com/hashicorp/terraform/blob/v1.0.0/website/docs/language/syntax/json.html.md
+
+// jsonFileSchema holds the top-level blocks of the JSON syntax of Terraform alongside their labels,
+// e.g. {"resource": {"aws_s3_bucket": {"logs": {...}}}} is a resource block with two labels
+var jsonFileSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "resource", LabelNames: []string{"type", "name"}},
+		{Type: "data", LabelNames: []string{"type", "name"}},
+		{Type: "module", LabelNames: []string{"name"}},
+		{Type: "provider", LabelNames: []string{"name"}},
+		{Type: "variable", LabelNames: []string{"name"}},
+		{Type: "output", LabelNames: []string{"name"}},
+		{Type: "check", LabelNames: []string{"name"}},
+		{Type: "locals"},
+		{Type: "terraform"},
+		{Type: "moved"},
+		{Type: "import"},
+	},
+}
+
+// jsonDynamicBlockSchema holds the dynamic blocks of the blocks which can generate nested blocks
+var jsonDynamicBlockSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "dynamic", LabelNames: []string{"name"}},
+	},
+}
+
+// jsonDynamicContentSchema holds the content block of a dynamic block
+var jsonDynamicContentSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "content"},
+	},
+}
+
+// jsonDynamicBlockTypes are the blocks which can hold dynamic blocks, including the content of a dynamic block
+// The other objects of a block are attribute values, which are emitted the same way as the nested blocks they declare
+var jsonDynamicBlockTypes = map[string]bool{
+	"resource": true,
+	"data":     true,
+	"provider": true,
+	"content":  true,
+}
+
+// jsonBodyConverter converts the body of a file written in the JSON syntax of Terraform into the syntax tree of the native syntax,
+// so that it is parsed the same way, e.g. expanding count, for_each and dynamic blocks
+// The strings of the file are parsed as templates, or as expressions for the arguments which are keywords or references,
+// e.g. the type of a variable
+type jsonBodyConverter struct {
+	fileName string
+	// source holds the content of the file followed by its strings without their escape sequences, which the parser reads
+	// the source of the expressions from, so the byte offsets of the values point into the file and the ones of the
+	// expressions point into their string, while the lines and columns of both point into the file
+	source []byte
+}
+
+// parseJsonBody converts a file written in the JSON syntax of Terraform into a native body,
+// and returns the source the byte offsets of its expressions point into
+func parseJsonBody(fileName string, file *hcl.File) (*hclsyntax.Body, []byte, hcl.Diagnostics) {
+	converter := &jsonBodyConverter{
+		fileName: fileName,
+		source:   append([]byte{}, file.Bytes...),
+	}
+
+	body, diags := converter.body("", file.Body, file.Body.MissingItemRange())
+	return body, converter.source, diags
+}
+
+// body converts the body of a block into its attributes and nested blocks
+func (converter *jsonBodyConverter) body(blockType string, jsonBody hcl.Body, bodyRange hcl.Range) (*hclsyntax.Body, hcl.Diagnostics) {
+	schema := &hcl.BodySchema{}
+	switch {
+	case blockType == "":
+		schema = jsonFileSchema
+	case blockType == "dynamic":
+		schema = jsonDynamicContentSchema
+	case jsonDynamicBlockTypes[blockType]:
+		schema = jsonDynamicBlockSchema
+	}
+
+	content, remain, diags := jsonBody.PartialContent(schema)
+	attributes, attributeDiags := remain.JustAttributes()
+	diags = append(diags, attributeDiags...)
+
+	endRange := jsonBody.MissingItemRange()
+	body := &hclsyntax.Body{
+		Attributes: hclsyntax.Attributes{},
+		SrcRange:   hcl.RangeBetween(bodyRange, endRange),
+		EndRange:   endRange,
+	}
+
+	for name, attribute := range attributes {
+		expr, exprDiags := converter.expression(attribute.Expr, isJsonExpressionArgument(blockType, name))
+		diags = append(diags, exprDiags...)
+		body.Attributes[name] = &hclsyntax.Attribute{
+			Name:        name,
+			Expr:        expr,
+			SrcRange:    attribute.Expr.Range(),
+			NameRange:   attribute.NameRange,
+			EqualsRange: attribute.NameRange,
+		}
+	}
+
+	for _, block := range content.Blocks {
+		nestedBody, nestedDiags := converter.body(block.Type, block.Body, block.DefRange)
+		diags = append(diags, nestedDiags...)
+		// the block starts at the object of its body, as the key of its type can hold several blocks
+		body.Blocks = append(body.Blocks, &hclsyntax.Block{
+			Type:            block.Type,
+			Labels:          block.Labels,
+			Body:            nestedBody,
+			TypeRange:       block.DefRange,
+			LabelRanges:     block.LabelRanges,
+			OpenBraceRange:  block.DefRange,
+			CloseBraceRange: nestedBody.EndRange,
+		})
+	}
+
+	return body, diags
+}
+
+// isJsonExpressionArgument tells whether the strings of an argument are expressions rather than templates,
+// as the JSON syntax writes the keywords and references of these arguments as strings, e.g. "type": "list(string)"
+func isJsonExpressionArgument(blockType string, name string) bool {
+	switch {
+	case name == "depends_on":
+		return true
+	case blockType == "variable":
+		return name == "type"
+	case blockType == "dynamic":
+		return name == "iterator"
+	case blockType == "resource" || blockType == "data":
+		return name == "provider"
+	case blockType == "module":
+		return name == "providers"
+	}
+	return false
+}
+
+// expression converts the expression of a JSON value into the native expression it declares
+func (converter *jsonBodyConverter) expression(expr hcl.Expression, isExpression bool) (hclsyntax.Expression, hcl.Diagnostics) {
+	// without a context the strings of the value are not evaluated, so the value only tells which kind of JSON value it is
+	value, _ := expr.Value(nil)
+	valueType := value.Type()
+
+	switch {
+	case valueType.IsObjectType():
+		pairs, diags := hcl.ExprMap(expr)
+		items := make([]hclsyntax.ObjectConsItem, 0, len(pairs))
+		for _, pair := range pairs {
+			key, _ := pair.Key.Value(nil)
+			// properties named "//" are comments in the JSON syntax of Terraform
+			if key.AsString() == "//" {
+				continue
+			}
+			valueExpr, valueDiags := converter.expression(pair.Value, isExpression)
+			diags = append(diags, valueDiags...)
+			items = append(items, hclsyntax.ObjectConsItem{
+				KeyExpr: &hclsyntax.ObjectConsKeyExpr{
+					Wrapped: &hclsyntax.LiteralValueExpr{Val: key, SrcRange: pair.Key.Range()},
+				},
+				ValueExpr: valueExpr,
+			})
+		}
+		return &hclsyntax.ObjectConsExpr{Items: items, SrcRange: expr.Range(), OpenRange: expr.Range()}, diags
+	case valueType.IsTupleType():
+		elements, diags := hcl.ExprList(expr)
+		exprs := make([]hclsyntax.Expression, 0, len(elements))
+		for _, element := range elements {
+			elementExpr, elementDiags := converter.expression(element, isExpression)
+			diags = append(diags, elementDiags...)
+			exprs = append(exprs, elementExpr)
+		}
+		return &hclsyntax.TupleConsExpr{Exprs: exprs, SrcRange: expr.Range(), OpenRange: expr.Range()}, diags
+	case valueType == cty.String && value.IsKnown() && !value.IsNull():
+		return converter.stringExpression(value.AsString(), expr.Range(), isExpression)
+	}
+
+	// numbers, booleans and null
+	return &hclsyntax.LiteralValueExpr{Val: value, SrcRange: expr.Range()}, nil
+}
+
+// stringExpression parses a string as a template, or as an expression
+// The string is appended to the source between quotes, the same way a native template is written
+func (converter *jsonBodyConverter) stringExpression(value string, valueRange hcl.Range, isExpression bool) (hclsyntax.Expression, hcl.Diagnostics) {
+	converter.source = append(converter.source, '\n', '"')
+	start := hcl.Pos{
+		Line:   valueRange.Start.Line,
+		Column: valueRange.Start.Column + 1,
+		Byte:   len(converter.source),
+	}
+	converter.source = append(converter.source, value...)
+	converter.source = append(converter.source, '"')
+
+	if isExpression {
+		return hclsyntax.ParseExpression([]byte(value), converter.fileName, start)
+	}
+
+	expr, diags := hclsyntax.ParseTemplate([]byte(value), converter.fileName, start)
+	if diags.HasErrors() {
+		return expr, diags
+	}
+
+	// the template spans the quotes, so that its source is the same as the one of a native template
+	templateRange := valueRange
+	templateRange.Start.Byte = start.Byte - 1
+	templateRange.End.Byte = start.Byte + len(value) + 1
+	switch template := expr.(type) {
+	case *hclsyntax.TemplateExpr:
+		template.SrcRange = templateRange
+	case *hclsyntax.TemplateWrapExpr:
+		template.SrcRange = templateRange
+	}
+	return expr, diags
+}
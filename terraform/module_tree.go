@@ -0,0 +1,186 @@
+package terraform
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ParseModuleTreeResult holds the outcome of parsing a root module and the local modules it calls
+type ParseModuleTreeResult struct {
+	// Modules holds the result of each module keyed by its address (e.g. module.vpc or module.vpc.module.subnets)
+	// The root module is keyed by an empty address
+	Modules map[string]*ParseModuleResult
+	// Resources holds the body of the resource and data blocks of all the modules keyed by their absolute address
+	// e.g. aws_vpc.this, module.vpc.aws_subnet.this or module.vpc.data.aws_ami.ubuntu
+	Resources map[string]JSON
+	// ModuleErrors will contain the addresses of the module calls which could not be resolved alongside their errors
+	ModuleErrors map[string]error
+}
+
+// moduleCall is a module block whose source is a local directory
+type moduleCall struct {
+	name   string
+	source string
+	inputs ValueMap
+}
+
+// arguments of a module block which are not input variables of the called module
+var moduleMetaArguments = map[string]bool{
+	"source":     true,
+	"version":    true,
+	"count":      true,
+	"for_each":   true,
+	"providers":  true,
+	"depends_on": true,
+}
+
+var maxModuleDepth = 10
+
+// ParseModuleTree parses the root module of the provided filesystem and then follows the module calls with a local source
+// (e.g. ./modules/vpc), passing the arguments of each module block in as the input variables of the called module
+// The filesystem functions read from the provided filesystem unless the options hold another one
+func ParseModuleTree(fsys fs.FS, options Options) (*ParseModuleTreeResult, error) {
+	rawFiles, err := readModuleFiles(fsys, ".", true)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.FS == nil {
+		options.FS = fsys
+	}
+
+	treeRes := &ParseModuleTreeResult{
+		Modules:      make(map[string]*ParseModuleResult),
+		Resources:    make(map[string]JSON),
+		ModuleErrors: make(map[string]error),
+	}
+
+	parseRes, files, vars := parseModule(rawFiles, ".", nil, options)
+	addModule(treeRes, "", parseRes)
+	parseModuleCalls(fsys, ".", "", []string{"."}, files, vars, options, treeRes)
+
+	return treeRes, nil
+}
+
+func parseModuleCalls(fsys fs.FS, dir string, address string, ancestors []string, files map[string]File, vars ModuleVariables, options Options, treeRes *ParseModuleTreeResult) {
+	for _, call := range extractModuleCalls(files, vars, options) {
+		callAddress := joinPath(address, "module."+call.name)
+
+		callDir := path.Join(dir, call.source)
+		if !fs.ValidPath(callDir) {
+			treeRes.ModuleErrors[callAddress] = createInvalidModuleError([]error{
+				fmt.Errorf("module source %s is outside of the root directory", call.source),
+			})
+			continue
+		}
+		if isModuleCycle(callDir, ancestors) {
+			treeRes.ModuleErrors[callAddress] = createInvalidModuleError([]error{
+				fmt.Errorf("module cycle detected: %s -> %s", strings.Join(ancestors, " -> "), callDir),
+			})
+			continue
+		}
+		if len(ancestors) > maxModuleDepth {
+			treeRes.ModuleErrors[callAddress] = createInvalidModuleError([]error{
+				fmt.Errorf("module depth exceeds the limit of %d nested modules", maxModuleDepth),
+			})
+			continue
+		}
+
+		rawFiles, err := readModuleFiles(fsys, callDir, false)
+		if err != nil {
+			treeRes.ModuleErrors[callAddress] = createInvalidModuleError([]error{err})
+			continue
+		}
+
+		// like Terraform, the variable sources only set the input variables of the root module
+		callOptions := options
+		callOptions.VariableSources = nil
+		parseRes, callFiles, callVars := parseModule(rawFiles, callDir, call.inputs, callOptions)
+		addModule(treeRes, callAddress, parseRes)
+		parseModuleCalls(fsys, callDir, callAddress, append(ancestors[:len(ancestors):len(ancestors)], callDir), callFiles, callVars, options, treeRes)
+	}
+}
+
+// addModule records the result of a module alongside its resources, of which the addresses are prefixed by the address of the module
+func addModule(treeRes *ParseModuleTreeResult, address string, parseRes *ParseModuleResult) {
+	treeRes.Modules[address] = parseRes
+	for resourceAddress, body := range buildSymbolTable(parseRes.ParsedFiles).blocks {
+		treeRes.Resources[joinPath(address, resourceAddress)] = body
+	}
+}
+
+func isModuleCycle(dir string, ancestors []string) bool {
+	for _, ancestor := range ancestors {
+		if ancestor == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// extractModuleCalls returns the module blocks with a local source, with their arguments evaluated using the provided variables
+func extractModuleCalls(files map[string]File, vars ModuleVariables, options Options) []moduleCall {
+	fileNames := make([]string, 0, len(files))
+	for fileName := range files {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	ctx := &hcl.EvalContext{
+		Functions: moduleFunctions(options),
+		Variables: createValueMap(vars),
+	}
+
+	var calls []moduleCall
+	for _, fileName := range fileNames {
+		if !isValidTerraformFile(fileName) {
+			continue
+		}
+
+		bodyContent, _, _ := files[fileName].hclFile.Body.PartialContent(tfFileModuleSchema)
+		for _, block := range bodyContent.Blocks {
+			attrs, _ := block.Body.JustAttributes()
+
+			source, ok := attrs["source"]
+			if !ok {
+				continue
+			}
+			sourceValue, diags := source.Expr.Value(nil)
+			if diags.HasErrors() || sourceValue.Type() != cty.String || sourceValue.IsNull() || !isLocalModuleSource(sourceValue.AsString()) {
+				continue
+			}
+
+			inputs := ValueMap{}
+			for name, attr := range attrs {
+				if moduleMetaArguments[name] {
+					continue
+				}
+				value, diags := attr.Expr.Value(ctx)
+				// the argument cannot be evaluated so the called module falls back on its defaults
+				if diags.HasErrors() || !value.IsWhollyKnown() {
+					continue
+				}
+				inputs[name] = value
+			}
+
+			calls = append(calls, moduleCall{
+				name:   block.Labels[0],
+				source: sourceValue.AsString(),
+				inputs: inputs,
+			})
+		}
+	}
+
+	return calls
+}
+
+// Terraform only treats sources starting with ./ or ../ as local paths
+func isLocalModuleSource(source string) bool {
+	return strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../")
+}
@@ -0,0 +1,139 @@
+package terraform
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseModuleTree(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.tf": {Data: []byte(`
+module "vpc" {
+	source = "./modules/vpc"
+	cidr   = var.cidr
+}
+
+module "remote" {
+	source = "terraform-aws-modules/vpc/aws"
+}
+
+module "outside" {
+	source = "../outside"
+}
+
+resource "aws_s3_bucket" "logs" {
+	bucket = "logs"
+}
+
+variable "cidr" {}`)},
+		"terraform.tfvars": {Data: []byte(`cidr = "10.0.0.0/16"`)},
+		"modules/vpc/main.tf": {Data: []byte(`
+resource "aws_vpc" "this" {
+	cidr_block = var.cidr
+	tags       = var.tags
+}
+
+module "subnets" {
+	source = "../subnets"
+	cidr   = aws_vpc.this.cidr_block
+}
+
+variable "cidr" {
+	default = "0.0.0.0/0"
+}
+
+variable "tags" {
+	default = {}
+}`)},
+		"modules/vpc/terraform.tfvars": {Data: []byte(`tags = { Name = "ignored" }`)},
+		"modules/subnets/main.tf": {Data: []byte(`
+resource "aws_subnet" "this" {
+	cidr_block = var.cidr
+}
+
+module "loop" {
+	source = "../vpc"
+}`)},
+	}
+
+	actual, err := ParseModuleTree(fsys, DefaultOptions())
+	require.Nil(t, err)
+
+	assert.ElementsMatch(t, []string{"", "module.vpc", "module.vpc.module.subnets"}, moduleAddresses(actual.Modules))
+	assert.Equal(t, map[string]JSON{
+		"modules/vpc/main.tf": {
+			"resource": map[string]interface{}{
+				"aws_vpc": map[string]interface{}{
+					"this": map[string]interface{}{
+						"cidr_block": "10.0.0.0/16",
+						"tags":       map[string]interface{}{},
+					},
+				},
+			},
+			"module": map[string]interface{}{
+				"subnets": map[string]interface{}{
+					"cidr":   "${aws_vpc.this.cidr_block}",
+					"source": "../subnets",
+				},
+			},
+			"variable": map[string]interface{}{
+				"cidr": map[string]interface{}{
+					"default": "0.0.0.0/0",
+				},
+				"tags": map[string]interface{}{
+					"default": map[string]interface{}{},
+				},
+			},
+		},
+	}, actual.Modules["module.vpc"].ParsedFiles)
+	assert.Equal(t, "${var.cidr}", actual.Modules["module.vpc.module.subnets"].ParsedFiles["modules/subnets/main.tf"]["resource"].(map[string]interface{})["aws_subnet"].(map[string]interface{})["this"].(map[string]interface{})["cidr_block"])
+
+	// the resources of the child modules are addressed by the address of their module
+	assert.ElementsMatch(t, []string{"aws_s3_bucket.logs", "module.vpc.aws_vpc.this", "module.vpc.module.subnets.aws_subnet.this"}, resourceAddresses(actual.Resources))
+	assert.Equal(t, "10.0.0.0/16", actual.Resources["module.vpc.aws_vpc.this"]["cidr_block"])
+	assert.Equal(t, "${var.cidr}", actual.Resources["module.vpc.module.subnets.aws_subnet.this"]["cidr_block"])
+
+	require.Len(t, actual.ModuleErrors, 2)
+	assert.Equal(t, "\nmodule source ../outside is outside of the root directory", GenerateDebugLogs(actual.ModuleErrors["module.outside"]))
+	assert.Equal(t, "\nmodule cycle detected: . -> modules/vpc -> modules/subnets -> modules/vpc", GenerateDebugLogs(actual.ModuleErrors["module.vpc.module.subnets.module.loop"]))
+	assert.True(t, errors.Is(actual.ModuleErrors["module.outside"], ErrInvalidModule))
+}
+
+func TestParseModuleTreeDepthLimit(t *testing.T) {
+	oldMaxModuleDepth := maxModuleDepth
+	defer func() {
+		maxModuleDepth = oldMaxModuleDepth
+	}()
+	maxModuleDepth = 1
+
+	fsys := fstest.MapFS{
+		"main.tf":     {Data: []byte(`module "a" { source = "./a" }`)},
+		"a/main.tf":   {Data: []byte(`module "b" { source = "./b" }`)},
+		"a/b/main.tf": {Data: []byte(`module "c" { source = "./c" }`)},
+	}
+
+	actual, err := ParseModuleTree(fsys, DefaultOptions())
+	require.Nil(t, err)
+	assert.ElementsMatch(t, []string{"", "module.a"}, moduleAddresses(actual.Modules))
+	assert.Equal(t, "\nmodule depth exceeds the limit of 1 nested modules", GenerateDebugLogs(actual.ModuleErrors["module.a.module.b"]))
+}
+
+func moduleAddresses(modules map[string]*ParseModuleResult) []string {
+	addresses := make([]string, 0, len(modules))
+	for address := range modules {
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
+func resourceAddresses(resources map[string]JSON) []string {
+	addresses := make([]string, 0, len(resources))
+	for address := range resources {
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
@@ -0,0 +1,50 @@
+package terraform
+
+import "sort"
+
+// Output is an output block of a module
+type Output struct {
+	// Value is the value of the output, evaluated with the variables and locals of the module
+	// It holds the same placeholders as the parsed files for the parts which are not known or which are redacted,
+	// so an output derived from a sensitive variable without sensitive = true holds a redaction placeholder
+	Value       interface{} `json:"value"`
+	Sensitive   bool        `json:"sensitive"`
+	Description string      `json:"description,omitempty"`
+	// FileName is the name of the file which declares the output
+	FileName string `json:"fileName"`
+}
+
+// extractOutputs collects the output blocks of the parsed files, once the override files and the references are applied to them
+func extractOutputs(parseRes *ParseModuleResult) {
+	fileNames := make([]string, 0, len(parseRes.ParsedFiles))
+	for fileName := range parseRes.ParsedFiles {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	for _, fileName := range fileNames {
+		outputs, _ := parseRes.ParsedFiles[fileName]["output"].(JSON)
+		for name, body := range outputs {
+			body, ok := body.(JSON)
+			if !ok {
+				continue
+			}
+
+			output := Output{
+				Value:    body["value"],
+				FileName: fileName,
+			}
+			// like Terraform, the sensitive argument also accepts the string representation of a boolean
+			switch sensitive := body["sensitive"].(type) {
+			case bool:
+				output.Sensitive = sensitive
+			case string:
+				output.Sensitive = sensitive == "true"
+			}
+			if description, ok := body["description"].(string); ok {
+				output.Description = description
+			}
+			parseRes.Outputs[name] = output
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package terraform
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Logic inspired from https://github.com/hashicorp/terraform/blob/v1.0.0/internal/configs/module_merge.go
+
+// blockMatch is a block found in a parsed document alongside its JSON path
+type blockMatch struct {
+	block JSON
+	path  string
+}
+
+// isOverrideFile checks if the file is an override file, i.e. override.tf, *_override.tf or their .tf.json equivalents
+func isOverrideFile(fileName string) bool {
+	baseName := path.Base(strings.Replace(fileName, "\\", "/", -1))
+	for _, fileExt := range []string{TF_JSON, TF} {
+		if strings.HasSuffix(baseName, fileExt) {
+			name := strings.TrimSuffix(baseName, fileExt)
+			return name == "override" || strings.HasSuffix(name, "_override")
+		}
+	}
+	return false
+}
+
+// sortOverrideFilesLast sorts file names lexically, with the override files after all the other files
+func sortOverrideFilesLast(fileNames []string) {
+	sort.Slice(fileNames, func(i, j int) bool {
+		if isOverrideFile(fileNames[i]) != isOverrideFile(fileNames[j]) {
+			return !isOverrideFile(fileNames[i])
+		}
+		return fileNames[i] < fileNames[j]
+	})
+}
+
+// applyOverrides merges the parsed override files into the parsed files they override, block by block, the way Terraform does
+// The override files are then removed from the parsed files, and the overridden paths are recorded in the result
+func applyOverrides(parseRes *ParseModuleResult) {
+	fileNames := make([]string, 0, len(parseRes.ParsedFiles))
+	for fileName := range parseRes.ParsedFiles {
+		fileNames = append(fileNames, fileName)
+	}
+	sortOverrideFilesLast(fileNames)
+
+	var baseFiles []string
+	for _, fileName := range fileNames {
+		if !isOverrideFile(fileName) {
+			baseFiles = append(baseFiles, fileName)
+			continue
+		}
+
+		var errors []error
+		for _, override := range overrideTargets(parseRes.ParsedFiles[fileName]) {
+			var err error
+			if override.keys[0] == "locals" {
+				err = overrideLocals(parseRes, baseFiles, fileName, override.blockMatch)
+			} else {
+				err = overrideBlock(parseRes, baseFiles, fileName, override.blockMatch, override.keys)
+			}
+			if err != nil {
+				errors = append(errors, err)
+			}
+		}
+
+		if len(errors) > 0 {
+			recordDebugLog(parseRes, fileName, createInvalidOverrideError(errors))
+		}
+		delete(parseRes.ParsedFiles, fileName)
+		delete(parseRes.SourceMaps, fileName)
+		delete(parseRes.Comments, fileName)
+	}
+}
+
+// overrideTarget is a block of an override file alongside the keys identifying the blocks it overrides
+type overrideTarget struct {
+	blockMatch
+	keys []string
+}
+
+// overrideTargets lists the top-level blocks of an override file, sorted by path
+func overrideTargets(document JSON) []overrideTarget {
+	var targets []overrideTarget
+	for blockType, value := range document {
+		switch blockType {
+		case "resource", "data":
+			for _, types := range findBlocks(value, nil, blockType, false) {
+				for typeName, names := range types.block {
+					targets = append(targets, namedOverrideTargets(names, joinPath(types.path, typeName), []string{blockType, typeName})...)
+				}
+			}
+		case "variable", "output", "module", "provider":
+			targets = append(targets, namedOverrideTargets(value, blockType, []string{blockType})...)
+		case "terraform", "locals":
+			for _, block := range findBlocks(value, nil, blockType, false) {
+				targets = append(targets, overrideTarget{blockMatch: block, keys: []string{blockType}})
+			}
+		}
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].path < targets[j].path
+	})
+	return targets
+}
+
+func namedOverrideTargets(value interface{}, path string, keys []string) []overrideTarget {
+	var targets []overrideTarget
+	for _, names := range findBlocks(value, nil, path, false) {
+		for name, nameValue := range names.block {
+			for _, block := range findBlocks(nameValue, nil, joinPath(names.path, name), false) {
+				targets = append(targets, overrideTarget{
+					blockMatch: block,
+					keys:       append(keys[:len(keys):len(keys)], name),
+				})
+			}
+		}
+	}
+	return targets
+}
+
+// overrideBlock merges an override block into the block with the same identity in the base files
+func overrideBlock(parseRes *ParseModuleResult, baseFiles []string, overrideFile string, override blockMatch, keys []string) error {
+	// resources and data sources also override their instances when they are expanded
+	matchInstances := keys[0] == "resource" || keys[0] == "data"
+
+	found := false
+	for _, baseFile := range baseFiles {
+		for _, base := range findBlocks(parseRes.ParsedFiles[baseFile], keys, "", matchInstances) {
+			// providers are identified by both their name and their alias
+			if keys[0] == "provider" && base.block["alias"] != override.block["alias"] {
+				continue
+			}
+			found = true
+			// the comments attached to the override block are attached to the block it overrides
+			moveComments(parseRes, baseFile, base.path, overrideFile, override.path)
+
+			for key, value := range override.block {
+				baseLifecycle, isBaseMap := base.block[key].(JSON)
+				overrideLifecycle, isOverrideMap := value.(JSON)
+				// the lifecycle block is merged argument by argument rather than replaced
+				if key == "lifecycle" && isBaseMap && isOverrideMap {
+					for argument, argumentValue := range overrideLifecycle {
+						baseLifecycle[argument] = argumentValue
+						recordOverride(parseRes, baseFile, joinPath(joinPath(base.path, key), argument), overrideFile, joinPath(joinPath(override.path, key), argument))
+					}
+					continue
+				}
+
+				base.block[key] = value
+				recordOverride(parseRes, baseFile, joinPath(base.path, key), overrideFile, joinPath(override.path, key))
+			}
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("Missing base %s for override at %s", strings.Join(keys, "."), override.path)
+	}
+	return nil
+}
+
+// overrideLocals replaces each local value declared in an override file, wherever it is declared in the base files
+func overrideLocals(parseRes *ParseModuleResult, baseFiles []string, overrideFile string, override blockMatch) error {
+	var errors []string
+	for name, value := range override.block {
+		found := false
+		for _, baseFile := range baseFiles {
+			for _, base := range findBlocks(parseRes.ParsedFiles[baseFile], []string{"locals"}, "", false) {
+				if _, ok := base.block[name]; !ok {
+					continue
+				}
+				found = true
+				base.block[name] = value
+				recordOverride(parseRes, baseFile, joinPath(base.path, name), overrideFile, joinPath(override.path, name))
+			}
+		}
+		if !found {
+			errors = append(errors, fmt.Sprintf("Missing base local.%s for override", name))
+		}
+	}
+
+	if len(errors) > 0 {
+		sort.Strings(errors)
+		return fmt.Errorf("%s", strings.Join(errors, "\n"))
+	}
+	return nil
+}
+
+// recordOverride records which file overrode a path and replaces its source ranges and its comments with the ones
+// from the override file
+func recordOverride(parseRes *ParseModuleResult, baseFile string, basePath string, overrideFile string, overridePath string) {
+	if _, ok := parseRes.Overrides[baseFile]; !ok {
+		parseRes.Overrides[baseFile] = make(map[string]string)
+	}
+	parseRes.Overrides[baseFile][basePath] = overrideFile
+
+	for path := range parseRes.Comments[baseFile] {
+		if isPathOrNested(path, basePath) {
+			delete(parseRes.Comments[baseFile], path)
+		}
+	}
+	for path := range parseRes.Comments[overrideFile] {
+		if isPathOrNested(path, overridePath) {
+			moveComments(parseRes, baseFile, basePath+strings.TrimPrefix(path, overridePath), overrideFile, path)
+		}
+	}
+
+	baseSourceMap := parseRes.SourceMaps[baseFile]
+	if baseSourceMap == nil {
+		return
+	}
+	for path := range baseSourceMap {
+		if isPathOrNested(path, basePath) {
+			delete(baseSourceMap, path)
+		}
+	}
+	for path, sourceRange := range parseRes.SourceMaps[overrideFile] {
+		if isPathOrNested(path, overridePath) {
+			baseSourceMap[basePath+strings.TrimPrefix(path, overridePath)] = sourceRange
+		}
+	}
+}
+
+// moveComments attaches the comments of a path of an override file to a path of the base file it is merged into
+func moveComments(parseRes *ParseModuleResult, baseFile string, basePath string, overrideFile string, overridePath string) {
+	comments, ok := parseRes.Comments[overrideFile][overridePath]
+	if !ok {
+		return
+	}
+	if _, ok := parseRes.Comments[baseFile]; !ok {
+		parseRes.Comments[baseFile] = Comments{}
+	}
+	parseRes.Comments[baseFile][basePath] = append(parseRes.Comments[baseFile][basePath], comments...)
+}
+
+// findBlocks looks up the blocks found under the provided keys, descending into the lists of repeated blocks
+// When matching instances, the last key also matches the instances of an expanded resource, e.g. web["0"]
+func findBlocks(value interface{}, keys []string, path string, matchInstances bool) []blockMatch {
+	if list, ok := value.([]interface{}); ok {
+		var matches []blockMatch
+		for i, elem := range list {
+			matches = append(matches, findBlocks(elem, keys, indexPath(path, i), matchInstances)...)
+		}
+		return matches
+	}
+
+	block, ok := value.(JSON)
+	if !ok {
+		return nil
+	}
+	if len(keys) == 0 {
+		return []blockMatch{{block: block, path: path}}
+	}
+
+	var matches []blockMatch
+	for key, nested := range block {
+		if key == keys[0] || (matchInstances && len(keys) == 1 && strings.HasPrefix(key, keys[0]+"[")) {
+			matches = append(matches, findBlocks(nested, keys[1:], joinPath(path, key), matchInstances)...)
+		}
+	}
+	return matches
+}
+
+func isPathOrNested(path string, parentPath string) bool {
+	return path == parentPath || strings.HasPrefix(path, parentPath+".") || strings.HasPrefix(path, parentPath+"[")
+}
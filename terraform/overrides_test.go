@@ -0,0 +1,17 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsOverrideFile(t *testing.T) {
+	assert.True(t, isOverrideFile("override.tf"))
+	assert.True(t, isOverrideFile("network_override.tf"))
+	assert.True(t, isOverrideFile("override.tf.json"))
+	assert.True(t, isOverrideFile("modules\\vpc\\vpc_override.tf.json"))
+	assert.False(t, isOverrideFile("overrides.tf"))
+	assert.False(t, isOverrideFile("networkoverride.tf"))
+	assert.False(t, isOverrideFile("override.tfvars"))
+}
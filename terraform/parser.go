@@ -1,28 +1,64 @@
 package terraform
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"strings"
 
 	"github.com/zclconf/go-cty/cty"
 	ctyconvert "github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
 	ctyjson "github.com/zclconf/go-cty/cty/json"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
+	hcljson "github.com/hashicorp/hcl/v2/json"
 )
 
 // Parsing logic was taken from https://github.com/tmccombs/hcl2json/tree/a80b1cd24d787567ec3e93b6806077b8d6ee4d3d/convert
 
 type Options struct {
 	Simplify bool
+	// ExpandCountAndForEach emits one object per resource or data instance when the value of
+	// their count or for_each meta-argument is known, e.g. web["0"] or web["key"]
+	ExpandCountAndForEach bool
+	// VariableSources are the -var and -var-file options and the environment variables passed to the Terraform CLI,
+	// in the order they are provided
+	// They only apply to the root module
+	VariableSources []VariableSource
+	// ValidateVariables evaluates the validation blocks of the variables against their final value
+	// and reports the failing conditions in the variable diagnostics
+	ValidateVariables bool
+	// FS is the read-only filesystem of the module, of which the root is the root module
+	// When set, path.module, path.root and path.cwd are bound and the file, filebase64, fileexists, fileset and templatefile
+	// functions read from it, without ever leaving it
+	FS fs.FS
+	// UnknownMarkers emits the expressions which cannot be evaluated as structured markers holding their source,
+	// their references and the reason they are not known, e.g. {"__unknown__": {"expression": "aws_iam_role.x.arn", ...}},
+	// instead of their source wrapped in ${...}
+	UnknownMarkers bool
+	// ResolveReferences replaces the references to the attributes of the resource and data blocks of the module,
+	// e.g. aws_s3_bucket.logs.id, by their value when it is set in the module and by a typed reference to the block otherwise
+	ResolveReferences bool
+	// PreserveComments attaches the comments of the files to the nearest block or attribute
+	// and returns them keyed by the JSON paths of the source map, e.g. to read inline ignores
+	PreserveComments bool
+	// RecoverFromErrors still parses the files with syntax errors, dropping the top-level blocks which overlap the errors,
+	// so that the blocks which parsed cleanly are emitted and the variables and locals they declare are part of the module
+	// The dropped ranges are returned under the damaged ranges
+	RecoverFromErrors bool
 }
 
 type Parser struct {
 	bytes     []byte
 	variables ValueMap
+	functions map[string]function.Function
 	options   Options
+	sourceMap SourceMap
+	// diagnostics holds the warnings of the file, which are shared with the parsers of its nested scopes
+	diagnostics *[]*Diagnostic
 }
 
 type NewParserParams struct {
@@ -35,20 +71,60 @@ type JSON = map[string]interface{}
 
 func newParser(params NewParserParams) Parser {
 	return Parser{
-		bytes:     params.bytes,
-		variables: createValueMap(params.variables),
-		options:   params.options,
+		bytes:       params.bytes,
+		variables:   createValueMap(params.variables),
+		functions:   moduleFunctions(params.options),
+		options:     params.options,
+		sourceMap:   SourceMap{},
+		diagnostics: &[]*Diagnostic{},
+	}
+}
+
+// DefaultOptions returns the options used when parsing through ParseModule and ParseHclToJson
+func DefaultOptions() Options {
+	return Options{
+		Simplify: true,
 	}
 }
 
 // ParseHclToJson parses a provided HCL file to JSON and dereferences any known variables using the provided variables
 func ParseHclToJson(fileName string, fileContent string, variables ModuleVariables) (string, error) {
-	file, diagnostics := hclsyntax.ParseConfig([]byte(fileContent), fileName, hcl.Pos{Line: 1, Column: 1})
+	document, _, err := ParseHclToDocument(fileName, fileContent, variables, DefaultOptions())
+	if err != nil {
+		return "", err
+	}
+
+	jsonBytes, err := json.MarshalIndent(document, "", "\t")
+	if err != nil {
+		return "", createInternalJSONParsingError([]error{err})
+	}
+
+	return string(jsonBytes), nil
+}
+
+// ParseHclToDocument parses a provided HCL file into a document made of plain Go values
+// (maps, slices, strings, booleans, json.Number and nil) and dereferences any known variables using the provided variables
+// It also returns a source map which records where in the file each of the emitted JSON paths was declared
+func ParseHclToDocument(fileName string, fileContent string, variables ModuleVariables, options Options) (JSON, SourceMap, error) {
+	document, sourceMap, _, err := parseHclSourceToDocument(fileName, fileContent, variables, options)
+	return document, sourceMap, err
+}
+
+// parseHclSourceToDocument parses a provided HCL file into a document, alongside the warnings of the file
+func parseHclSourceToDocument(fileName string, fileContent string, variables ModuleVariables, options Options) (JSON, SourceMap, []*Diagnostic, error) {
+	file, diagnostics := parseHclFile(fileName, []byte(fileContent))
 	if diagnostics.HasErrors() {
-		return "", createInvalidHCLError(diagnostics.Errs())
+		return nil, nil, nil, createInvalidHCLError(diagnostics.Errs())
 	}
 
-	var parsedFile interface{}
+	return parseHclFileToDocument(fileName, file, variables, options)
+}
+
+// parseHclFileToDocument parses an HCL file which was already read, e.g. the part of a file with syntax errors which was recovered
+func parseHclFileToDocument(fileName string, file *hcl.File, variables ModuleVariables, options Options) (JSON, SourceMap, []*Diagnostic, error) {
+	var parsedFile JSON
+	var sourceMap SourceMap
+	var diagnostics []*Diagnostic
 	var parseErr error
 	func() {
 		defer func() {
@@ -57,55 +133,99 @@ func ParseHclToJson(fileName string, fileContent string, variables ModuleVariabl
 				parseErr = fmt.Errorf("panic: %v", r)
 			}
 		}()
-		parsedFile, parseErr = parseFile(file, variables) // Call parseFile
+		parsedFile, sourceMap, diagnostics, parseErr = parseFile(fileName, file, variables, options) // Call parseFile
 
 	}()
 
 	if parseErr != nil {
-		return "", createInternalHCLParsingError([]error{parseErr})
+		// the errors caused by the file, e.g. the invalid blocks of a JSON file, are reported as they are
+		if customError, ok := parseErr.(*CustomError); ok && customError.IsUserError() {
+			return nil, nil, nil, customError
+		}
+		return nil, nil, nil, createInternalHCLParsingError([]error{parseErr})
 	}
 
-	jsonBytes, err := json.MarshalIndent(parsedFile, "", "\t")
+	document, err := toDocument(parsedFile)
 	if err != nil {
-		return "", createInternalJSONParsingError([]error{err})
+		return nil, nil, nil, createInternalJSONParsingError([]error{err})
 	}
 
-	return string(jsonBytes), nil
+	return document, sourceMap, diagnostics, nil
+}
+
+// parseHclFile parses files written in either the native or the JSON syntax of HCL
+func parseHclFile(fileName string, fileContent []byte) (*hcl.File, hcl.Diagnostics) {
+	if isJsonFile(fileName) {
+		return hcljson.Parse(fileContent, fileName)
+	}
+	return hclsyntax.ParseConfig(fileContent, fileName, hcl.Pos{Line: 1, Column: 1})
+}
+
+// toDocument converts the parser output, which still holds cty values, into plain Go values
+// Numbers are decoded as json.Number so they keep the precision of the original cty values
+func toDocument(parsedFile JSON) (JSON, error) {
+	jsonBytes, err := json.Marshal(parsedFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var document JSON
+	decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	return document, nil
 }
 
-func parseFile(file *hcl.File, variables ModuleVariables) (JSON, error) {
+func parseFile(fileName string, file *hcl.File, variables ModuleVariables, options Options) (JSON, SourceMap, []*Diagnostic, error) {
 	parser := newParser(NewParserParams{
 		bytes:     file.Bytes,
 		variables: variables,
-		options: Options{
-			Simplify: true,
-		}})
-
-	body, ok := file.Body.(*hclsyntax.Body)
-	if !ok {
-		return nil, fmt.Errorf("Failed to parse hcl.Body to hclsyntax.Body type")
+		options:   options,
+	})
+
+	var body *hclsyntax.Body
+	if isJsonFile(fileName) {
+		// the files written in the JSON syntax are converted into a native body, of which the expressions
+		// read their source from the strings of the file
+		var diags hcl.Diagnostics
+		body, parser.bytes, diags = parseJsonBody(fileName, file)
+		if diags.HasErrors() {
+			return nil, nil, nil, createInvalidHCLError(diags.Errs())
+		}
+	} else {
+		var ok bool
+		body, ok = file.Body.(*hclsyntax.Body)
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("Failed to parse hcl.Body to hclsyntax.Body type")
+		}
 	}
 
 	out := make(JSON)
-	err := parser.parseBody(body, out)
+	err := parser.parseBody(body, out, "")
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
-	return out, nil
+	return out, parser.sourceMap, *parser.diagnostics, nil
 }
 
-func (parser *Parser) parseBody(body *hclsyntax.Body, out JSON) error {
+func (parser *Parser) parseBody(body *hclsyntax.Body, out JSON, path string) error {
 	var err error
 	for key, value := range body.Attributes {
 		out[key], err = parser.parseExpression(value.Expr)
 		if err != nil {
 			return fmt.Errorf("Failed to parse expression: %w", err)
 		}
+		attributePath := joinPath(path, key)
+		parser.sourceMap.add(attributePath, value.SrcRange)
+		parser.addExpressionRanges(value.Expr, attributePath)
 	}
 
 	for _, block := range body.Blocks {
-		if err := parser.parseBlock(block, out); err != nil {
+		if err := parser.parseBlock(block, out, path); err != nil {
 			return fmt.Errorf("Failed to parse block: %w", err)
 		}
 	}
@@ -113,17 +233,51 @@ func (parser *Parser) parseBody(body *hclsyntax.Body, out JSON) error {
 	return nil
 }
 
-func (parser *Parser) parseBlock(block *hclsyntax.Block, out JSON) error {
-	key, nestedOut, err := parser.parseLabels(block.Type, block.Labels, out)
+func (parser *Parser) parseBlock(block *hclsyntax.Block, out JSON, path string) error {
+	if path != "" && isDynamicBlock(block) {
+		return parser.parseDynamicBlock(block, out, path)
+	}
+
+	if parser.options.ExpandCountAndForEach && path == "" && isResourceBlock(block) {
+		if instances, ok := parser.resourceInstances(block.Body); ok {
+			return parser.parseResourceInstances(block, instances, out, path)
+		}
+	}
+
+	_, _, err := parser.parseLabelledBody(block.Type, block.Labels, block.Body, block.Range(), out, path)
+	return err
+}
+
+// parseLabelledBody parses a block body under the key and labels it was declared with
+// and returns the parsed value alongside the JSON path it was emitted at
+func (parser *Parser) parseLabelledBody(blockType string, labels []string, body *hclsyntax.Body, r hcl.Range, out JSON, path string) (JSON, string, error) {
+	key, nestedOut, keyPath, err := parser.parseLabels(blockType, labels, out, path)
 	if err != nil {
-		return err
+		return nil, "", err
+	}
+
+	// blocks sharing the same key are emitted as a list, so their paths are indexed
+	blockPath := keyPath
+	if current, exists := nestedOut[key]; exists {
+		if list, ok := current.([]interface{}); ok {
+			blockPath = indexPath(keyPath, len(list))
+		} else {
+			parser.sourceMap.movePrefix(keyPath, indexPath(keyPath, 0))
+			blockPath = indexPath(keyPath, 1)
+		}
 	}
 
 	value := make(JSON)
-	err = parser.parseBody(block.Body, value)
+	err = parser.parseBody(body, value, blockPath)
 	if err != nil {
-		return err
+		return nil, "", err
+	}
+	if path == "" && blockType == "variable" && isSensitiveVariable(body) {
+		if _, ok := value["default"]; ok {
+			value["default"] = JSON{REDACTED_VALUE_KEY: SENSITIVE_REDACTION_REASON}
+		}
 	}
+	parser.sourceMap.add(blockPath, r)
 
 	if current, exists := nestedOut[key]; exists {
 		if list, ok := current.([]interface{}); ok {
@@ -135,10 +289,10 @@ func (parser *Parser) parseBlock(block *hclsyntax.Block, out JSON) error {
 		nestedOut[key] = value
 	}
 
-	return nil
+	return value, blockPath, nil
 }
 
-func (parser *Parser) parseLabels(key string, labels []string, out JSON) (string, JSON, error) {
+func (parser *Parser) parseLabels(key string, labels []string, out JSON, path string) (string, JSON, string, error) {
 	for _, label := range labels {
 		// Checks to see if the label exists in the current output
 		// When the label exists, move onto the next label reference.
@@ -148,28 +302,72 @@ func (parser *Parser) parseLabels(key string, labels []string, out JSON) (string
 			var ok bool
 			out, ok = out[key].(JSON)
 			if !ok {
-				return "", nil, fmt.Errorf("Failed to convert block to JSON: %v.%v", key, strings.Join(labels, "."))
+				return "", nil, "", fmt.Errorf("Failed to convert block to JSON: %v.%v", key, strings.Join(labels, "."))
 			}
 		} else {
 			out[key] = make(JSON)
 			out = out[key].(JSON)
 		}
 
+		path = joinPath(path, key)
 		key = label
 	}
 
-	return key, out, nil
+	return key, out, joinPath(path, key), nil
+}
+
+// addExpressionRanges records the ranges of the elements and items nested inside tuple and object expressions
+func (parser *Parser) addExpressionRanges(expr hclsyntax.Expression, path string) {
+	switch value := expr.(type) {
+	case *hclsyntax.TupleConsExpr:
+		for i, elem := range value.Exprs {
+			elemPath := indexPath(path, i)
+			parser.sourceMap.add(elemPath, elem.Range())
+			parser.addExpressionRanges(elem, elemPath)
+		}
+	case *hclsyntax.ObjectConsExpr:
+		for _, item := range value.Items {
+			key, err := parser.evaluateKey(item.KeyExpr)
+			if err != nil {
+				continue
+			}
+			itemPath := joinPath(path, key)
+			parser.sourceMap.add(itemPath, hcl.RangeBetween(item.KeyExpr.Range(), item.ValueExpr.Range()))
+			parser.addExpressionRanges(item.ValueExpr, itemPath)
+		}
+	}
+}
+
+// withVariables returns a copy of the parser which can also reference the provided variables,
+// e.g. count.index while parsing the instances of a resource
+func (parser *Parser) withVariables(variables ValueMap) *Parser {
+	child := *parser
+	child.variables = make(ValueMap, len(parser.variables)+len(variables))
+	for name, value := range parser.variables {
+		child.variables[name] = value
+	}
+	for name, value := range variables {
+		child.variables[name] = value
+	}
+	return &child
+}
+
+func (parser *Parser) evalContext() *hcl.EvalContext {
+	return &hcl.EvalContext{
+		Functions: parser.functions,
+		Variables: parser.variables,
+	}
 }
 
 func (parser *Parser) parseExpression(expr hclsyntax.Expression) (interface{}, error) {
 	if parser.options.Simplify {
-		ctx := &hcl.EvalContext{
-			Functions: terraformFunctions,
-			Variables: parser.variables,
-		}
-		value, err := expr.Value(ctx)
+		value, err := expr.Value(parser.evalContext())
 		if err == nil {
-			return ctyjson.SimpleJSONValue{Value: value}, nil
+			// the branch picked by a sensitive condition would disclose it, but hcl drops the marks of the condition
+			if parser.hasSensitiveCondition(expr) {
+				value = value.Mark(sensitiveMark)
+			}
+			return redactValue(value), nil
 		}
 	}
 
@@ -179,6 +377,10 @@ func (parser *Parser) parseExpression(expr hclsyntax.Expression) (interface{}, e
 	case *hclsyntax.UnaryOpExpr:
 		return parser.parseUnary(value)
 	case *hclsyntax.TemplateExpr:
+		// a marker cannot be embedded in a string so the whole template is unknown
+		if parser.options.UnknownMarkers && !isLiteralTemplate(value) {
+			return parser.unknownExpr(value), nil
+		}
 		return parser.parseTemplate(value)
 	case *hclsyntax.TemplateWrapExpr:
 		return parser.parseExpression(value.Wrapped)
@@ -205,8 +407,14 @@ func (parser *Parser) parseExpression(expr hclsyntax.Expression) (interface{}, e
 			}
 		}
 		return out, nil
+	case *hclsyntax.ConditionalExpr:
+		return parser.parseConditional(value)
+	case *hclsyntax.ForExpr:
+		return parser.parseFor(value)
+	case *hclsyntax.SplatExpr:
+		return parser.parseSplat(value)
 	default:
-		return parser.wrapExpr(expr), nil
+		return parser.unknownExpr(expr), nil
 	}
 }
 
@@ -215,7 +423,7 @@ func (parser *Parser) parseUnary(v *hclsyntax.UnaryOpExpr) (interface{}, error)
 	if !isLiteral {
 		// If the expression after the operator isn't a literal, fall back to
 		// wrapping the expression with ${...}
-		return parser.wrapExpr(v), nil
+		return parser.unknownExpr(v), nil
 	}
 	val, err := v.Value(nil)
 	if err != nil {
@@ -261,6 +469,10 @@ func (parser *Parser) parseStringPart(expr hclsyntax.Expression) (string, error)
 	case *hclsyntax.TemplateJoinExpr:
 		return parser.parseTemplateFor(v.Tuple.(*hclsyntax.ForExpr))
 	default:
+		// a known part is embedded as a value, even if other parts of the template are not known
+		if s, ok := parser.evaluateString(expr); ok {
+			return s, nil
+		}
 		// treating as an embedded expression
 		return parser.wrapExpr(expr), nil
 	}
@@ -277,7 +489,25 @@ func (parser *Parser) parseKey(keyExpr hclsyntax.Expression) (string, error) {
 	return parser.parseStringPart(keyExpr)
 }
 
+// evaluateKey returns the key an object item will be emitted under, preferring its evaluated value
+func (parser *Parser) evaluateKey(keyExpr hclsyntax.Expression) (string, error) {
+	if parser.options.Simplify {
+		value, diags := keyExpr.Value(parser.evalContext())
+		// a sensitive key is not emitted
+		if !diags.HasErrors() && value.IsKnown() && !value.IsNull() && !value.ContainsMarked() {
+			if key, err := ctyconvert.Convert(value, cty.String); err == nil {
+				return key.AsString(), nil
+			}
+		}
+	}
+	return parser.parseKey(keyExpr)
+}
+
 func (parser *Parser) parseTemplateConditional(expr *hclsyntax.ConditionalExpr) (string, error) {
+	if branch, ok, err := parser.parseTemplateConditionalBranch(expr); ok {
+		return branch, err
+	}
+
 	var builder strings.Builder
 	builder.WriteString("%{if ")
 	builder.WriteString(parser.rangeSource(expr.Condition.Range()))
@@ -302,6 +532,10 @@ func (parser *Parser) parseTemplateConditional(expr *hclsyntax.ConditionalExpr)
 }
 
 func (parser *Parser) parseTemplateFor(expr *hclsyntax.ForExpr) (string, error) {
+	if elements, ok, err := parser.parseTemplateForElements(expr); ok {
+		return elements, err
+	}
+
 	var builder strings.Builder
 	builder.WriteString("%{for ")
 	if len(expr.KeyVar) > 0 {
@@ -1,6 +1,8 @@
 package terraform
 
 import (
+	"encoding/json"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -526,7 +528,434 @@ block "label_one" {
 			_, err := ParseHclToJson("test", tc.input, ModuleVariables{})
 			require.NotNil(t, err)
 			assert.Equal(t, tc.expected, err.Error())
-			assert.True(t, isUserError(err))
+			var customError *CustomError
+			require.True(t, errors.As(err, &customError))
+			assert.True(t, customError.IsUserError())
 		})
 	}
 }
+
+func TestParseHclToDocumentSourceMap(t *testing.T) {
+	input := `resource "aws_s3_bucket" "x" {
+	acl = "private"
+	tags = {
+		Name = "x"
+	}
+}
+
+resource "aws_security_group" "sg" {
+	ingress {
+		from_port = 22
+	}
+	ingress {
+		from_port = 443
+	}
+}`
+
+	_, sourceMap, err := ParseHclToDocument("main.tf", input, ModuleVariables{}, DefaultOptions())
+	require.Nil(t, err)
+	assert.Equal(t, SourceMap{
+		"resource.aws_s3_bucket.x": {
+			FileName: "main.tf",
+			Start:    SourcePos{Line: 1, Column: 1},
+			End:      SourcePos{Line: 6, Column: 2},
+		},
+		"resource.aws_s3_bucket.x.acl": {
+			FileName: "main.tf",
+			Start:    SourcePos{Line: 2, Column: 2},
+			End:      SourcePos{Line: 2, Column: 17},
+		},
+		"resource.aws_s3_bucket.x.tags": {
+			FileName: "main.tf",
+			Start:    SourcePos{Line: 3, Column: 2},
+			End:      SourcePos{Line: 5, Column: 3},
+		},
+		"resource.aws_s3_bucket.x.tags.Name": {
+			FileName: "main.tf",
+			Start:    SourcePos{Line: 4, Column: 3},
+			End:      SourcePos{Line: 4, Column: 13},
+		},
+		"resource.aws_security_group.sg": {
+			FileName: "main.tf",
+			Start:    SourcePos{Line: 8, Column: 1},
+			End:      SourcePos{Line: 15, Column: 2},
+		},
+		"resource.aws_security_group.sg.ingress[0]": {
+			FileName: "main.tf",
+			Start:    SourcePos{Line: 9, Column: 2},
+			End:      SourcePos{Line: 11, Column: 3},
+		},
+		"resource.aws_security_group.sg.ingress[0].from_port": {
+			FileName: "main.tf",
+			Start:    SourcePos{Line: 10, Column: 3},
+			End:      SourcePos{Line: 10, Column: 17},
+		},
+		"resource.aws_security_group.sg.ingress[1]": {
+			FileName: "main.tf",
+			Start:    SourcePos{Line: 12, Column: 2},
+			End:      SourcePos{Line: 14, Column: 3},
+		},
+		"resource.aws_security_group.sg.ingress[1].from_port": {
+			FileName: "main.tf",
+			Start:    SourcePos{Line: 13, Column: 3},
+			End:      SourcePos{Line: 13, Column: 18},
+		},
+	}, sourceMap)
+}
+
+func TestParseHclToDocumentExpandsCountAndForEach(t *testing.T) {
+	input := `
+resource "aws_instance" "web" {
+	count = var.instances
+	name  = "web-${count.index}"
+}
+
+resource "aws_s3_bucket" "buckets" {
+	for_each = var.buckets
+	bucket   = each.key
+	acl      = each.value
+}
+
+data "aws_ami" "images" {
+	for_each = local.images
+	name     = each.value
+}
+
+resource "aws_instance" "unknown" {
+	count = length(aws_instance.web)
+	name  = "unknown"
+}
+
+resource "aws_instance" "none" {
+	count = 0
+}`
+	variables := ModuleVariables{
+		inputs: ValueMap{
+			"instances": cty.NumberIntVal(2),
+			"buckets": cty.MapVal(map[string]cty.Value{
+				"logs":   cty.StringVal("private"),
+				"public": cty.StringVal("public-read"),
+			}),
+		},
+		locals: ValueMap{
+			"images": cty.SetVal([]cty.Value{cty.StringVal("ubuntu")}),
+		},
+	}
+	options := DefaultOptions()
+	options.ExpandCountAndForEach = true
+
+	actual, sourceMap, err := ParseHclToDocument("main.tf", input, variables, options)
+	require.Nil(t, err)
+	assert.Equal(t, JSON{
+		"resource": map[string]interface{}{
+			"aws_instance": map[string]interface{}{
+				`web["0"]`: map[string]interface{}{
+					"name": "web-0",
+				},
+				`web["1"]`: map[string]interface{}{
+					"name": "web-1",
+				},
+				"unknown": map[string]interface{}{
+					"count": "${length(aws_instance.web)}",
+					"name":  "unknown",
+				},
+			},
+			"aws_s3_bucket": map[string]interface{}{
+				`buckets["logs"]`: map[string]interface{}{
+					"bucket": "logs",
+					"acl":    "private",
+				},
+				`buckets["public"]`: map[string]interface{}{
+					"bucket": "public",
+					"acl":    "public-read",
+				},
+			},
+		},
+		"data": map[string]interface{}{
+			"aws_ami": map[string]interface{}{
+				`images["ubuntu"]`: map[string]interface{}{
+					"name": "ubuntu",
+				},
+			},
+		},
+	}, actual)
+	assert.Contains(t, sourceMap, `resource.aws_instance.web["1"].name`)
+	assert.NotContains(t, sourceMap, `resource.aws_instance.web["1"].count`)
+}
+
+func TestParseHclToJsonRendersDynamicBlocks(t *testing.T) {
+	type test struct {
+		name      string
+		input     string
+		variables ModuleVariables
+		expected  string
+	}
+
+	tests := []test{
+		{
+			name: "Dynamic block with a known for_each value",
+			input: `
+resource "aws_security_group" "sg" {
+	ingress {
+		from_port = 22
+	}
+	dynamic "ingress" {
+		for_each = var.ports
+		content {
+			from_port = ingress.value
+			rule      = ingress.key
+		}
+	}
+}`,
+			variables: ModuleVariables{
+				inputs: ValueMap{
+					"ports": cty.ListVal([]cty.Value{cty.NumberIntVal(80), cty.NumberIntVal(443)}),
+				},
+			},
+			expected: `{
+	"resource": {
+		"aws_security_group": {
+			"sg": {
+				"ingress": [
+					{
+						"from_port": 22
+					},
+					{
+						"from_port": 80,
+						"rule": 0
+					},
+					{
+						"from_port": 443,
+						"rule": 1
+					}
+				]
+			}
+		}
+	}
+}`,
+		},
+		{
+			name: "Nested dynamic blocks with a custom iterator",
+			input: `
+resource "aws_security_group" "sg" {
+	dynamic "ingress" {
+		for_each = var.rules
+		iterator = rule
+		content {
+			from_port = rule.key
+			dynamic "cidr" {
+				for_each = rule.value
+				content {
+					block = cidr.value
+					port  = rule.key
+				}
+			}
+		}
+	}
+}`,
+			variables: ModuleVariables{
+				inputs: ValueMap{
+					"rules": cty.MapVal(map[string]cty.Value{
+						"22": cty.ListVal([]cty.Value{cty.StringVal("10.0.0.0/8")}),
+					}),
+				},
+			},
+			expected: `{
+	"resource": {
+		"aws_security_group": {
+			"sg": {
+				"ingress": {
+					"cidr": {
+						"block": "10.0.0.0/8",
+						"port": "22"
+					},
+					"from_port": "22"
+				}
+			}
+		}
+	}
+}`,
+		},
+		{
+			name: "Dynamic block with an unknown for_each value",
+			input: `
+resource "aws_security_group" "sg" {
+	dynamic "ingress" {
+		for_each = var.ports
+		content {
+			from_port = ingress.value
+		}
+	}
+}`,
+			expected: `{
+	"resource": {
+		"aws_security_group": {
+			"sg": {
+				"ingress": {
+					"__dynamic__": {
+						"content": {
+							"from_port": "${ingress.value}"
+						},
+						"for_each": "${var.ports}"
+					}
+				}
+			}
+		}
+	}
+}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := ParseHclToJson("test", tc.input, tc.variables)
+			require.Nil(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestParseHclToDocumentEmitsUnknownMarkers(t *testing.T) {
+	input := `
+resource "aws_iam_role_policy" "policy" {
+	role     = aws_iam_role.x.arn
+	name     = "${var.prefix}-${aws_iam_role.x.name}"
+	literal  = "arn:aws:iam::$${account}:root"
+	known    = var.prefix
+	negated  = -aws_instance.web[0].count
+	index    = aws_instance.web["a"].id
+	missing  = var.missing
+	function = unsupported(var.prefix)
+	invalid  = var.prefix + 1
+	tags     = { Name = aws_iam_role.x.name }
+}`
+	variables := ModuleVariables{
+		inputs: ValueMap{
+			"prefix": cty.StringVal("test"),
+		},
+	}
+	options := DefaultOptions()
+	options.UnknownMarkers = true
+
+	actual, _, err := ParseHclToDocument("test.tf", input, variables, options)
+	require.Nil(t, err)
+
+	unknown := func(expression string, references []interface{}, reason string) JSON {
+		return JSON{
+			UNKNOWN_VALUE_KEY: map[string]interface{}{
+				"expression": expression,
+				"references": references,
+				"reason":     reason,
+			},
+		}
+	}
+	assert.Equal(t, JSON{
+		"resource": map[string]interface{}{
+			"aws_iam_role_policy": map[string]interface{}{
+				"policy": map[string]interface{}{
+					"role":     unknown("aws_iam_role.x.arn", []interface{}{"aws_iam_role.x.arn"}, UNRESOLVED_REFERENCE_REASON),
+					"name":     unknown(`"${var.prefix}-${aws_iam_role.x.name}"`, []interface{}{"var.prefix", "aws_iam_role.x.name"}, UNRESOLVED_REFERENCE_REASON),
+					"literal":  "arn:aws:iam::${account}:root",
+					"known":    "test",
+					"negated":  unknown("-aws_instance.web[0].count", []interface{}{"aws_instance.web[0].count"}, UNRESOLVED_REFERENCE_REASON),
+					"index":    unknown(`aws_instance.web["a"].id`, []interface{}{`aws_instance.web["a"].id`}, UNRESOLVED_REFERENCE_REASON),
+					"missing":  unknown("var.missing", []interface{}{"var.missing"}, UNRESOLVED_REFERENCE_REASON),
+					"function": unknown("unsupported(var.prefix)", []interface{}{"var.prefix"}, UNSUPPORTED_FUNCTION_REASON),
+					"invalid":  unknown("var.prefix + 1", []interface{}{"var.prefix"}, INVALID_EXPRESSION_REASON),
+					"tags": map[string]interface{}{
+						"Name": unknown("aws_iam_role.x.name", []interface{}{"aws_iam_role.x.name"}, UNRESOLVED_REFERENCE_REASON),
+					},
+				},
+			},
+		},
+	}, actual)
+}
+
+func TestParseHclToDocumentEmitsUnknownMarkersWithoutSimplify(t *testing.T) {
+	input := `
+resource "aws_s3_bucket" "logs" {
+	bucket = var.prefix
+	acl    = "private"
+}`
+	options := Options{UnknownMarkers: true}
+
+	actual, _, err := ParseHclToDocument("test.tf", input, ModuleVariables{}, options)
+	require.Nil(t, err)
+
+	assert.Equal(t, JSON{
+		"resource": map[string]interface{}{
+			"aws_s3_bucket": map[string]interface{}{
+				"logs": map[string]interface{}{
+					"bucket": map[string]interface{}{
+						UNKNOWN_VALUE_KEY: map[string]interface{}{
+							"expression": "var.prefix",
+							"references": []interface{}{"var.prefix"},
+							"reason":     NOT_EVALUATED_REASON,
+						},
+					},
+					"acl": "private",
+				},
+			},
+		},
+	}, actual)
+}
+
+func TestParseHclToDocumentPartiallyEvaluatesExpressions(t *testing.T) {
+	input := `
+resource "aws_security_group" "sg" {
+	conditional = var.enabled ? aws_vpc.main.id : "none"
+	skipped     = var.enabled ? "yes" : aws_vpc.main.id
+	unknown     = aws_vpc.main.enabled ? "yes" : "no"
+	list        = [for port in var.ports : { from = port, to = aws_vpc.main.port }]
+	filtered    = [for port in var.ports : port if port > 80]
+	map         = { for name, port in var.named : name => "${aws_vpc.main.id}:${port}" }
+	grouped     = { for name, port in var.named : port => name... }
+	splat       = [for port in var.ports : { id = port, vpc = aws_vpc.main.id }][*].id
+	names       = "%{ for name in var.names }${name}-${aws_vpc.main.id},%{ endfor }"
+	branch      = "%{ if var.enabled }on-${aws_vpc.main.id}%{ else }off%{ endif }"
+	parts       = "${var.names[0]}-${aws_vpc.main.id}"
+	collection  = [for id in aws_vpc.main.ids : id]
+}`
+	variables := ModuleVariables{
+		inputs: ValueMap{
+			"enabled": cty.True,
+			"ports":   cty.TupleVal([]cty.Value{cty.NumberIntVal(80), cty.NumberIntVal(443)}),
+			"named":   cty.ObjectVal(map[string]cty.Value{"http": cty.NumberIntVal(80), "https": cty.NumberIntVal(443), "alt": cty.NumberIntVal(80)}),
+			"names":   cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+		},
+	}
+
+	actual, _, err := ParseHclToDocument("test.tf", input, variables, DefaultOptions())
+	require.Nil(t, err)
+
+	assert.Equal(t, JSON{
+		"resource": map[string]interface{}{
+			"aws_security_group": map[string]interface{}{
+				"sg": map[string]interface{}{
+					"conditional": "${aws_vpc.main.id}",
+					"skipped":     "yes",
+					"unknown":     `${aws_vpc.main.enabled ? "yes" : "no"}`,
+					"list": []interface{}{
+						map[string]interface{}{"from": json.Number("80"), "to": "${aws_vpc.main.port}"},
+						map[string]interface{}{"from": json.Number("443"), "to": "${aws_vpc.main.port}"},
+					},
+					"filtered": []interface{}{json.Number("443")},
+					"map": map[string]interface{}{
+						"alt":   "${aws_vpc.main.id}:80",
+						"http":  "${aws_vpc.main.id}:80",
+						"https": "${aws_vpc.main.id}:443",
+					},
+					"grouped": map[string]interface{}{
+						"80":  []interface{}{"alt", "http"},
+						"443": []interface{}{"https"},
+					},
+					"splat":      []interface{}{json.Number("80"), json.Number("443")},
+					"names":      "a-${aws_vpc.main.id},b-${aws_vpc.main.id},",
+					"branch":     "on-${aws_vpc.main.id}",
+					"parts":      "a-${aws_vpc.main.id}",
+					"collection": "${[for id in aws_vpc.main.ids : id]}",
+				},
+			},
+		},
+	}, actual)
+}
@@ -0,0 +1,271 @@
+package terraform
+
+import (
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	ctyconvert "github.com/zclconf/go-cty/cty/convert"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// The expressions below are partially evaluated when they cannot be evaluated as a whole, e.g. because one of their leaves
+// references a resource: the parts which are known are emitted as values and only the unknown leaves are emitted as placeholders
+
+// parseConditional parses the branch of a conditional expression whose condition is known
+func (parser *Parser) parseConditional(expr *hclsyntax.ConditionalExpr) (interface{}, error) {
+	condition, ok := parser.evaluateCondition(expr.Condition)
+	if !ok {
+		return parser.unknownExpr(expr), nil
+	}
+	if condition {
+		return parser.parseExpression(expr.TrueResult)
+	}
+	return parser.parseExpression(expr.FalseResult)
+}
+
+// parseFor parses a for expression over a known collection into a list or an object, of which the elements may be unknown
+func (parser *Parser) parseFor(expr *hclsyntax.ForExpr) (interface{}, error) {
+	elements, ok := parser.forElements(expr)
+	if !ok {
+		return parser.unknownExpr(expr), nil
+	}
+
+	if expr.KeyExpr == nil {
+		list := []interface{}{}
+		for _, element := range elements {
+			value, err := element.parseExpression(expr.ValExpr)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	}
+
+	out := make(JSON)
+	for _, element := range elements {
+		key, ok := element.evaluateString(expr.KeyExpr)
+		if !ok {
+			return parser.unknownExpr(expr), nil
+		}
+		value, err := element.parseExpression(expr.ValExpr)
+		if err != nil {
+			return nil, err
+		}
+
+		if expr.Group {
+			group, _ := out[key].([]interface{})
+			out[key] = append(group, value)
+			continue
+		}
+		// like Terraform, the keys must be unique unless the values are grouped
+		if _, exists := out[key]; exists {
+			return parser.unknownExpr(expr), nil
+		}
+		out[key] = value
+	}
+	return out, nil
+}
+
+// parseSplat parses a splat expression over a known list into the list of the values found in each element
+func (parser *Parser) parseSplat(expr *hclsyntax.SplatExpr) (interface{}, error) {
+	traversal, ok := splatTraversal(expr)
+	if !parser.options.Simplify || !ok {
+		return parser.unknownExpr(expr), nil
+	}
+
+	source, err := parser.parseExpression(expr.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	var elements []interface{}
+	switch source := source.(type) {
+	case []interface{}:
+		elements = source
+	case ctyjson.SimpleJSONValue:
+		value := source.Value
+		switch {
+		case value.IsNull():
+			// like Terraform, a splat over null is an empty list
+		case value.Type().IsListType() || value.Type().IsTupleType() || value.Type().IsSetType():
+			for it := value.ElementIterator(); it.Next(); {
+				_, element := it.Element()
+				elements = append(elements, ctyjson.SimpleJSONValue{Value: element})
+			}
+		default:
+			elements = []interface{}{source}
+		}
+	case JSON:
+		if isPlaceholder(source) {
+			return parser.unknownExpr(expr), nil
+		}
+		elements = []interface{}{source}
+	default:
+		return parser.unknownExpr(expr), nil
+	}
+
+	list := []interface{}{}
+	for _, element := range elements {
+		value, ok := traverseElement(element, traversal)
+		if !ok {
+			return parser.unknownExpr(expr), nil
+		}
+		list = append(list, value)
+	}
+	return list, nil
+}
+
+// parseTemplateConditionalBranch renders the branch of a template conditional whose condition is known
+func (parser *Parser) parseTemplateConditionalBranch(expr *hclsyntax.ConditionalExpr) (string, bool, error) {
+	condition, ok := parser.evaluateCondition(expr.Condition)
+	if !ok {
+		return "", false, nil
+	}
+	branch := expr.FalseResult
+	if condition {
+		branch = expr.TrueResult
+	}
+	result, err := parser.parseStringPart(branch)
+	return result, true, err
+}
+
+// parseTemplateForElements renders a template for directive over a known collection
+func (parser *Parser) parseTemplateForElements(expr *hclsyntax.ForExpr) (string, bool, error) {
+	elements, ok := parser.forElements(expr)
+	if !ok {
+		return "", false, nil
+	}
+
+	var builder strings.Builder
+	for _, element := range elements {
+		result, err := element.parseStringPart(expr.ValExpr)
+		if err != nil {
+			return "", true, err
+		}
+		builder.WriteString(result)
+	}
+	return builder.String(), true, nil
+}
+
+// forElements returns a parser for each element of the collection of a for expression which satisfies its condition,
+// with the key and value symbols of the expression bound to the element
+func (parser *Parser) forElements(expr *hclsyntax.ForExpr) ([]*Parser, bool) {
+	if !parser.options.Simplify {
+		return nil, false
+	}
+	collection, diags := expr.CollExpr.Value(parser.evalContext())
+	if diags.HasErrors() || !collection.IsKnown() || collection.IsNull() || collection.IsMarked() || !collection.CanIterateElements() {
+		return nil, false
+	}
+
+	var elements []*Parser
+	for it := collection.ElementIterator(); it.Next(); {
+		key, value := it.Element()
+		variables := ValueMap{expr.ValVar: value}
+		if expr.KeyVar != "" {
+			variables[expr.KeyVar] = key
+		}
+		element := parser.withVariables(variables)
+
+		if expr.CondExpr != nil {
+			include, ok := element.evaluateCondition(expr.CondExpr)
+			if !ok {
+				return nil, false
+			}
+			if !include {
+				continue
+			}
+		}
+		elements = append(elements, element)
+	}
+	return elements, true
+}
+
+// evaluateCondition returns the value of a condition when it is known
+func (parser *Parser) evaluateCondition(expr hclsyntax.Expression) (bool, bool) {
+	if !parser.options.Simplify {
+		return false, false
+	}
+	value, diags := expr.Value(parser.evalContext())
+	// a sensitive condition is not evaluated, as the branch it picks would disclose it
+	if diags.HasErrors() || !value.IsKnown() || value.IsNull() || value.IsMarked() {
+		return false, false
+	}
+	value, err := ctyconvert.Convert(value, cty.Bool)
+	if err != nil {
+		return false, false
+	}
+	return value.True(), true
+}
+
+// evaluateString returns the value of an expression converted to a string when it is known
+func (parser *Parser) evaluateString(expr hclsyntax.Expression) (string, bool) {
+	if !parser.options.Simplify {
+		return "", false
+	}
+	value, diags := expr.Value(parser.evalContext())
+	if diags.HasErrors() || !value.IsKnown() || value.IsNull() || value.IsMarked() {
+		return "", false
+	}
+	value, err := ctyconvert.Convert(value, cty.String)
+	if err != nil {
+		return "", false
+	}
+	return value.AsString(), true
+}
+
+// splatTraversal returns the traversal a splat expression applies to each element, e.g. .id for aws_instance.web[*].id
+func splatTraversal(expr *hclsyntax.SplatExpr) (hcl.Traversal, bool) {
+	switch each := expr.Each.(type) {
+	case *hclsyntax.AnonSymbolExpr:
+		return nil, each == expr.Item
+	case *hclsyntax.RelativeTraversalExpr:
+		return each.Traversal, each.Source == expr.Item
+	}
+	return nil, false
+}
+
+// traverseElement applies the traversal of a splat expression to one of the elements of its source
+// The traversal of an unknown element is unknown, the traversal of a redacted element is redacted
+func traverseElement(element interface{}, traversal hcl.Traversal) (interface{}, bool) {
+	if len(traversal) == 0 {
+		return element, true
+	}
+
+	switch element := element.(type) {
+	case ctyjson.SimpleJSONValue:
+		value, diags := traversal.TraverseRel(element.Value)
+		if diags.HasErrors() {
+			return nil, false
+		}
+		return redactValue(value), true
+	case string:
+		expression, ok := interpolatedExpression(element)
+		if !ok {
+			return nil, false
+		}
+		return "${" + expression + traversalString(traversal) + "}", true
+	case JSON:
+		if _, ok := element[REDACTED_VALUE_KEY]; ok && len(element) == 1 {
+			return element, true
+		}
+		if marker, ok := element[UNKNOWN_VALUE_KEY].(unknownValue); ok && len(element) == 1 {
+			marker.Expression += traversalString(traversal)
+			return JSON{UNKNOWN_VALUE_KEY: marker}, true
+		}
+	}
+	return traverseDocument(element, traversal)
+}
+
+// isPlaceholder tells whether a parsed value stands for a value which is not known or which is redacted
+func isPlaceholder(value JSON) bool {
+	if len(value) != 1 {
+		return false
+	}
+	_, isUnknown := value[UNKNOWN_VALUE_KEY]
+	_, isRedacted := value[REDACTED_VALUE_KEY]
+	return isUnknown || isRedacted
+}
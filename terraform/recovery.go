@@ -0,0 +1,95 @@
+package terraform
+
+import (
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// recoverHclFile returns the part of a file with syntax errors which parsed cleanly, alongside the ranges which were dropped
+// The top-level blocks and attributes which overlap an error are dropped as a whole, as well as the errors outside of them,
+// so that a half-parsed block is never emitted
+// Only the native syntax can be recovered, as the JSON syntax does not return a partial file
+func recoverHclFile(file *hcl.File, diagnostics hcl.Diagnostics) (*hcl.File, []SourceRange, bool) {
+	if file == nil {
+		return nil, nil, false
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, nil, false
+	}
+
+	var errorRanges []hcl.Range
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity != hcl.DiagError {
+			continue
+		}
+		// an error which cannot be located could be anywhere in the file
+		if diagnostic.Subject == nil {
+			return nil, nil, false
+		}
+		errorRanges = append(errorRanges, *diagnostic.Subject)
+	}
+
+	recovered := &hclsyntax.Body{
+		Attributes: hclsyntax.Attributes{},
+		SrcRange:   body.SrcRange,
+		EndRange:   body.EndRange,
+	}
+	var damagedRanges []SourceRange
+	covered := make([]bool, len(errorRanges))
+	isDamaged := func(r hcl.Range) bool {
+		damaged := false
+		for i, errorRange := range errorRanges {
+			if overlaps(r, errorRange) {
+				covered[i] = true
+				damaged = true
+			}
+		}
+		return damaged
+	}
+
+	for name, attribute := range body.Attributes {
+		if isDamaged(attribute.SrcRange) {
+			damagedRanges = append(damagedRanges, newSourceRange(attribute.SrcRange))
+			continue
+		}
+		recovered.Attributes[name] = attribute
+	}
+	for _, block := range body.Blocks {
+		if isDamaged(block.Range()) {
+			damagedRanges = append(damagedRanges, newSourceRange(block.Range()))
+			continue
+		}
+		recovered.Blocks = append(recovered.Blocks, block)
+	}
+	for i, errorRange := range errorRanges {
+		if !covered[i] {
+			damagedRanges = append(damagedRanges, newSourceRange(errorRange))
+		}
+	}
+	sortSourceRanges(damagedRanges)
+
+	return &hcl.File{
+		Body:  recovered,
+		Bytes: file.Bytes,
+	}, damagedRanges, true
+}
+
+// overlaps tells whether two ranges of the same file share a byte, or whether an empty range is within the other one
+func overlaps(r hcl.Range, other hcl.Range) bool {
+	if other.Start.Byte == other.End.Byte {
+		return r.Start.Byte <= other.Start.Byte && other.Start.Byte <= r.End.Byte
+	}
+	return r.Start.Byte < other.End.Byte && other.Start.Byte < r.End.Byte
+}
+
+func sortSourceRanges(ranges []SourceRange) {
+	sort.Slice(ranges, func(i, j int) bool {
+		if ranges[i].Start.Line != ranges[j].Start.Line {
+			return ranges[i].Start.Line < ranges[j].Start.Line
+		}
+		return ranges[i].Start.Column < ranges[j].Start.Column
+	})
+}
@@ -0,0 +1,306 @@
+package terraform
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// symbolTable holds the body of the resource and data blocks of a module, keyed by their address
+// e.g. aws_s3_bucket.logs, data.aws_ami.ubuntu or aws_instance.web["0"] for an expanded instance
+type symbolTable struct {
+	blocks map[string]JSON
+	// resolved caches the resolved value of each reference, so that a reference used many times is resolved once
+	resolved map[string]interface{}
+	// visiting holds the references being resolved, in the order they are followed, to detect the references
+	// which refer to each other
+	visiting []string
+	// cyclic holds the references which are part of a cycle, which resolve to a typed reference to themselves
+	cyclic map[string]bool
+}
+
+// resolveReferences replaces the references to the attributes of the resource and data blocks of the module by their value
+// when it is known, and by a typed reference to the block otherwise, e.g.
+// {"__reference__": {"address": "aws_s3_bucket.logs", "attribute": "id", "expression": "aws_s3_bucket.logs.id"}}
+func resolveReferences(parseRes *ParseModuleResult) {
+	symbols := buildSymbolTable(parseRes.ParsedFiles)
+
+	resolvedFiles := make(map[string]JSON, len(parseRes.ParsedFiles))
+	for fileName, document := range parseRes.ParsedFiles {
+		resolvedFiles[fileName] = symbols.resolveValue(document).(JSON)
+	}
+	parseRes.ParsedFiles = resolvedFiles
+}
+
+func buildSymbolTable(parsedFiles map[string]JSON) *symbolTable {
+	fileNames := make([]string, 0, len(parsedFiles))
+	for fileName := range parsedFiles {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	symbols := &symbolTable{
+		blocks:   map[string]JSON{},
+		resolved: map[string]interface{}{},
+		cyclic:   map[string]bool{},
+	}
+	for _, fileName := range fileNames {
+		for _, blockType := range []string{"resource", "data"} {
+			resourceTypes, _ := parsedFiles[fileName][blockType].(JSON)
+			for resourceType, resources := range resourceTypes {
+				resourcesByName, _ := resources.(JSON)
+				for name, body := range resourcesByName {
+					body, ok := body.(JSON)
+					if !ok {
+						continue
+					}
+					address := resourceType + "." + name
+					if blockType == "data" {
+						address = "data." + address
+					}
+					symbols.blocks[address] = body
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+func (symbols *symbolTable) resolveValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case JSON:
+		if expression, ok := unknownExpression(value); ok {
+			if resolved, ok := symbols.resolveExpression(expression); ok {
+				return resolved
+			}
+			return value
+		}
+		resolved := make(JSON, len(value))
+		for key, element := range value {
+			resolved[key] = symbols.resolveValue(element)
+		}
+		return resolved
+	case []interface{}:
+		resolved := make([]interface{}, 0, len(value))
+		for _, element := range value {
+			resolved = append(resolved, symbols.resolveValue(element))
+		}
+		return resolved
+	case string:
+		if expression, ok := interpolatedExpression(value); ok {
+			if resolved, ok := symbols.resolveExpression(expression); ok {
+				return resolved
+			}
+		}
+		return value
+	default:
+		return value
+	}
+}
+
+// resolveExpression resolves an expression which is a reference to an attribute of a resource or data block of the module
+func (symbols *symbolTable) resolveExpression(expression string) (interface{}, bool) {
+	expr, diags := hclsyntax.ParseExpression([]byte(expression), "", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, false
+	}
+	traversalExpr, ok := expr.(*hclsyntax.ScopeTraversalExpr)
+	if !ok {
+		return nil, false
+	}
+	traversal := traversalExpr.Traversal
+
+	body, addressLength, ok := symbols.lookup(traversal)
+	if !ok {
+		return nil, false
+	}
+
+	typedReference := newTypedReference(traversal, addressLength)
+	key := traversalString(traversal)
+	if resolved, ok := symbols.resolved[key]; ok {
+		return resolved, true
+	}
+	for i, visiting := range symbols.visiting {
+		if visiting == key {
+			// the references followed since this one refer back to it
+			for _, cyclic := range symbols.visiting[i:] {
+				symbols.cyclic[cyclic] = true
+			}
+			return typedReference, true
+		}
+	}
+
+	symbols.visiting = append(symbols.visiting, key)
+	resolved := symbols.resolveTraversal(body, traversal[addressLength:], typedReference)
+	symbols.visiting = symbols.visiting[:len(symbols.visiting)-1]
+	if symbols.cyclic[key] {
+		resolved = typedReference
+	}
+	symbols.resolved[key] = resolved
+	return resolved, true
+}
+
+// resolveTraversal resolves the attribute of a block, which is replaced by the typed reference when its value is unknown
+func (symbols *symbolTable) resolveTraversal(body JSON, traversal hcl.Traversal, typedReference JSON) interface{} {
+	// an attribute which is not set in the configuration is computed by the provider
+	value, ok := traverseDocument(body, traversal)
+	if !ok {
+		return typedReference
+	}
+	resolved := symbols.resolveValue(value)
+	if isUnknownPlaceholder(resolved) {
+		return typedReference
+	}
+	return resolved
+}
+
+// newTypedReference returns the reference to the attribute of a block, of which the address is made of the first steps of the traversal
+func newTypedReference(traversal hcl.Traversal, addressLength int) JSON {
+	typedReference := JSON{
+		"address":    traversalString(traversal[:addressLength]),
+		"expression": traversalString(traversal),
+	}
+	if attribute := strings.TrimPrefix(traversalString(traversal[addressLength:]), "."); attribute != "" {
+		typedReference["attribute"] = attribute
+	}
+	return JSON{REFERENCE_KEY: typedReference}
+}
+
+// lookup returns the body of the block a traversal refers to, alongside the number of steps which make up its address
+func (symbols *symbolTable) lookup(traversal hcl.Traversal) (JSON, int, bool) {
+	addressLength := 2
+	if traversal.RootName() == "data" {
+		addressLength = 3
+	}
+	if len(traversal) < addressLength {
+		return nil, 0, false
+	}
+
+	var names []string
+	for _, step := range traversal[:addressLength] {
+		switch step := step.(type) {
+		case hcl.TraverseRoot:
+			names = append(names, step.Name)
+		case hcl.TraverseAttr:
+			names = append(names, step.Name)
+		default:
+			return nil, 0, false
+		}
+	}
+	address := strings.Join(names, ".")
+
+	// the instance of a resource which uses count or for_each, e.g. aws_instance.web[0]
+	if len(traversal) > addressLength {
+		if index, ok := traversal[addressLength].(hcl.TraverseIndex); ok {
+			if key, ok := indexKey(index.Key); ok {
+				instanceAddress := strings.Join(names[:len(names)-1], ".") + "." + FormatInstanceName(names[len(names)-1], key)
+				if body, ok := symbols.blocks[instanceAddress]; ok {
+					return body, addressLength + 1, true
+				}
+			}
+			// the instances are not expanded so they all share the body of the block
+			if body, ok := symbols.blocks[address]; ok {
+				return body, addressLength + 1, true
+			}
+		}
+	}
+
+	body, ok := symbols.blocks[address]
+	return body, addressLength, ok
+}
+
+// traverseDocument returns the value found at the end of a relative traversal, e.g. .versioning[0].enabled
+func traverseDocument(value interface{}, traversal hcl.Traversal) (interface{}, bool) {
+	for _, step := range traversal {
+		switch step := step.(type) {
+		case hcl.TraverseAttr:
+			object, ok := value.(JSON)
+			if !ok {
+				return nil, false
+			}
+			if value, ok = object[step.Name]; !ok {
+				return nil, false
+			}
+		case hcl.TraverseIndex:
+			key, ok := indexKey(step.Key)
+			if !ok {
+				return nil, false
+			}
+			switch collection := value.(type) {
+			case []interface{}:
+				index, err := strconv.Atoi(key)
+				if err != nil || index < 0 || index >= len(collection) {
+					return nil, false
+				}
+				value = collection[index]
+			case JSON:
+				// a single block is emitted as an object rather than a list of one object
+				if element, ok := collection[key]; ok {
+					value = element
+				} else if key != "0" {
+					return nil, false
+				}
+			default:
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func indexKey(key cty.Value) (string, bool) {
+	if !key.IsKnown() || key.IsNull() {
+		return "", false
+	}
+	switch key.Type() {
+	case cty.String:
+		return key.AsString(), true
+	case cty.Number:
+		return key.AsBigFloat().Text('f', -1), true
+	}
+	return "", false
+}
+
+// interpolatedExpression returns the expression of a string which is a single interpolation, e.g. ${aws_s3_bucket.logs.id}
+func interpolatedExpression(value string) (string, bool) {
+	if !strings.HasPrefix(value, "${") || !strings.HasSuffix(value, "}") {
+		return "", false
+	}
+	expr, diags := hclsyntax.ParseTemplate([]byte(value), "", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return "", false
+	}
+	if _, ok := expr.(*hclsyntax.TemplateWrapExpr); !ok {
+		return "", false
+	}
+	return value[2 : len(value)-1], true
+}
+
+// unknownExpression returns the expression of a structured unknown marker
+func unknownExpression(value JSON) (string, bool) {
+	marker, ok := value[UNKNOWN_VALUE_KEY].(JSON)
+	if !ok || len(value) != 1 {
+		return "", false
+	}
+	expression, ok := marker["expression"].(string)
+	return expression, ok
+}
+
+// isUnknownPlaceholder tells whether a value is the placeholder of an expression which could not be evaluated
+func isUnknownPlaceholder(value interface{}) bool {
+	switch value := value.(type) {
+	case string:
+		_, ok := interpolatedExpression(value)
+		return ok
+	case JSON:
+		_, ok := unknownExpression(value)
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package terraform
+
+import (
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Logic inspired from https://github.com/hashicorp/terraform/blob/v1.5.0/internal/lang/marks/marks.go
+
+// valueMark is a mark of the cty values evaluated by the parser
+type valueMark string
+
+// sensitiveMark marks the values of the variables declared with sensitive = true, which cty propagates to the values derived from them
+const sensitiveMark = valueMark("sensitive")
+
+// redactValue converts an evaluated value into the parser output, replacing the sensitive values it holds by a redaction placeholder,
+// e.g. {"__redacted__": "sensitive"}
+func redactValue(value cty.Value) interface{} {
+	if value.HasMark(sensitiveMark) {
+		return JSON{REDACTED_VALUE_KEY: SENSITIVE_REDACTION_REASON}
+	}
+	if !value.ContainsMarked() {
+		return ctyjson.SimpleJSONValue{Value: value}
+	}
+
+	value, _ = value.Unmark()
+	valueType := value.Type()
+	switch {
+	case value.IsNull() || !value.IsKnown():
+		return ctyjson.SimpleJSONValue{Value: value}
+	case valueType.IsObjectType() || valueType.IsMapType():
+		out := make(JSON, value.LengthInt())
+		for it := value.ElementIterator(); it.Next(); {
+			key, element := it.Element()
+			out[key.AsString()] = redactValue(element)
+		}
+		return out
+	case valueType.IsListType() || valueType.IsTupleType() || valueType.IsSetType():
+		out := make([]interface{}, 0, value.LengthInt())
+		for it := value.ElementIterator(); it.Next(); {
+			_, element := it.Element()
+			out = append(out, redactValue(element))
+		}
+		return out
+	default:
+		return ctyjson.SimpleJSONValue{Value: value}
+	}
+}
+
+// markSensitiveInputVariables marks the values of the variables declared with sensitive = true
+func markSensitiveInputVariables(inputs ValueMap, declarations VariableDeclarations) ValueMap {
+	markedInputs := make(ValueMap, len(inputs))
+	for name, value := range inputs {
+		if declarations[name].sensitive {
+			value = value.Mark(sensitiveMark)
+		}
+		markedInputs[name] = value
+	}
+	return markedInputs
+}
+
+// isSensitiveVariable tells whether a variable block is declared with sensitive = true, in which case its default is redacted
+func isSensitiveVariable(body *hclsyntax.Body) bool {
+	attribute, ok := body.Attributes["sensitive"]
+	if !ok {
+		return false
+	}
+	value, diags := attribute.Expr.Value(nil)
+	return !diags.HasErrors() && value.Type() == cty.Bool && value.IsKnown() && !value.IsNull() && value.True()
+}
+
+// hasSensitiveCondition tells whether an expression holds a conditional expression of which the condition
+// refers to a sensitive value, e.g. var.password == "hunter2" ? "yes" : "no"
+func (parser *Parser) hasSensitiveCondition(expr hclsyntax.Expression) bool {
+	if !parser.referencesSensitiveValue(expr) {
+		return false
+	}
+	found := false
+	hclsyntax.VisitAll(expr, func(node hclsyntax.Node) hcl.Diagnostics {
+		if conditional, ok := node.(*hclsyntax.ConditionalExpr); ok && !found {
+			found = parser.referencesSensitiveValue(conditional.Condition)
+		}
+		return nil
+	})
+	return found
+}
+
+// referencesSensitiveValue tells whether an expression refers to a variable holding a sensitive value
+// A traversal which cannot be followed to its end, e.g. var.tokens[each.key], is checked up to where it can be followed
+func (parser *Parser) referencesSensitiveValue(expr hcl.Expression) bool {
+	ctx := parser.evalContext()
+	for _, traversal := range expr.Variables() {
+		for i := len(traversal); i > 0; i-- {
+			value, diags := traversal[:i].TraverseAbs(ctx)
+			if diags.HasErrors() {
+				continue
+			}
+			if value.ContainsMarked() {
+				return true
+			}
+			break
+		}
+	}
+	return false
+}
+
+// recordRedactedPaths records the JSON paths of the redaction placeholders of each parsed file
+func recordRedactedPaths(parseRes *ParseModuleResult) {
+	for fileName, document := range parseRes.ParsedFiles {
+		var paths []string
+		collectRedactedPaths(document, "", &paths)
+		if len(paths) == 0 {
+			continue
+		}
+		sort.Strings(paths)
+		parseRes.RedactedPaths[fileName] = paths
+	}
+}
+
+func collectRedactedPaths(value interface{}, path string, paths *[]string) {
+	switch value := value.(type) {
+	case JSON:
+		if reason, ok := value[REDACTED_VALUE_KEY]; ok && len(value) == 1 && reason == SENSITIVE_REDACTION_REASON {
+			*paths = append(*paths, path)
+			return
+		}
+		for key, element := range value {
+			collectRedactedPaths(element, joinPath(path, key), paths)
+		}
+	case []interface{}:
+		for i, element := range value {
+			collectRedactedPaths(element, indexPath(path, i), paths)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package terraform
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// SourcePos is a line and column position in a source file, both starting at 1
+type SourcePos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// SourceRange records the file and the start and end positions a value was declared at
+type SourceRange struct {
+	FileName string    `json:"fileName"`
+	Start    SourcePos `json:"start"`
+	End      SourcePos `json:"end"`
+}
+
+// SourceMap maps the JSON paths emitted by the parser (e.g. resource.aws_s3_bucket.x.acl) to their source ranges
+type SourceMap map[string]SourceRange
+
+func newSourceRange(r hcl.Range) SourceRange {
+	return SourceRange{
+		FileName: r.Filename,
+		Start: SourcePos{
+			Line:   r.Start.Line,
+			Column: r.Start.Column,
+		},
+		End: SourcePos{
+			Line:   r.End.Line,
+			Column: r.End.Column,
+		},
+	}
+}
+
+func (sourceMap SourceMap) add(path string, r hcl.Range) {
+	if sourceMap == nil || path == "" {
+		return
+	}
+	sourceMap[path] = newSourceRange(r)
+}
+
+// movePrefix renames the entry for a path and all the entries nested under it,
+// e.g. when a single block turns into a list of blocks and its path gains an index
+func (sourceMap SourceMap) movePrefix(oldPath string, newPath string) {
+	moved := SourceMap{}
+	for path, sourceRange := range sourceMap {
+		if path == oldPath || strings.HasPrefix(path, oldPath+".") || strings.HasPrefix(path, oldPath+"[") {
+			delete(sourceMap, path)
+			moved[newPath+strings.TrimPrefix(path, oldPath)] = sourceRange
+		}
+	}
+	for path, sourceRange := range moved {
+		sourceMap[path] = sourceRange
+	}
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func indexPath(path string, index int) string {
+	return fmt.Sprintf("%s[%d]", path, index)
+}
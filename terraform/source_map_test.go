@@ -0,0 +1,25 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceMapMovePrefix(t *testing.T) {
+	sourceMap := SourceMap{
+		"block":         {FileName: "a"},
+		"block.attr":    {FileName: "b"},
+		"block[1]":      {FileName: "c"},
+		"blocks":        {FileName: "d"},
+		"other.block.x": {FileName: "e"},
+	}
+	sourceMap.movePrefix("block", "block[0]")
+	assert.Equal(t, SourceMap{
+		"block[0]":      {FileName: "a"},
+		"block[0].attr": {FileName: "b"},
+		"block[0][1]":   {FileName: "c"},
+		"blocks":        {FileName: "d"},
+		"other.block.x": {FileName: "e"},
+	}, sourceMap)
+}
@@ -0,0 +1,267 @@
+package terraform
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+	ctyconvert "github.com/zclconf/go-cty/cty/convert"
+)
+
+// Logic inspired from https://github.com/hashicorp/terraform/blob/v1.3.0/internal/typeexpr/get_type.go
+// and https://github.com/hashicorp/terraform/blob/v1.3.0/internal/typeexpr/defaults.go
+
+const invalidTypeSummary = "Invalid type specification"
+
+// typeConstraint is the type constraint of a variable alongside the default values of its optional object attributes
+type typeConstraint struct {
+	ty cty.Type
+	// defaults holds the default values of the optional attributes of an object type
+	defaults map[string]cty.Value
+	// children holds the constraints nested in this one which have defaults, keyed by object attribute name,
+	// by tuple element index, or by an empty key for the elements of a collection
+	children map[string]*typeConstraint
+}
+
+// parseTypeConstraint parses the expression of the type argument of a variable block, e.g. list(object({ a = optional(string, "b") }))
+func parseTypeConstraint(expr hcl.Expression) (*typeConstraint, hcl.Diagnostics) {
+	return parseTypeExpr(expr, false)
+}
+
+func parseTypeExpr(expr hcl.Expression, isObjectAttribute bool) (*typeConstraint, hcl.Diagnostics) {
+	switch keyword := hcl.ExprAsKeyword(expr); keyword {
+	case "bool":
+		return &typeConstraint{ty: cty.Bool}, nil
+	case "string":
+		return &typeConstraint{ty: cty.String}, nil
+	case "number":
+		return &typeConstraint{ty: cty.Number}, nil
+	case "any":
+		return &typeConstraint{ty: cty.DynamicPseudoType}, nil
+	case "":
+		// the expression is either a legacy type constraint or a type constructor call
+	default:
+		return nil, invalidTypeDiagnostics(expr.Range(), fmt.Sprintf("The keyword %q is not a valid type specification.", keyword))
+	}
+
+	// legacy type constraints are quoted, e.g. "string", "list" or "map"
+	if value, hclDiags := expr.Value(nil); !hclDiags.HasErrors() && value.IsKnown() && !value.IsNull() && value.Type() == cty.String {
+		switch value.AsString() {
+		case "string":
+			return &typeConstraint{ty: cty.String}, nil
+		case "list":
+			return &typeConstraint{ty: cty.List(cty.DynamicPseudoType)}, nil
+		case "map":
+			return &typeConstraint{ty: cty.Map(cty.DynamicPseudoType)}, nil
+		}
+		return nil, invalidTypeDiagnostics(expr.Range(), fmt.Sprintf("The legacy type constraint %q is not valid.", value.AsString()))
+	}
+
+	call, hclDiags := hcl.ExprCall(expr)
+	if hclDiags.HasErrors() {
+		return nil, invalidTypeDiagnostics(expr.Range(), "A type specification is either a primitive type keyword (bool, number, string) or a complex type constructor call, like list(string).")
+	}
+
+	switch call.Name {
+	case "list", "set", "map":
+		if len(call.Arguments) != 1 {
+			return nil, invalidTypeDiagnostics(call.ArgsRange, fmt.Sprintf("The %s type constructor requires one argument specifying the element type.", call.Name))
+		}
+		element, hclDiags := parseTypeExpr(call.Arguments[0], false)
+		if hclDiags.HasErrors() {
+			return nil, hclDiags
+		}
+		constraint := &typeConstraint{ty: collectionType(call.Name, element.ty)}
+		constraint.addChild("", element)
+		return constraint, nil
+	case "tuple":
+		if len(call.Arguments) != 1 {
+			return nil, invalidTypeDiagnostics(call.ArgsRange, "The tuple type constructor requires one argument specifying the element types as a list.")
+		}
+		elementExprs, hclDiags := hcl.ExprList(call.Arguments[0])
+		if hclDiags.HasErrors() {
+			return nil, invalidTypeDiagnostics(call.Arguments[0].Range(), "Tuple type constructor requires a list of element types.")
+		}
+		constraint := &typeConstraint{}
+		elementTypes := make([]cty.Type, 0, len(elementExprs))
+		for i, elementExpr := range elementExprs {
+			element, hclDiags := parseTypeExpr(elementExpr, false)
+			if hclDiags.HasErrors() {
+				return nil, hclDiags
+			}
+			elementTypes = append(elementTypes, element.ty)
+			constraint.addChild(strconv.Itoa(i), element)
+		}
+		constraint.ty = cty.Tuple(elementTypes)
+		return constraint, nil
+	case "object":
+		if len(call.Arguments) != 1 {
+			return nil, invalidTypeDiagnostics(call.ArgsRange, "The object type constructor requires one argument specifying the attribute types and values as a map.")
+		}
+		return parseObjectTypeExpr(call.Arguments[0])
+	case "optional":
+		if !isObjectAttribute {
+			return nil, invalidTypeDiagnostics(call.ArgsRange, "Keyword \"optional\" is valid only as a modifier for object type attributes.")
+		}
+		return nil, invalidTypeDiagnostics(call.ArgsRange, "The optional modifier is only valid directly as the type of an object attribute.")
+	default:
+		return nil, invalidTypeDiagnostics(expr.Range(), fmt.Sprintf("Keyword %q is not a valid type constructor.", call.Name))
+	}
+}
+
+func parseObjectTypeExpr(expr hcl.Expression) (*typeConstraint, hcl.Diagnostics) {
+	attributeExprs, hclDiags := hcl.ExprMap(expr)
+	if hclDiags.HasErrors() {
+		return nil, invalidTypeDiagnostics(expr.Range(), "Object type constructor requires a map whose keys are attribute names and whose values are the corresponding attribute types.")
+	}
+
+	constraint := &typeConstraint{}
+	attributeTypes := make(map[string]cty.Type, len(attributeExprs))
+	var optionalAttributes []string
+	for _, attributeExpr := range attributeExprs {
+		name := hcl.ExprAsKeyword(attributeExpr.Key)
+		if name == "" {
+			nameValue, hclDiags := attributeExpr.Key.Value(nil)
+			if hclDiags.HasErrors() || !nameValue.IsKnown() || nameValue.IsNull() || nameValue.Type() != cty.String {
+				return nil, invalidTypeDiagnostics(attributeExpr.Key.Range(), "Object constructor map keys must be attribute names.")
+			}
+			name = nameValue.AsString()
+		}
+
+		attributeTypeExpr := attributeExpr.Value
+		var defaultExpr hcl.Expression
+		if call, hclDiags := hcl.ExprCall(attributeTypeExpr); !hclDiags.HasErrors() && call.Name == "optional" {
+			if len(call.Arguments) < 1 || len(call.Arguments) > 2 {
+				return nil, invalidTypeDiagnostics(call.ArgsRange, "Optional attribute modifier requires the attribute type and an optional default value.")
+			}
+			attributeTypeExpr = call.Arguments[0]
+			if len(call.Arguments) == 2 {
+				defaultExpr = call.Arguments[1]
+			}
+			optionalAttributes = append(optionalAttributes, name)
+		}
+
+		attribute, hclDiags := parseTypeExpr(attributeTypeExpr, true)
+		if hclDiags.HasErrors() {
+			return nil, hclDiags
+		}
+		attributeTypes[name] = attribute.ty
+		constraint.addChild(name, attribute)
+
+		if defaultExpr != nil {
+			defaultValue, hclDiags := defaultExpr.Value(&hcl.EvalContext{Functions: terraformFunctions})
+			if hclDiags.HasErrors() {
+				return nil, hclDiags
+			}
+			defaultValue, err := attribute.convert(defaultValue)
+			if err != nil {
+				return nil, invalidTypeDiagnostics(defaultExpr.Range(), fmt.Sprintf("Invalid default value for optional attribute %q: %s.", name, err))
+			}
+			if constraint.defaults == nil {
+				constraint.defaults = make(map[string]cty.Value)
+			}
+			constraint.defaults[name] = defaultValue
+		}
+	}
+
+	sort.Strings(optionalAttributes)
+	constraint.ty = cty.ObjectWithOptionalAttrs(attributeTypes, optionalAttributes)
+	return constraint, nil
+}
+
+func collectionType(name string, elementType cty.Type) cty.Type {
+	switch name {
+	case "list":
+		return cty.List(elementType)
+	case "set":
+		return cty.Set(elementType)
+	default:
+		return cty.Map(elementType)
+	}
+}
+
+// addChild keeps the nested constraint only if it has defaults to apply
+func (constraint *typeConstraint) addChild(key string, child *typeConstraint) {
+	if len(child.defaults) == 0 && len(child.children) == 0 {
+		return
+	}
+	if constraint.children == nil {
+		constraint.children = make(map[string]*typeConstraint)
+	}
+	constraint.children[key] = child
+}
+
+// convert applies the defaults of the optional object attributes to the value and then converts it to the type of the constraint
+func (constraint *typeConstraint) convert(value cty.Value) (cty.Value, error) {
+	return ctyconvert.Convert(constraint.applyDefaults(value), constraint.ty)
+}
+
+// applyDefaults sets the missing or null optional attributes of the objects found in the value to their default values
+func (constraint *typeConstraint) applyDefaults(value cty.Value) cty.Value {
+	if constraint == nil || (len(constraint.defaults) == 0 && len(constraint.children) == 0) {
+		return value
+	}
+	if value.IsNull() || !value.IsKnown() || !value.CanIterateElements() {
+		return value
+	}
+	// the marks of the value, e.g. the sensitive mark, are kept on the value with its defaults
+	if value.IsMarked() {
+		unmarkedValue, marks := value.Unmark()
+		return constraint.applyDefaults(unmarkedValue).WithMarks(marks)
+	}
+
+	valueType := value.Type()
+	switch {
+	case constraint.ty.IsObjectType() && (valueType.IsObjectType() || valueType.IsMapType()):
+		attributes := make(map[string]cty.Value)
+		for it := value.ElementIterator(); it.Next(); {
+			key, element := it.Element()
+			attributes[key.AsString()] = element
+		}
+		for name, defaultValue := range constraint.defaults {
+			if attribute, ok := attributes[name]; !ok || attribute.IsNull() {
+				attributes[name] = defaultValue
+			}
+		}
+		for name, child := range constraint.children {
+			if attribute, ok := attributes[name]; ok {
+				attributes[name] = child.applyDefaults(attribute)
+			}
+		}
+		return cty.ObjectVal(attributes)
+	case constraint.ty.IsMapType() && (valueType.IsObjectType() || valueType.IsMapType()):
+		elements := make(map[string]cty.Value)
+		for it := value.ElementIterator(); it.Next(); {
+			key, element := it.Element()
+			elements[key.AsString()] = constraint.children[""].applyDefaults(element)
+		}
+		return cty.ObjectVal(elements)
+	case (constraint.ty.IsListType() || constraint.ty.IsSetType()) && (valueType.IsTupleType() || valueType.IsListType() || valueType.IsSetType()):
+		elements := make([]cty.Value, 0, value.LengthInt())
+		for it := value.ElementIterator(); it.Next(); {
+			_, element := it.Element()
+			elements = append(elements, constraint.children[""].applyDefaults(element))
+		}
+		return cty.TupleVal(elements)
+	case constraint.ty.IsTupleType() && (valueType.IsTupleType() || valueType.IsListType()):
+		elements := make([]cty.Value, 0, value.LengthInt())
+		i := 0
+		for it := value.ElementIterator(); it.Next(); i++ {
+			_, element := it.Element()
+			elements = append(elements, constraint.children[strconv.Itoa(i)].applyDefaults(element))
+		}
+		return cty.TupleVal(elements)
+	}
+	return value
+}
+
+func invalidTypeDiagnostics(r hcl.Range, detail string) hcl.Diagnostics {
+	return hcl.Diagnostics{{
+		Severity: hcl.DiagError,
+		Summary:  invalidTypeSummary,
+		Detail:   detail,
+		Subject:  r.Ptr(),
+	}}
+}
@@ -0,0 +1,115 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/stretchr/testify/assert"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestTypeConstraintConvert(t *testing.T) {
+	testCases := []struct {
+		name     string
+		typeExpr string
+		value    cty.Value
+		expected cty.Value
+	}{
+		{
+			name:     "Primitive type",
+			typeExpr: "number",
+			value:    cty.StringVal("8080"),
+			expected: cty.NumberIntVal(8080),
+		},
+		{
+			name:     "Legacy type",
+			typeExpr: `"list"`,
+			value:    cty.TupleVal([]cty.Value{cty.StringVal("a")}),
+			expected: cty.ListVal([]cty.Value{cty.StringVal("a")}),
+		},
+		{
+			name:     "Collection type",
+			typeExpr: "map(bool)",
+			value:    cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("true")}),
+			expected: cty.MapVal(map[string]cty.Value{"a": cty.True}),
+		},
+		{
+			name:     "Any type",
+			typeExpr: "any",
+			value:    cty.StringVal("a"),
+			expected: cty.StringVal("a"),
+		},
+		{
+			name:     "Optional attributes",
+			typeExpr: `object({ name = string, port = optional(number, 80), tags = optional(map(string)) })`,
+			value:    cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("web")}),
+			expected: cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+				"port": cty.NumberIntVal(80),
+				"tags": cty.NullVal(cty.Map(cty.String)),
+			}),
+		},
+		{
+			name:     "Nested optional attributes",
+			typeExpr: `list(object({ port = optional(number, 80), tls = optional(object({ enabled = optional(bool, true) }), {}) }))`,
+			value: cty.TupleVal([]cty.Value{
+				cty.EmptyObjectVal,
+				cty.ObjectVal(map[string]cty.Value{"port": cty.StringVal("443"), "tls": cty.ObjectVal(map[string]cty.Value{"enabled": cty.False})}),
+			}),
+			expected: cty.ListVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{"port": cty.NumberIntVal(80), "tls": cty.ObjectVal(map[string]cty.Value{"enabled": cty.True})}),
+				cty.ObjectVal(map[string]cty.Value{"port": cty.NumberIntVal(443), "tls": cty.ObjectVal(map[string]cty.Value{"enabled": cty.False})}),
+			}),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expr, hclDiags := hclsyntax.ParseExpression([]byte(tc.typeExpr), "test.tf", hcl.Pos{Line: 1, Column: 1})
+			assert.False(t, hclDiags.HasErrors())
+
+			constraint, hclDiags := parseTypeConstraint(expr)
+			assert.False(t, hclDiags.HasErrors())
+
+			actual, err := constraint.convert(tc.value)
+			assert.Nil(t, err)
+			assert.True(t, tc.expected.RawEquals(actual), "expected %#v but got %#v", tc.expected, actual)
+		})
+	}
+}
+
+func TestParseTypeConstraintFailure(t *testing.T) {
+	testCases := []struct {
+		name     string
+		typeExpr string
+		detail   string
+	}{
+		{
+			name:     "Unknown keyword",
+			typeExpr: "text",
+			detail:   `The keyword "text" is not a valid type specification.`,
+		},
+		{
+			name:     "Optional outside an object",
+			typeExpr: "list(optional(string))",
+			detail:   `Keyword "optional" is valid only as a modifier for object type attributes.`,
+		},
+		{
+			name:     "Invalid default",
+			typeExpr: `object({ port = optional(number, "http") })`,
+			detail:   `Invalid default value for optional attribute "port": a number is required.`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expr, hclDiags := hclsyntax.ParseExpression([]byte(tc.typeExpr), "test.tf", hcl.Pos{Line: 1, Column: 1})
+			assert.False(t, hclDiags.HasErrors())
+
+			_, hclDiags = parseTypeConstraint(expr)
+			assert.True(t, hclDiags.HasErrors())
+			assert.Equal(t, tc.detail, hclDiags[0].Detail)
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package terraform
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// unknownValue is the structured marker of an expression which cannot be evaluated, emitted under UNKNOWN_VALUE_KEY
+type unknownValue struct {
+	// Expression is the source of the expression, e.g. aws_iam_role.x.arn
+	Expression string `json:"expression"`
+	// References are the traversals the expression references, e.g. ["aws_iam_role.x.arn"]
+	References []string `json:"references"`
+	// Reason is one of the reasons why the value of an expression is not known, e.g. UNRESOLVED_REFERENCE_REASON
+	Reason string `json:"reason"`
+}
+
+// unknownExpr returns the placeholder of an expression which cannot be evaluated,
+// which is its source wrapped in ${...} unless structured unknown markers are requested
+func (parser *Parser) unknownExpr(expr hclsyntax.Expression) interface{} {
+	if !parser.options.UnknownMarkers {
+		return parser.wrapExpr(expr)
+	}
+
+	references := []string{}
+	seen := make(map[string]bool)
+	for _, traversal := range expr.Variables() {
+		reference := traversalString(traversal)
+		if seen[reference] {
+			continue
+		}
+		seen[reference] = true
+		references = append(references, reference)
+	}
+
+	return JSON{
+		UNKNOWN_VALUE_KEY: unknownValue{
+			Expression: parser.rangeSource(expr.Range()),
+			References: references,
+			Reason:     parser.unknownReason(expr),
+		},
+	}
+}
+
+// unknownReason tells why the value of an expression is not known
+func (parser *Parser) unknownReason(expr hclsyntax.Expression) string {
+	if !parser.options.Simplify {
+		return NOT_EVALUATED_REASON
+	}
+
+	ctx := parser.evalContext()
+	reason := ""
+	hclsyntax.VisitAll(expr, func(node hclsyntax.Node) hcl.Diagnostics {
+		if call, ok := node.(*hclsyntax.FunctionCallExpr); ok {
+			if _, ok := ctx.Functions[call.Name]; !ok {
+				reason = UNSUPPORTED_FUNCTION_REASON
+			}
+		}
+		return nil
+	})
+	if reason != "" {
+		return reason
+	}
+
+	for _, traversal := range expr.Variables() {
+		value, diags := traversal.TraverseAbs(ctx)
+		if diags.HasErrors() || !value.IsWhollyKnown() {
+			return UNRESOLVED_REFERENCE_REASON
+		}
+	}
+	return INVALID_EXPRESSION_REASON
+}
+
+// isLiteralTemplate tells whether a template is only made of literal strings, e.g. the lines of a heredoc without interpolations
+func isLiteralTemplate(template *hclsyntax.TemplateExpr) bool {
+	for _, part := range template.Parts {
+		if _, isLiteral := part.(*hclsyntax.LiteralValueExpr); !isLiteral {
+			return false
+		}
+	}
+	return true
+}
+
+// traversalString renders a traversal the way it is written, e.g. aws_instance.web[0].id
+func traversalString(traversal hcl.Traversal) string {
+	var builder strings.Builder
+	for _, step := range traversal {
+		switch step := step.(type) {
+		case hcl.TraverseRoot:
+			builder.WriteString(step.Name)
+		case hcl.TraverseAttr:
+			builder.WriteString(".")
+			builder.WriteString(step.Name)
+		case hcl.TraverseIndex:
+			builder.WriteString("[")
+			switch {
+			case !step.Key.IsKnown() || step.Key.IsNull():
+				builder.WriteString("?")
+			case step.Key.Type() == cty.String:
+				builder.WriteString(fmt.Sprintf("%q", step.Key.AsString()))
+			case step.Key.Type() == cty.Number:
+				builder.WriteString(step.Key.AsBigFloat().Text('f', -1))
+			default:
+				builder.WriteString("?")
+			}
+			builder.WriteString("]")
+		case hcl.TraverseSplat:
+			builder.WriteString("[*]")
+		}
+	}
+	return builder.String()
+}
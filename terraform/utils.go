@@ -8,13 +8,31 @@ import (
 
 // the terraform.tfvars file is a strict file name so make sure the file isn't called something like *terraform.tfvars
 func isTerraformTfvarsFile(fileName string) bool {
+	return hasBaseName(fileName, DEFAULT_TFVARS)
+}
+
+// the same applies to the terraform.tfvars.json file
+func isTerraformTfvarsJsonFile(fileName string) bool {
+	return hasBaseName(fileName, DEFAULT_TFVARS_JSON)
+}
+
+func hasBaseName(fileName string, baseName string) bool {
 	// the CLI uses this library by compiling it through gopherjs for Linux so for Windows we must remove backward slashes
 	osFileName := strings.Replace(fileName, "\\", "/", -1)
-	return fileName == DEFAULT_TFVARS || strings.HasSuffix(osFileName, fmt.Sprintf("/%s", DEFAULT_TFVARS))
+	return fileName == baseName || strings.HasSuffix(osFileName, fmt.Sprintf("/%s", baseName))
+}
+
+func isAutoTfvarsFile(fileName string) bool {
+	return strings.HasSuffix(fileName, AUTO_TFVARS) || strings.HasSuffix(fileName, AUTO_TFVARS_JSON)
+}
+
+// files written in the JSON syntax are parsed with the HCL JSON parser
+func isJsonFile(fileName string) bool {
+	return strings.HasSuffix(fileName, ".json")
 }
 
 func isValidInputVariablesFile(fileName string) bool {
-	if isTerraformTfvarsFile(fileName) {
+	if isTerraformTfvarsFile(fileName) || isTerraformTfvarsJsonFile(fileName) {
 		return true
 	}
 	for _, fileExt := range VALID_VARIABLE_FILES {
@@ -40,12 +58,17 @@ type PrioritisableFile struct {
 }
 
 func createPrioritisableFile(fileName string) PrioritisableFile {
-	// TODO: Variables in terraform.tfvars.json files come after terraform.tfvars and before .auto.tfvars (not supported)
-	// TODO: Variables in -var and -var-file options come after .auto.tfvars and .auto.tfvars.json files, in the order they are provided (not supported)
+	// Environment variables and the -var and -var-file options are not files of the module, see mergeInputVariables
 
 	// The file with the biggest value has the highest priority
-	if strings.HasSuffix(fileName, TF) {
-		// Default values have lowest priority (in .tf files)
+	if isOverrideFile(fileName) {
+		// Default values in override files replace the ones in the files they override
+		return PrioritisableFile{
+			fileName: fileName,
+			priority: 2,
+		}
+	} else if strings.HasSuffix(fileName, TF) || strings.HasSuffix(fileName, TF_JSON) {
+		// Default values have lowest priority (in .tf and .tf.json files)
 		return PrioritisableFile{
 			fileName: fileName,
 			priority: 1,
@@ -54,14 +77,19 @@ func createPrioritisableFile(fileName string) PrioritisableFile {
 		// Then variables in the terraform.tfvars file if it exists
 		return PrioritisableFile{
 			fileName: fileName,
-			priority: 2,
+			priority: 3,
 		}
-	} else if strings.HasSuffix(fileName, AUTO_TFVARS) {
-		// Then variables in .auto.tfvars, in lexical order
-		// TODO: or.auto.tfvars.json (not supported)
+	} else if isTerraformTfvarsJsonFile(fileName) {
+		// Then variables in the terraform.tfvars.json file if it exists
 		return PrioritisableFile{
 			fileName: fileName,
-			priority: 3,
+			priority: 4,
+		}
+	} else if isAutoTfvarsFile(fileName) {
+		// Then variables in .auto.tfvars or .auto.tfvars.json, in lexical order
+		return PrioritisableFile{
+			fileName: fileName,
+			priority: 5,
 		}
 	} else {
 		// Won't happen
@@ -86,8 +114,8 @@ func orderFilesByPriority(fileNames []string) []string {
 		}
 
 		if prioritisableFiles[i].priority == prioritisableFiles[j].priority {
-			// sort files with the same priority and .auto.tfvars lexically
-			if strings.HasSuffix(prioritisableFiles[i].fileName, AUTO_TFVARS) {
+			// sort files with the same priority and .auto.tfvars, .auto.tfvars.json or override files lexically
+			if isAutoTfvarsFile(prioritisableFiles[i].fileName) || isOverrideFile(prioritisableFiles[i].fileName) {
 				x := strings.Compare(prioritisableFiles[i].fileName, prioritisableFiles[j].fileName)
 				return x <= 0
 			}
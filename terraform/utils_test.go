@@ -15,12 +15,21 @@ func TestIsTerraformTfvarsFile(t *testing.T) {
 	assert.True(t, isTerraformTfvarsFile("C:\\\\path\\\\to\\\\terraform.tfvars"))
 }
 
+func TestIsTerraformTfvarsJsonFile(t *testing.T) {
+	assert.True(t, isTerraformTfvarsJsonFile("terraform.tfvars.json"))
+	assert.True(t, isTerraformTfvarsJsonFile(fmt.Sprintf("path%cto%cterraform.tfvars.json", os.PathSeparator, os.PathSeparator)))
+	assert.False(t, isTerraformTfvarsJsonFile("test_terraform.tfvars.json"))
+	assert.False(t, isTerraformTfvarsJsonFile("terraform.tfvars"))
+}
+
 func TestIsValidVariableFile(t *testing.T) {
 	assert.True(t, isValidInputVariablesFile(fmt.Sprintf("path%cto%cterraform.tfvars", os.PathSeparator, os.PathSeparator)))
-	assert.False(t, isValidInputVariablesFile(fmt.Sprintf("path%cto%cterraform.tfvars.json", os.PathSeparator, os.PathSeparator)))
+	assert.True(t, isValidInputVariablesFile(fmt.Sprintf("path%cto%cterraform.tfvars.json", os.PathSeparator, os.PathSeparator)))
 	assert.True(t, isValidInputVariablesFile(fmt.Sprintf("path%cto%ctest.tf", os.PathSeparator, os.PathSeparator)))
 	assert.True(t, isValidInputVariablesFile(fmt.Sprintf("path%cto%ctest.auto.tfvars", os.PathSeparator, os.PathSeparator)))
-	assert.False(t, isValidInputVariablesFile(fmt.Sprintf("path%cto%ctest.auto.tfvars.json", os.PathSeparator, os.PathSeparator)))
+	assert.True(t, isValidInputVariablesFile(fmt.Sprintf("path%cto%ctest.auto.tfvars.json", os.PathSeparator, os.PathSeparator)))
+	assert.True(t, isValidInputVariablesFile(fmt.Sprintf("path%cto%ctest.tf.json", os.PathSeparator, os.PathSeparator)))
+	assert.False(t, isValidInputVariablesFile(fmt.Sprintf("path%cto%ctest.tfvars.json", os.PathSeparator, os.PathSeparator)))
 }
 
 func TestIsValidTerraformFile(t *testing.T) {
@@ -29,6 +38,7 @@ func TestIsValidTerraformFile(t *testing.T) {
 	assert.True(t, isValidTerraformFile(fmt.Sprintf("path%cto%ctest.tf", os.PathSeparator, os.PathSeparator)))
 	assert.False(t, isValidTerraformFile(fmt.Sprintf("path%cto%ctest.auto.tfvars", os.PathSeparator, os.PathSeparator)))
 	assert.False(t, isValidTerraformFile(fmt.Sprintf("path%cto%ctest.auto.tfvars.json", os.PathSeparator, os.PathSeparator)))
+	assert.True(t, isValidTerraformFile(fmt.Sprintf("path%cto%ctest.tf.json", os.PathSeparator, os.PathSeparator)))
 }
 
 func TestOrderFilesByPriority(t *testing.T) {
@@ -57,3 +67,43 @@ func TestOrderFilesByPriority(t *testing.T) {
 	actual := orderFilesByPriority(input)
 	assert.Equal(t, expected, actual)
 }
+
+func TestOrderFilesByPriorityWithJsonFiles(t *testing.T) {
+	input := []string{
+		"c.auto.tfvars",
+		"terraform.tfvars.json",
+		"b.tf.json",
+		"a.tf",
+		"terraform.tfvars",
+		"b.auto.tfvars.json",
+		"a.auto.tfvars",
+	}
+	expected := []string{
+		"b.tf.json",
+		"a.tf",
+		"terraform.tfvars",
+		"terraform.tfvars.json",
+		"a.auto.tfvars",
+		"b.auto.tfvars.json",
+		"c.auto.tfvars",
+	}
+	actual := orderFilesByPriority(input)
+	assert.Equal(t, expected, actual)
+}
+
+func TestOrderFilesByPriorityWithOverrideFiles(t *testing.T) {
+	input := []string{
+		"terraform.tfvars",
+		"b_override.tf",
+		"main.tf",
+		"a_override.tf.json",
+	}
+	expected := []string{
+		"main.tf",
+		"a_override.tf.json",
+		"b_override.tf",
+		"terraform.tfvars",
+	}
+	actual := orderFilesByPriority(input)
+	assert.Equal(t, expected, actual)
+}
@@ -0,0 +1,231 @@
+package terraform
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Logic inspired from https://github.com/hashicorp/terraform/blob/v1.0.0/internal/command/meta_vars.go
+
+type VariableSourceType string
+
+// VariableSource is a source of input variable values passed to the Terraform CLI rather than found in the module
+type VariableSource struct {
+	Type VariableSourceType
+	// Value is the raw value of a -var option, i.e. a variable name and a value separated by an equals sign
+	Value string
+	// FileName and FileContent are the name and the content of the file of a -var-file option
+	FileName    string
+	FileContent []byte
+	// Environment holds environment variables, of which only the ones starting with TF_VAR_ are used
+	Environment map[string]string
+}
+
+// InputVariableSources holds the input variables which do not come from the files of the module
+type InputVariableSources struct {
+	// Environment holds the values of the TF_VAR_ environment variables
+	Environment ValueMap
+	// CommandLine holds the values of each -var and -var-file option, in the order they are provided
+	CommandLine []ValueMap
+}
+
+// parseVariableSources converts the untyped variable sources passed to ParseModule, skipping the invalid ones
+// e.g. {"type": "var", "value": "region=eu-west-1"}, {"type": "varFile", "fileName": "prod.tfvars", "fileContent": "..."}
+// or {"type": "env", "environment": {"TF_VAR_region": "eu-west-1"}}
+func parseVariableSources(rawVariableSources []interface{}) []VariableSource {
+	var variableSources []VariableSource
+	for _, rawVariableSource := range rawVariableSources {
+		rawMap, ok := rawVariableSource.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		sourceType, _ := rawMap["type"].(string)
+
+		switch VariableSourceType(sourceType) {
+		case VAR_SOURCE:
+			value, ok := rawMap["value"].(string)
+			if !ok {
+				continue
+			}
+			variableSources = append(variableSources, VariableSource{Type: VAR_SOURCE, Value: value})
+		case VAR_FILE_SOURCE:
+			fileName, isFileNameString := rawMap["fileName"].(string)
+			fileContent, isFileContentString := rawMap["fileContent"].(string)
+			if !isFileNameString || !isFileContentString {
+				continue
+			}
+			variableSources = append(variableSources, VariableSource{Type: VAR_FILE_SOURCE, FileName: fileName, FileContent: []byte(fileContent)})
+		case ENV_SOURCE:
+			rawEnvironment, ok := rawMap["environment"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			environment := make(map[string]string, len(rawEnvironment))
+			for name, rawValue := range rawEnvironment {
+				if value, ok := rawValue.(string); ok {
+					environment[name] = value
+				}
+			}
+			variableSources = append(variableSources, VariableSource{Type: ENV_SOURCE, Environment: environment})
+		}
+	}
+	return variableSources
+}
+
+// extractInputVariablesFromSources extracts the input variables from the provided variable sources
+// The raw values of the -var options and of the environment variables are parsed according to the declared type of their variable
+// The errors are recorded in the variable source errors against the -var-file file names, the -var options
+// or the environment variables they come from
+func extractInputVariablesFromSources(variableSources []VariableSource, declarations VariableDeclarations, parseRes *ParseModuleResult) InputVariableSources {
+	inputSources := InputVariableSources{
+		Environment: ValueMap{},
+	}
+
+	for _, variableSource := range variableSources {
+		switch variableSource.Type {
+		case VAR_SOURCE:
+			name, value, err := extractInputVariableFromVarOption(variableSource.Value, declarations)
+			if err != nil {
+				recordVariableSourceError(parseRes, fmt.Sprintf("-var %q", name), err)
+				continue
+			}
+			// like Terraform, a -var option for an undeclared variable is an error
+			if _, ok := declarations[name]; !ok {
+				recordVariableSourceError(parseRes, fmt.Sprintf("-var %q", name), createInvalidVariableError([]error{&hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Value for undeclared variable",
+					Detail:   fmt.Sprintf("A variable named %q was assigned on the command line, but the root module does not declare a variable of that name. To use this value, add a \"variable\" block to the configuration.", name),
+				}}))
+				continue
+			}
+			inputSources.CommandLine = append(inputSources.CommandLine, ValueMap{name: value})
+		case VAR_FILE_SOURCE:
+			hclFile, hclDiags := parseHclFile(variableSource.FileName, variableSource.FileContent)
+			if hclDiags.HasErrors() {
+				recordVariableSourceError(parseRes, variableSource.FileName, createInvalidHCLError(hclDiags.Errs()))
+				continue
+			}
+			inputs, hclDiags := extractInputVariablesFromTfvarsFile(hclFile, isJsonFile(variableSource.FileName))
+			if hclDiags.HasErrors() {
+				recordVariableSourceError(parseRes, variableSource.FileName, createInvalidHCLError(hclDiags.Errs()))
+			}
+			removeUndeclaredVarFileVariables(hclFile, variableSource.FileName, inputs, declarations, parseRes)
+			inputSources.CommandLine = append(inputSources.CommandLine, inputs)
+		case ENV_SOURCE:
+			// sort the environment variables so that the errors are deterministic
+			envNames := make([]string, 0, len(variableSource.Environment))
+			for envName := range variableSource.Environment {
+				envNames = append(envNames, envName)
+			}
+			sort.Strings(envNames)
+
+			for _, envName := range envNames {
+				if !strings.HasPrefix(envName, TF_VAR_PREFIX) {
+					continue
+				}
+				name := strings.TrimPrefix(envName, TF_VAR_PREFIX)
+				// like Terraform, environment variables for undeclared variables are ignored, but they are reported
+				declaration, ok := declarations[name]
+				if !ok {
+					parseRes.VariableDiagnostics = append(parseRes.VariableDiagnostics, newVariableDiagnostic(name, "Value for undeclared variable",
+						fmt.Sprintf("The root module does not declare a variable named %q but a value was found in the environment variable %s. If you meant to use this value, add a \"variable\" block to the configuration.", name, envName), nil))
+					continue
+				}
+				value, hclDiags := parseRawVariableValue(envName, variableSource.Environment[envName], declaration.typeExpr)
+				if hclDiags.HasErrors() {
+					recordVariableSourceError(parseRes, envName, createInvalidVariableError(hclDiags.Errs()))
+					continue
+				}
+				inputSources.Environment[name] = value
+			}
+		}
+	}
+
+	return inputSources
+}
+
+// removeUndeclaredVarFileVariables removes the values of the variables which are not declared in the module from the values of a -var-file file
+// Like Terraform, they are ignored, and a diagnostic is recorded for each of them
+func removeUndeclaredVarFileVariables(hclFile *hcl.File, fileName string, inputs ValueMap, declarations VariableDeclarations, parseRes *ParseModuleResult) {
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		if _, ok := declarations[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	attrs, _ := hclFile.Body.JustAttributes()
+	for _, name := range names {
+		delete(inputs, name)
+
+		var subject *hcl.Range
+		if attr, ok := attrs[name]; ok {
+			subject = attr.NameRange.Ptr()
+		}
+		parseRes.VariableDiagnostics = append(parseRes.VariableDiagnostics, newVariableDiagnostic(name, "Value for undeclared variable",
+			fmt.Sprintf("The root module does not declare a variable named %q but a value was found in file %q. If you meant to use this value, add a \"variable\" block to the configuration.", name, fileName), subject))
+	}
+}
+
+// extractInputVariableFromVarOption parses the raw value of a -var option, e.g. region=eu-west-1
+func extractInputVariableFromVarOption(rawValue string, declarations VariableDeclarations) (string, cty.Value, error) {
+	equals := strings.Index(rawValue, "=")
+	if equals < 1 {
+		return rawValue, cty.NilVal, createInvalidVariableError([]error{
+			fmt.Errorf("The given -var option %q is not correctly specified. It must be a variable name and value separated by an equals sign", rawValue),
+		})
+	}
+
+	name := strings.TrimSpace(rawValue[:equals])
+	value, hclDiags := parseRawVariableValue(fmt.Sprintf("-var %q", name), rawValue[equals+1:], declarations[name].typeExpr)
+	if hclDiags.HasErrors() {
+		return name, cty.NilVal, createInvalidVariableError(hclDiags.Errs())
+	}
+	return name, value, nil
+}
+
+// parseRawVariableValue parses a raw value the way Terraform does: the values of variables with a primitive type,
+// and of undeclared variables or of variables without a type, are literal strings while the others are HCL expressions
+func parseRawVariableValue(sourceName string, rawValue string, typeExpr hcl.Expression) (cty.Value, hcl.Diagnostics) {
+	if isLiteralVariableType(typeExpr) {
+		return cty.StringVal(rawValue), nil
+	}
+
+	expr, hclDiags := hclsyntax.ParseExpression([]byte(rawValue), sourceName, hcl.Pos{Line: 1, Column: 1})
+	if hclDiags.HasErrors() {
+		return cty.NilVal, hclDiags
+	}
+	return expr.Value(nil)
+}
+
+func isLiteralVariableType(typeExpr hcl.Expression) bool {
+	if typeExpr == nil {
+		return true
+	}
+
+	switch hcl.ExprAsKeyword(typeExpr) {
+	case "string", "number", "bool":
+		return true
+	case "":
+		// legacy type constraints are quoted, e.g. "string", "list" or "map", of which only "string" is primitive
+		value, hclDiags := typeExpr.Value(nil)
+		if hclDiags.HasErrors() || !value.IsKnown() || value.IsNull() || value.Type() != cty.String {
+			return false
+		}
+		return value.AsString() == "string"
+	default:
+		return false
+	}
+}
+
+// recordVariableSourceError records the error of a variable source apart from the errors of the files,
+// as the names of the sources, e.g. -var "region", are not file names
+func recordVariableSourceError(parseRes *ParseModuleResult, sourceName string, err error) {
+	parseRes.VariableSourceErrors[sourceName] = err
+}
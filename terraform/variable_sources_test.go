@@ -0,0 +1,80 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/stretchr/testify/assert"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestParseRawVariableValue(t *testing.T) {
+	testCases := []struct {
+		name     string
+		typeExpr string
+		rawValue string
+		expected cty.Value
+	}{
+		{
+			name:     "Undeclared type",
+			rawValue: `["a"]`,
+			expected: cty.StringVal(`["a"]`),
+		},
+		{
+			name:     "Primitive type",
+			typeExpr: "number",
+			rawValue: "1",
+			expected: cty.StringVal("1"),
+		},
+		{
+			name:     "Legacy string type",
+			typeExpr: `"string"`,
+			rawValue: "a",
+			expected: cty.StringVal("a"),
+		},
+		{
+			name:     "Collection type",
+			typeExpr: "list(string)",
+			rawValue: `["a", "b"]`,
+			expected: cty.TupleVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+		},
+		{
+			name:     "Any type",
+			typeExpr: "any",
+			rawValue: `{ a = true }`,
+			expected: cty.ObjectVal(map[string]cty.Value{"a": cty.True}),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var typeExpr hcl.Expression
+			if tc.typeExpr != "" {
+				var hclDiags hcl.Diagnostics
+				typeExpr, hclDiags = hclsyntax.ParseExpression([]byte(tc.typeExpr), "test.tf", hcl.Pos{Line: 1, Column: 1})
+				assert.False(t, hclDiags.HasErrors())
+			}
+
+			actual, hclDiags := parseRawVariableValue("-var", tc.rawValue, typeExpr)
+			assert.False(t, hclDiags.HasErrors())
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestParseVariableSources(t *testing.T) {
+	actual := parseVariableSources([]interface{}{
+		map[string]interface{}{"type": "var", "value": "region=eu-west-1"},
+		map[string]interface{}{"type": "varFile", "fileName": "prod.tfvars", "fileContent": `region = "us-east-1"`},
+		map[string]interface{}{"type": "env", "environment": map[string]interface{}{"TF_VAR_region": "eu-west-2", "HOME": "/root"}},
+		map[string]interface{}{"type": "var"},
+		"region=eu-west-3",
+	})
+
+	assert.Equal(t, []VariableSource{
+		{Type: VAR_SOURCE, Value: "region=eu-west-1"},
+		{Type: VAR_FILE_SOURCE, FileName: "prod.tfvars", FileContent: []byte(`region = "us-east-1"`)},
+		{Type: ENV_SOURCE, Environment: map[string]string{"TF_VAR_region": "eu-west-2", "HOME": "/root"}},
+	}, actual)
+}
@@ -1,12 +1,16 @@
 package terraform
 
 import (
+	"fmt"
 	"reflect"
 	"sort"
 	"strings"
 
 	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/zclconf/go-cty/cty"
+	ctyconvert "github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
 )
 
 type ValueMap map[string]cty.Value
@@ -16,11 +20,14 @@ type ExpressionMap map[string]hcl.Expression
 type ModuleVariables struct {
 	inputs ValueMap
 	locals ValueMap
+	// path holds the values of path.module, path.root and path.cwd when the module has a filesystem
+	path ValueMap
 }
 
 type InputVariablesByFile map[string]ValueMap
 
 // ExtractVariables extracts the input variables and local values from the provided file
+// The validation blocks of the variables are evaluated against their final value by ParseModuleFiles when ValidateVariables is set
 func ExtractVariables(file File) (ValueMap, ExpressionMap, error) {
 	inputsMap := ValueMap{}
 	localsMap := ExpressionMap{}
@@ -44,10 +51,10 @@ func ExtractVariables(file File) (ValueMap, ExpressionMap, error) {
 func extractInputVariablesFromFile(file File) (ValueMap, hcl.Diagnostics) {
 	var inputVariables ValueMap
 	var hclDiags hcl.Diagnostics
-	if strings.HasSuffix(file.fileName, TF) {
+	if strings.HasSuffix(file.fileName, TF) || strings.HasSuffix(file.fileName, TF_JSON) {
 		inputVariables, hclDiags = extractInputVariablesFromTfFile(file.hclFile)
-	} else if strings.HasSuffix(file.fileName, TFVARS) {
-		inputVariables, hclDiags = extractInputVariablesFromTfvarsFile(file.hclFile)
+	} else if strings.HasSuffix(file.fileName, TFVARS) || strings.HasSuffix(file.fileName, TFVARS_JSON) {
+		inputVariables, hclDiags = extractInputVariablesFromTfvarsFile(file.hclFile, isJsonFile(file.fileName))
 	}
 
 	if hclDiags.HasErrors() {
@@ -102,13 +109,233 @@ func extractInputVariablesFromTfFile(file *hcl.File) (ValueMap, hcl.Diagnostics)
 	return inputVariablesMap, hclDiags
 }
 
-func extractInputVariablesFromTfvarsFile(file *hcl.File) (ValueMap, hcl.Diagnostics) {
+// variableDeclaration holds what the variable blocks declare about an input variable besides its default value
+type variableDeclaration struct {
+	// typeExpr is the expression of the type argument, or nil if the variable is declared without a type
+	typeExpr hcl.Expression
+	// constraint is the parsed type constraint, or nil if there is no type or it is invalid
+	constraint *typeConstraint
+	// typeHclDiags holds the errors found while parsing the type constraint
+	typeHclDiags hcl.Diagnostics
+	validations  []variableValidation
+	// sensitive is set when the variable is declared with sensitive = true
+	sensitive bool
+	declRange hcl.Range
+}
+
+// variableValidation is a validation block of a variable
+type variableValidation struct {
+	condition    hcl.Expression
+	errorMessage hcl.Expression
+	// errorMessageSource is the source text of the error message, used when it cannot be evaluated
+	errorMessageSource string
+}
+
+type VariableDeclarations map[string]variableDeclaration
+
+// extractVariableDeclarationsFromTfFile extracts the declarations of the variables in the provided file and parses their type constraints
+func extractVariableDeclarationsFromTfFile(file *hcl.File) (VariableDeclarations, hcl.Diagnostics) {
+	declarations := VariableDeclarations{}
+
+	bodyContent, _, hclDiags := file.Body.PartialContent(tfFileVariableSchema)
+	if hclDiags.HasErrors() {
+		return declarations, hclDiags
+	}
+
+	for _, block := range bodyContent.Blocks {
+		declaration := variableDeclaration{
+			declRange: block.DefRange,
+		}
+
+		content, _, _ := block.Body.PartialContent(variableBlockSchema)
+		if attr, ok := content.Attributes["type"]; ok {
+			declaration.typeExpr = jsonTypeExpr(attr.Expr)
+			declaration.constraint, declaration.typeHclDiags = parseTypeConstraint(declaration.typeExpr)
+		}
+		if attr, ok := content.Attributes["sensitive"]; ok {
+			sensitive, hclDiags := attr.Expr.Value(nil)
+			if !hclDiags.HasErrors() {
+				if sensitive, err := ctyconvert.Convert(sensitive, cty.Bool); err == nil && sensitive.IsKnown() && !sensitive.IsNull() {
+					declaration.sensitive = sensitive.True()
+				}
+			}
+		}
+		for _, validationBlock := range content.Blocks {
+			validationContent, validationHclDiags := validationBlock.Body.Content(variableValidationBlockSchema)
+			if validationHclDiags.HasErrors() {
+				hclDiags = append(hclDiags, validationHclDiags...)
+				continue
+			}
+			errorMessage := validationContent.Attributes["error_message"].Expr
+			declaration.validations = append(declaration.validations, variableValidation{
+				condition:          validationContent.Attributes["condition"].Expr,
+				errorMessage:       errorMessage,
+				errorMessageSource: string(errorMessage.Range().SliceBytes(file.Bytes)),
+			})
+		}
+		declarations[block.Labels[0]] = declaration
+	}
+
+	return declarations, hclDiags
+}
+
+// jsonTypeExpr parses the type constraint of a variable declared in the JSON syntax, which is a string holding
+// the type constraint in the native syntax, e.g. "list(string)", so that it is parsed the same way as in the native syntax
+func jsonTypeExpr(expr hcl.Expression) hcl.Expression {
+	if !isJsonFile(expr.Range().Filename) {
+		return expr
+	}
+	value, hclDiags := expr.Value(nil)
+	if hclDiags.HasErrors() || !value.IsKnown() || value.IsNull() || value.Type() != cty.String {
+		return expr
+	}
+	// the legacy type constraints are still read as quoted strings
+	if value.AsString() == "list" || value.AsString() == "map" {
+		return expr
+	}
+	typeExpr, hclDiags := hclsyntax.ParseExpression([]byte(value.AsString()), expr.Range().Filename, expr.Range().Start)
+	if hclDiags.HasErrors() {
+		return expr
+	}
+	return typeExpr
+}
+
+// convertInputVariables converts the values of the input variables through their declared type constraints
+// The variables whose value cannot be converted are removed and reported in the diagnostics, alongside the invalid type constraints
+func convertInputVariables(inputs ValueMap, declarations VariableDeclarations) (ValueMap, []VariableDiagnostic) {
+	var diagnostics []VariableDiagnostic
+
+	declaredNames := make([]string, 0, len(declarations))
+	for name := range declarations {
+		declaredNames = append(declaredNames, name)
+	}
+	sort.Strings(declaredNames)
+
+	for _, name := range declaredNames {
+		for _, hclDiag := range declarations[name].typeHclDiags {
+			diagnostics = append(diagnostics, newVariableDiagnostic(name, hclDiag.Summary, hclDiag.Detail, hclDiag.Subject))
+		}
+	}
+
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	convertedInputs := make(ValueMap, len(inputs))
+	for _, name := range names {
+		value := inputs[name]
+		declaration, ok := declarations[name]
+		if !ok || declaration.constraint == nil {
+			convertedInputs[name] = value
+			continue
+		}
+
+		convertedValue, err := declaration.constraint.convert(value)
+		if err != nil {
+			diagnostics = append(diagnostics, newVariableDiagnostic(
+				name,
+				"Invalid value for input variable",
+				fmt.Sprintf("The given value is not suitable for var.%s: %s.", name, err),
+				declaration.declRange.Ptr(),
+			))
+			continue
+		}
+		convertedInputs[name] = convertedValue
+	}
+
+	return convertedInputs, diagnostics
+}
+
+// validateInputVariables evaluates the validation conditions of the variables against their final value
+// The failing conditions and the ones which cannot be evaluated are reported in the diagnostics, the values are kept as they are
+func validateInputVariables(inputs ValueMap, declarations VariableDeclarations) []VariableDiagnostic {
+	names := make([]string, 0, len(declarations))
+	for name := range declarations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var diagnostics []VariableDiagnostic
+	for _, name := range names {
+		value, ok := inputs[name]
+		if !ok {
+			continue
+		}
+
+		// like Terraform, the conditions can only refer to the variable they validate
+		ctx := &hcl.EvalContext{
+			Variables: map[string]cty.Value{
+				"var": cty.ObjectVal(map[string]cty.Value{name: value}),
+			},
+			Functions: terraformFunctions,
+		}
+
+		for _, validation := range declarations[name].validations {
+			// an error message derived from a sensitive value is not evaluated, so that the value does not leak into the diagnostics
+			errorMessage := validation.errorMessageSource
+			if errorMessageValue, hclDiags := validation.errorMessage.Value(ctx); !hclDiags.HasErrors() && errorMessageValue.IsKnown() && errorMessageValue.Type() == cty.String && !errorMessageValue.IsNull() && !errorMessageValue.IsMarked() {
+				errorMessage = errorMessageValue.AsString()
+			}
+
+			result, hclDiags := validation.condition.Value(ctx)
+			if !hclDiags.HasErrors() {
+				result, hclDiags = convertValidationResult(result)
+				result, _ = result.Unmark()
+			}
+
+			var diagnostic VariableDiagnostic
+			switch {
+			case hclDiags.HasErrors():
+				diagnostic = newVariableDiagnostic(name, "Unable to evaluate validation condition", hclDiags.Error(), validation.condition.Range().Ptr())
+			case !result.IsKnown():
+				diagnostic = newVariableDiagnostic(name, "Unable to evaluate validation condition", fmt.Sprintf("The validation condition of var.%s depends on values which are not known.", name), validation.condition.Range().Ptr())
+			case result.False():
+				diagnostic = newVariableDiagnostic(name, "Invalid value for variable", errorMessage, validation.condition.Range().Ptr())
+			default:
+				continue
+			}
+			diagnostic.ErrorMessage = errorMessage
+			diagnostics = append(diagnostics, diagnostic)
+		}
+	}
+
+	return diagnostics
+}
+
+func convertValidationResult(result cty.Value) (cty.Value, hcl.Diagnostics) {
+	if result.IsNull() {
+		return result, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid validation result",
+			Detail:   "The validation condition must not be null.",
+		}}
+	}
+	result, err := ctyconvert.Convert(result, cty.Bool)
+	if err != nil {
+		return result, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid validation result",
+			Detail:   fmt.Sprintf("The validation condition must be a boolean: %s.", err),
+		}}
+	}
+	return result, nil
+}
+
+func extractInputVariablesFromTfvarsFile(file *hcl.File, isJson bool) (ValueMap, hcl.Diagnostics) {
 	inputVariablesMap := ValueMap{}
 
 	attrs, hclDiags := file.Body.JustAttributes()
 
+	// like Terraform, strings in .tfvars.json files are literal values rather than templates
+	ctx := &hcl.EvalContext{Functions: terraformFunctions}
+	if isJson {
+		ctx = nil
+	}
+
 	for name, attr := range attrs {
-		value, diags := attr.Expr.Value(&hcl.EvalContext{Functions: terraformFunctions})
+		value, diags := attr.Expr.Value(ctx)
 		if diags.HasErrors() {
 			continue
 		}
@@ -117,7 +344,9 @@ func extractInputVariablesFromTfvarsFile(file *hcl.File) (ValueMap, hcl.Diagnost
 	return inputVariablesMap, hclDiags
 }
 
-func mergeInputVariables(inputVariablesByFile InputVariablesByFile) ValueMap {
+// mergeInputVariables merges the input variables in the precedence order of Terraform: the default values,
+// then the environment variables, then the variable definitions files and finally the -var and -var-file options
+func mergeInputVariables(inputVariablesByFile InputVariablesByFile, inputSources InputVariableSources) ValueMap {
 	combinedInputVariables := make(ValueMap)
 
 	fileNames := make([]string, 0, len(inputVariablesByFile))
@@ -135,18 +364,35 @@ func mergeInputVariables(inputVariablesByFile InputVariablesByFile) ValueMap {
 	prioritisedFileNames := orderFilesByPriority(fileNames)
 
 	for _, fileName := range prioritisedFileNames {
-		inputVariablesMap := inputVariablesByFile[fileName]
-		for input, value := range inputVariablesMap {
-			combinedInputVariables[input] = value
+		if isValidTerraformFile(fileName) {
+			mergeValueMap(combinedInputVariables, inputVariablesByFile[fileName])
+		}
+	}
+
+	mergeValueMap(combinedInputVariables, inputSources.Environment)
+
+	for _, fileName := range prioritisedFileNames {
+		if !isValidTerraformFile(fileName) {
+			mergeValueMap(combinedInputVariables, inputVariablesByFile[fileName])
 		}
 	}
 
+	for _, inputVariablesMap := range inputSources.CommandLine {
+		mergeValueMap(combinedInputVariables, inputVariablesMap)
+	}
+
 	return combinedInputVariables
 }
 
+func mergeValueMap(dst ValueMap, src ValueMap) {
+	for name, value := range src {
+		dst[name] = value
+	}
+}
+
 var maxLocalsDerefIterations = 32
 
-func dereferenceLocals(localExprsMap ExpressionMap, inputs ValueMap) ValueMap {
+func dereferenceLocals(localExprsMap ExpressionMap, inputs ValueMap, paths ValueMap, functions map[string]function.Function) ValueMap {
 	currLocalVals := ValueMap{}
 	nextLocalVals := ValueMap{}
 
@@ -156,8 +402,9 @@ func dereferenceLocals(localExprsMap ExpressionMap, inputs ValueMap) ValueMap {
 				Variables: createValueMap(ModuleVariables{
 					inputs: inputs,
 					locals: currLocalVals,
+					path:   paths,
 				}),
-				Functions: terraformFunctions,
+				Functions: functions,
 			})
 
 			// the local cannot be dereferenced so move onto the next one
@@ -183,8 +430,13 @@ func dereferenceLocals(localExprsMap ExpressionMap, inputs ValueMap) ValueMap {
 }
 
 func createValueMap(variables ModuleVariables) ValueMap {
-	return ValueMap{
+	valueMap := ValueMap{
 		"var":   cty.ObjectVal(variables.inputs),
 		"local": cty.ObjectVal(variables.locals),
 	}
+	// path is only bound when the module has a filesystem, otherwise references to it are kept as they are
+	if len(variables.path) > 0 {
+		valueMap["path"] = cty.ObjectVal(variables.path)
+	}
+	return valueMap
 }
@@ -132,7 +132,7 @@ func TestMergeVariablesFromTerraformFiles(t *testing.T) {
 		"var2": cty.StringVal("val2-duplicate"),
 		"var3": cty.StringVal("val3"),
 	}
-	actual := mergeInputVariables(input)
+	actual := mergeInputVariables(input, InputVariableSources{})
 	assert.Equal(t, expected, actual)
 }
 
@@ -148,7 +148,7 @@ func TestMergeVariablesOverridesWithTerraformTfvars(t *testing.T) {
 	expected := ValueMap{
 		"var": cty.StringVal("val2"),
 	}
-	actual := mergeInputVariables(input)
+	actual := mergeInputVariables(input, InputVariableSources{})
 	assert.Equal(t, expected, actual)
 }
 
@@ -167,7 +167,7 @@ func TestMergeVariablesOverridesWithAnyAutoTfvars(t *testing.T) {
 	expected := ValueMap{
 		"var": cty.StringVal("val3"),
 	}
-	actual := mergeInputVariables(input)
+	actual := mergeInputVariables(input, InputVariableSources{})
 	assert.Equal(t, expected, actual)
 }
 
@@ -189,6 +189,42 @@ func TestMergeVariablesOverridesWithLexicalOrderAutoTfvars(t *testing.T) {
 	expected := ValueMap{
 		"var": cty.StringVal("val3"),
 	}
-	actual := mergeInputVariables(input)
+	actual := mergeInputVariables(input, InputVariableSources{})
+	assert.Equal(t, expected, actual)
+}
+
+func TestMergeVariablesWithVariableSources(t *testing.T) {
+	input := InputVariablesByFile{
+		"test1.tf": ValueMap{
+			"default":  cty.StringVal("default"),
+			"env":      cty.StringVal("default"),
+			"tfvars":   cty.StringVal("default"),
+			"var":      cty.StringVal("default"),
+			"last_var": cty.StringVal("default"),
+		},
+		"terraform.tfvars": ValueMap{
+			"tfvars":   cty.StringVal("tfvars"),
+			"var":      cty.StringVal("tfvars"),
+			"last_var": cty.StringVal("tfvars"),
+		},
+	}
+	inputSources := InputVariableSources{
+		Environment: ValueMap{
+			"env":    cty.StringVal("env"),
+			"tfvars": cty.StringVal("env"),
+		},
+		CommandLine: []ValueMap{
+			{"var": cty.StringVal("var-file"), "last_var": cty.StringVal("var-file")},
+			{"last_var": cty.StringVal("var")},
+		},
+	}
+	expected := ValueMap{
+		"default":  cty.StringVal("default"),
+		"env":      cty.StringVal("env"),
+		"tfvars":   cty.StringVal("tfvars"),
+		"var":      cty.StringVal("var-file"),
+		"last_var": cty.StringVal("var"),
+	}
+	actual := mergeInputVariables(input, inputSources)
 	assert.Equal(t, expected, actual)
 }
@@ -5,19 +5,25 @@ import (
 	"fmt"
 	"math/rand"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/pkg/errors"
+	"github.com/snyk/snyk-iac-parsers/terraform"
 )
 
 type ResourceActions []string
 
 type TerraformPlanResource struct {
-	Address string      // "aws_cloudwatch_log_group.terra_ci",
-	Mode    string      // "managed",
-	Type    string      // "aws_cloudwatch_log_group",
-	Name    string      // "terra_ci",
-	Index   interface{} // Can be either an integer or a string (e.g. 1, "10.0.101.0/24", "rtb-00cf8381520103cfb")
+	Address       string      // "aws_cloudwatch_log_group.terra_ci",
+	ModuleAddress string      `json:"module_address"` // "module.logs", empty for the resources of the root module
+	Mode          string      // "managed",
+	Type          string      // "aws_cloudwatch_log_group",
+	Name          string      // "terra_ci",
+	Index         interface{} // Can be either an integer or a string (e.g. 1, "10.0.101.0/24", "rtb-00cf8381520103cfb")
 }
 
 type TerraformPlanResourceChange struct {
@@ -31,7 +37,12 @@ type TerraformPlanResourceChange struct {
 }
 
 type TerraformPlanModule struct {
-	Resources []TerraformPlanResourceChange `json:"resources"`
+	Resources   []TerraformPlanResourceChange      `json:"resources"`
+	ModuleCalls map[string]TerraformPlanModuleCall `json:"module_calls"`
+}
+
+type TerraformPlanModuleCall struct {
+	Module TerraformPlanModule `json:"module"`
 }
 
 type TerraformPlanConfiguration struct {
@@ -45,6 +56,68 @@ type TerraformPlanJson struct {
 
 type TerraformScanInput map[string]map[string]map[string]interface{}
 
+// ScanMode selects the preset of the resource actions which are scanned
+type ScanMode string
+
+const (
+	// FULL_SCAN scans the resources which are created, updated, replaced or left unchanged by the plan
+	FULL_SCAN ScanMode = "full"
+	// DELTA_SCAN only scans the resources which are created, updated or replaced by the plan, e.g. in pull requests
+	DELTA_SCAN ScanMode = "delta"
+)
+
+// TerraformPlanOptions selects the resource changes of a plan which are scanned
+type TerraformPlanOptions struct {
+	// ScanMode selects the preset of valid actions, and defaults to FULL_SCAN
+	ScanMode ScanMode
+	// Actions is an allow-list of action sets which replaces the preset of the scan mode,
+	// e.g. []ResourceActions{{"delete"}} to catch the removal of resources
+	Actions []ResourceActions
+	// IncludeAddresses and ExcludeAddresses are glob patterns matched against the addresses of the resources,
+	// e.g. module.network.*, where * matches any sequence of characters and ? any single character
+	// A resource is scanned when it matches one of the included patterns, if any, and none of the excluded ones
+	IncludeAddresses []string
+	ExcludeAddresses []string
+}
+
+// DefaultTerraformPlanOptions returns the options used by ParseTerraformPlan
+func DefaultTerraformPlanOptions() TerraformPlanOptions {
+	return TerraformPlanOptions{
+		ScanMode: FULL_SCAN,
+	}
+}
+
+// validActions returns the allow-list of the options, or else the preset of their scan mode
+func (options TerraformPlanOptions) validActions() []ResourceActions {
+	if options.Actions != nil {
+		return options.Actions
+	}
+	if options.ScanMode == DELTA_SCAN {
+		return getValidResourceActionsForDeltaScan()
+	}
+	return getValidResourceActionsForFullScan()
+}
+
+// isIncludedAddress tells whether a resource address matches the include and exclude patterns of the options
+func (options TerraformPlanOptions) isIncludedAddress(address string) bool {
+	included := len(options.IncludeAddresses) == 0
+	for _, pattern := range options.IncludeAddresses {
+		if matchAddress(pattern, address) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, pattern := range options.ExcludeAddresses {
+		if matchAddress(pattern, address) {
+			return false
+		}
+	}
+	return true
+}
+
 func getValidResourceActionsForDeltaScan() []ResourceActions {
 	return []ResourceActions{{`create`}, {`update`}, {`create`, `delete`}, {`delete`, `create`}}
 }
@@ -53,14 +126,22 @@ func getValidResourceActionsForFullScan() []ResourceActions {
 	return append(getValidResourceActionsForDeltaScan(), ResourceActions{`no-op`})
 }
 
-func parseTerraformPlan(planJson TerraformPlanJson, isFullScan bool) TerraformScanInput {
+func parseTerraformPlan(planJson TerraformPlanJson, options TerraformPlanOptions) TerraformScanInput {
 	scanInput := TerraformScanInput{
 		"resource": map[string]map[string]interface{}{},
 		"data":     map[string]map[string]interface{}{},
+		// the full lists of references of the resources, which is empty when none of them has references
+		"references": map[string]map[string]interface{}{},
 	}
+	// the scanned resources by the address of their configuration,
+	// e.g. module.logs.aws_s3_bucket.this for both module.logs["a"].aws_s3_bucket.this and module.logs["b"].aws_s3_bucket.this
+	scannedResources := map[string][]TerraformPlanResource{}
 	for _, resource := range planJson.ResourceChanges {
 		// checks if valid action, if invalid skip loop iteration
-		if !isValidResourceActions(resource.Change.Actions, isFullScan) {
+		if !isValidResourceActions(resource.Change.Actions, options.validActions()) {
+			continue
+		}
+		if !options.isIncludedAddress(getResourceAddress(resource)) {
 			continue
 		}
 		// get correct mode for scanInput
@@ -72,74 +153,202 @@ func parseTerraformPlan(planJson TerraformPlanJson, isFullScan bool) TerraformSc
 		}
 		// even though we only support resource or data options, we do this as a sanity check
 		if _, ok := scanInput[mode]; ok {
+			key := getResourceKey(resource)
 			// scanInput's mode is set, add item to mode
 			if _, ok := scanInput[mode][resource.Type]; ok {
 				// scanInput[mode][resource.Type] resource type already created, adding another resource under it with a new name
-				scanInput[mode][resource.Type][getResourceName(resource)] = resource.Change.After
+				scanInput[mode][resource.Type][key] = resource.Change.After
 			} else {
 				// set new resource type with its values
-				scanInput[mode][resource.Type] = map[string]interface{}{getResourceName(resource): resource.Change.After}
+				scanInput[mode][resource.Type] = map[string]interface{}{key: resource.Change.After}
+			}
+			if mode == "resource" {
+				configAddress := joinAddress(stripInstanceKeys(resource.ModuleAddress), resource.Type+"."+resource.Name)
+				scannedResources[configAddress] = append(scannedResources[configAddress], resource.TerraformPlanResource)
 			}
 		}
 	}
 
-	// check root module for references in first depth of attributes
-	for _, resource := range planJson.Configuration.RootModule.Resources {
+	// check the root module and the modules it calls for the references of the attributes which are not resolved
+	addModuleReferences(scanInput, planJson.Configuration.RootModule, "", scannedResources)
+
+	return scanInput
+}
+
+// addModuleReferences adds the references of the resources of a module of the configuration, of which the address
+// has no instance keys, to the scanned resources, then does the same for the modules it calls
+func addModuleReferences(scanInput TerraformScanInput, module TerraformPlanModule, moduleAddress string, scannedResources map[string][]TerraformPlanResource) {
+	for _, resource := range module.Resources {
 		// don't care about references in data sources for time being
 		if resource.Mode == "data" {
 			continue
 		}
 		mode := "resource"
-		// only update the references in resources that have some resolved attributes already
-		if resolvedResource, ok := scanInput[mode][resource.Type][getResourceName(resource)].(map[string]interface{}); ok && resolvedResource != nil {
-			expressions := getExpressions(resource.Expressions)
-			for k, v := range expressions {
+		expressions, ok := resource.Expressions.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, scannedResource := range scannedResources[joinAddress(moduleAddress, resource.Type+"."+resource.Name)] {
+			key := joinAddress(scannedResource.ModuleAddress, getResourceName(TerraformPlanResourceChange{TerraformPlanResource: scannedResource}))
+			// only update the references in resources that have some resolved attributes already
+			if resolvedResource, ok := scanInput[mode][resource.Type][key].(map[string]interface{}); ok && resolvedResource != nil {
+				references := map[string]interface{}{}
+				addReferences(resolvedResource, expressions, "", scannedResource.ModuleAddress, references)
+				if len(references) > 0 {
+					addResourceReferences(scanInput, resource.Type, key, references)
+				}
+			}
+		}
+	}
+
+	moduleNames := make([]string, 0, len(module.ModuleCalls))
+	for name := range module.ModuleCalls {
+		moduleNames = append(moduleNames, name)
+	}
+	sort.Strings(moduleNames)
+	for _, name := range moduleNames {
+		addModuleReferences(scanInput, module.ModuleCalls[name].Module, joinAddress(moduleAddress, "module."+name), scannedResources)
+	}
+}
+
+// addReferences walks the expressions of a resource alongside its resolved attributes, e.g. into the blocks of
+// server_side_encryption_configuration, and sets the attributes which are not resolved to the reference they are
+// computed from. The full lists of references are collected by the path of their attribute, e.g. rule[0].kms_master_key_id
+// The references of the resources of a child module are qualified with the address of the module instance,
+// e.g. module.logs.aws_kms_key.this, as they are relative to the module
+func addReferences(resolved map[string]interface{}, expressions map[string]interface{}, path string, moduleAddress string, references map[string]interface{}) {
+	for k, expression := range expressions {
+		attributePath := k
+		if path != "" {
+			attributePath = path + "." + k
+		}
+		switch expression := expression.(type) {
+		case map[string]interface{}:
+			if expressionReferences, ok := getReferences(expression); ok {
+				references[attributePath] = qualifyReferences(expressionReferences, moduleAddress)
 				// only add non existing attributes. If we already have resolved value do not overwrite it with reference
-				if _, ok := resolvedResource[k]; !ok {
-					resolvedResource[k] = v
+				if _, ok := resolved[k]; !ok {
+					resolved[k] = qualifyReference(getPreferredReference(expressionReferences), moduleAddress)
+				}
+				continue
+			}
+			// a single nested block, as opposed to a constant value
+			if _, ok := expression["constant_value"]; ok {
+				continue
+			}
+			if resolvedBlock, ok := resolved[k].(map[string]interface{}); ok {
+				addReferences(resolvedBlock, expression, attributePath, moduleAddress, references)
+			}
+		case []interface{}:
+			// the nested blocks, which are only walked where the resolved attributes hold them too
+			resolvedBlocks, ok := resolved[k].([]interface{})
+			if !ok {
+				continue
+			}
+			for i, block := range expression {
+				if i >= len(resolvedBlocks) {
+					break
+				}
+				blockExpressions, ok := block.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if resolvedBlock, ok := resolvedBlocks[i].(map[string]interface{}); ok {
+					addReferences(resolvedBlock, blockExpressions, fmt.Sprintf("%s[%d]", attributePath, i), moduleAddress, references)
 				}
 			}
-			scanInput[mode][resource.Type][getResourceName(resource)] = resolvedResource
 		}
 	}
+}
 
-	return scanInput
+// addResourceReferences keeps the full lists of references of a resource under the references key of the scan input,
+// e.g. references.aws_s3_bucket.logs["logging[0].target_bucket"], where only the resources with references are present
+func addResourceReferences(scanInput TerraformScanInput, resourceType string, key string, references map[string]interface{}) {
+	if _, ok := scanInput["references"][resourceType]; !ok {
+		scanInput["references"][resourceType] = map[string]interface{}{}
+	}
+	scanInput["references"][resourceType][key] = references
 }
 
-func getExpressions(expressions interface{}) map[string]interface{} {
-	result := make(map[string]interface{})
-	// expressions can be nested. we are only doing 1 depth to resolve top level depenencies
-	expressionMap, ok := expressions.(map[string]interface{})
-	if !ok {
-		return nil
+// getReferences returns the references of an expression, e.g. ["aws_kms_key.key.arn", "aws_kms_key.key"]
+func getReferences(expression map[string]interface{}) ([]interface{}, bool) {
+	references, ok := expression["references"].([]interface{})
+	if !ok || len(references) == 0 {
+		return nil, false
 	}
-	for k, v := range expressionMap {
-		referenceKey, ok := getReference(v)
-		if ok {
-			result[k] = referenceKey
+	return references, true
+}
+
+func qualifyReferences(references []interface{}, moduleAddress string) []interface{} {
+	qualifiedReferences := make([]interface{}, 0, len(references))
+	for _, reference := range references {
+		qualifiedReferences = append(qualifiedReferences, qualifyReference(reference, moduleAddress))
+	}
+	return qualifiedReferences
+}
+
+// qualifyReference prefixes a reference with the address of the module it is in, e.g. module.logs.aws_kms_key.this
+func qualifyReference(reference interface{}, moduleAddress string) interface{} {
+	if address, ok := reference.(string); ok {
+		return joinAddress(moduleAddress, address)
+	}
+	return reference
+}
+
+// getPreferredReference returns the most specific resource address among the references of an expression,
+// e.g. aws_instance.web[0] rather than aws_instance.web or aws_instance.web[0].id, or else the first reference
+// when none of them is a resource address, e.g. var.bucket_name
+func getPreferredReference(references []interface{}) interface{} {
+	preferred := references[0]
+	preferredDepth := 0
+	for _, reference := range references {
+		address, ok := reference.(string)
+		if !ok {
+			continue
+		}
+		if depth, ok := resourceAddressDepth(address); ok && depth > preferredDepth {
+			preferred = reference
+			preferredDepth = depth
 		}
 	}
-	return result
+	return preferred
 }
 
-// this is very naive implementation
-// the referenences can be composed of number of keys
-// we only going to use the first reference for time being
-func getReference(value interface{}) (interface{}, bool) {
-	v, ok := value.(map[string]interface{})
-	if !ok {
-		return "", false
+// resourceAddressDepth tells whether a reference is the address of a resource, e.g. aws_s3_bucket.logs,
+// aws_instance.web[0] or data.aws_caller_identity.current, and returns its number of steps after the data prefix,
+// which is larger for the addresses of resource instances
+func resourceAddressDepth(reference string) (int, bool) {
+	traversal, diags := hclsyntax.ParseTraversalAbs([]byte(reference), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return 0, false
 	}
-	// we are only interested with "references" values
-	referencesInt, ok := v["references"]
-	if !ok {
-		return "", false
+
+	nameStep := 1
+	switch traversal.RootName() {
+	case "var", "local", "module", "path", "terraform", "count", "each", "self":
+		return 0, false
+	case "data":
+		nameStep = 2
+	}
+	if len(traversal) <= nameStep {
+		return 0, false
 	}
-	references, ok := referencesInt.([]interface{})
-	if !ok {
-		return "", false
+	for i := 1; i <= nameStep; i++ {
+		if _, ok := traversal[i].(hcl.TraverseAttr); !ok {
+			return 0, false
+		}
 	}
-	return references[0], true
+	switch len(traversal) - nameStep - 1 {
+	case 0:
+	case 1:
+		// the instance key, as opposed to an attribute of the resource
+		if _, ok := traversal[nameStep+1].(hcl.TraverseIndex); !ok {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	return len(traversal) - nameStep + 1, true
 }
 
 func getResourceName(resource TerraformPlanResourceChange) string {
@@ -156,8 +365,53 @@ func getResourceName(resource TerraformPlanResourceChange) string {
 		// "name": "private",
 		// "index": "rtb-030b64d80cb5e9da7",
 		var indexKey string = mapResourceIndexToStringKey(resource.Index)
-		return fmt.Sprintf(`%s["%s"]`, resource.Name, indexKey)
+		return terraform.FormatInstanceName(resource.Name, indexKey)
+	}
+}
+
+// getResourceKey returns the key of a resource under its type, which is its name prefixed by the address of its module
+// when it is not in the root module, e.g. module.logs.this[0], so that the resources of different modules do not collide
+func getResourceKey(resource TerraformPlanResourceChange) string {
+	return joinAddress(resource.ModuleAddress, getResourceName(resource))
+}
+
+func joinAddress(moduleAddress string, address string) string {
+	if moduleAddress == "" {
+		return address
+	}
+	return moduleAddress + "." + address
+}
+
+// stripInstanceKeys removes the instance keys of a module address, e.g. module.a[0].module.b["x"] becomes module.a.module.b
+func stripInstanceKeys(address string) string {
+	var builder strings.Builder
+	depth := 0
+	inString := false
+	for i := 0; i < len(address); i++ {
+		c := address[i]
+		switch {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		case c == '"' && depth > 0:
+			inString = true
+			continue
+		case c == '[':
+			depth++
+			continue
+		case c == ']':
+			depth--
+			continue
+		}
+		if depth == 0 {
+			builder.WriteByte(c)
+		}
 	}
+	return builder.String()
 }
 
 func mapResourceIndexToStringKey(resourceIndex interface{}) string {
@@ -180,13 +434,47 @@ func mapResourceIndexToStringKey(resourceIndex interface{}) string {
 	return indexKey
 }
 
-func isValidResourceActions(resourceAction ResourceActions, isFullScan bool) bool {
-	var validActions []ResourceActions
-	if isFullScan {
-		validActions = getValidResourceActionsForFullScan()
-	} else {
-		validActions = getValidResourceActionsForDeltaScan()
+// getResourceAddress returns the address of a resource, which older plans may not hold
+func getResourceAddress(resource TerraformPlanResourceChange) string {
+	if resource.Address != "" {
+		return resource.Address
+	}
+	address := resource.Type + "." + getResourceName(resource)
+	if resource.Mode == "data" {
+		address = "data." + address
 	}
+	return address
+}
+
+// matchAddress matches a resource address against a glob pattern, in which only * and ? are special
+// as the other glob characters, e.g. [, are part of the addresses
+// On a mismatch, only the last * is retried against a longer part of the address, which keeps the matching linear
+// in the number of stars rather than exponential
+func matchAddress(pattern string, address string) bool {
+	patternIndex, addressIndex := 0, 0
+	starIndex, starAddressIndex := -1, 0
+	for addressIndex < len(address) {
+		switch {
+		case patternIndex < len(pattern) && pattern[patternIndex] == '*':
+			starIndex, starAddressIndex = patternIndex, addressIndex
+			patternIndex++
+		case patternIndex < len(pattern) && (pattern[patternIndex] == '?' || pattern[patternIndex] == address[addressIndex]):
+			patternIndex++
+			addressIndex++
+		case starIndex != -1:
+			starAddressIndex++
+			patternIndex, addressIndex = starIndex+1, starAddressIndex
+		default:
+			return false
+		}
+	}
+	for patternIndex < len(pattern) && pattern[patternIndex] == '*' {
+		patternIndex++
+	}
+	return patternIndex == len(pattern)
+}
+
+func isValidResourceActions(resourceAction ResourceActions, validActions []ResourceActions) bool {
 	for _, validAction := range validActions {
 		if reflect.DeepEqual(validAction, resourceAction) {
 			return true
@@ -196,13 +484,32 @@ func isValidResourceActions(resourceAction ResourceActions, isFullScan bool) boo
 }
 
 func ParseTerraformPlan(p []byte, v *interface{}) error {
+	// Currently being used only by Terraform Cloud integration
+	// It was decided that using Full Scan as the default scan is the right approach
+	return ParseTerraformPlanWithOptions(p, v, DefaultTerraformPlanOptions())
+}
+
+// ParseTerraformPlanWithOptions parses a Terraform plan, scanning the resource changes selected by the options
+func ParseTerraformPlanWithOptions(p []byte, v *interface{}, options TerraformPlanOptions) error {
+	switch options.ScanMode {
+	case "", FULL_SCAN, DELTA_SCAN:
+	default:
+		return errors.Errorf("unknown scan mode %q", options.ScanMode)
+	}
+
 	var tfPlanJson TerraformPlanJson
 	if err := json.Unmarshal(p, &tfPlanJson); err != nil {
 		return errors.Wrap(err, "failed to parse terraform-plan json payload")
 	}
-	// Currently being used only by Terraform Cloud integration
-	// It was decided that using Full Scan as the default scan is the right approach
-	// In the future this will be configurable
-	*v = parseTerraformPlan(tfPlanJson, true)
+	*v = parseTerraformPlan(tfPlanJson, options)
 	return nil
 }
+
+// ParseTerraformPlanWithSuppressions parses a Terraform plan like ParseTerraformPlan
+// and also returns the suppressions found in its sidecar file, which may be empty.
+func ParseTerraformPlanWithSuppressions(p []byte, sidecar []byte, v *interface{}) (Suppressions, error) {
+	if err := ParseTerraformPlan(p, v); err != nil {
+		return nil, err
+	}
+	return extractTerraformPlanSuppressions(sidecar), nil
+}
@@ -7,6 +7,7 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
@@ -50,7 +51,7 @@ func testPlanFile(t *testing.T, fileName, root string, wg *sync.WaitGroup) {
 		}
 		for scanMode, expectedResultsFolder := range scanModeResultsMap {
 			t.Run(fmt.Sprintf("%v scan for %s", scanMode, fileName), func(t *testing.T) {
-				parsedPlan := parseTerraformPlan(planJson, "full" == scanMode)
+				parsedPlan := parseTerraformPlan(planJson, TerraformPlanOptions{ScanMode: ScanMode(scanMode)})
 				expectedResult, err := getExpectedResult(fileName, expectedResultsFolder)
 				if err != nil {
 					t.Errorf("%v, failed with file %s, with scan type %s", err, fileName, scanMode)
@@ -80,3 +81,342 @@ func TestTerraformPlanParser(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestParseTerraformPlanWithOptions(t *testing.T) {
+	plan := []byte(`{
+	"resource_changes": [
+		{
+			"address": "aws_s3_bucket.logs",
+			"mode": "managed",
+			"type": "aws_s3_bucket",
+			"name": "logs",
+			"change": {"actions": ["no-op"], "after": {"bucket": "logs"}}
+		},
+		{
+			"address": "aws_s3_bucket.data",
+			"mode": "managed",
+			"type": "aws_s3_bucket",
+			"name": "data",
+			"change": {"actions": ["create"], "after": {"bucket": "data"}}
+		},
+		{
+			"address": "aws_s3_bucket_public_access_block.logs",
+			"mode": "managed",
+			"type": "aws_s3_bucket_public_access_block",
+			"name": "logs",
+			"change": {"actions": ["delete"], "before": {"bucket": "logs"}, "after": null}
+		},
+		{
+			"address": "aws_route.private[\"rtb-1\"]",
+			"mode": "managed",
+			"type": "aws_route",
+			"name": "private",
+			"index": "rtb-1",
+			"change": {"actions": ["update"], "after": {"route_table_id": "rtb-1"}}
+		}
+	]
+}`)
+
+	testTable := []struct {
+		name     string
+		options  TerraformPlanOptions
+		expected []string
+	}{
+		{
+			name:     "full scan",
+			options:  DefaultTerraformPlanOptions(),
+			expected: []string{"aws_route.private[\"rtb-1\"]", "aws_s3_bucket.data", "aws_s3_bucket.logs"},
+		},
+		{
+			name:     "delta scan",
+			options:  TerraformPlanOptions{ScanMode: DELTA_SCAN},
+			expected: []string{"aws_route.private[\"rtb-1\"]", "aws_s3_bucket.data"},
+		},
+		{
+			name:     "allow-list of actions",
+			options:  TerraformPlanOptions{Actions: []ResourceActions{{"delete"}}},
+			expected: []string{"aws_s3_bucket_public_access_block.logs"},
+		},
+		{
+			name:     "included and excluded addresses",
+			options:  TerraformPlanOptions{IncludeAddresses: []string{"aws_s3_bucket.*", "aws_route.private[\"rtb-?\"]"}, ExcludeAddresses: []string{"*.logs"}},
+			expected: []string{"aws_route.private[\"rtb-1\"]", "aws_s3_bucket.data"},
+		},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.name, func(t *testing.T) {
+			var actual interface{}
+			if err := ParseTerraformPlanWithOptions(plan, &actual, tc.options); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var addresses []string
+			for resourceType, resources := range actual.(TerraformScanInput)["resource"] {
+				for name := range resources {
+					addresses = append(addresses, resourceType+"."+name)
+				}
+			}
+			sort.Strings(addresses)
+			if !reflect.DeepEqual(addresses, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, addresses)
+			}
+		})
+	}
+
+	var actual interface{}
+	if err := ParseTerraformPlanWithOptions(plan, &actual, TerraformPlanOptions{ScanMode: "partial"}); err == nil {
+		t.Errorf("expected an error for an unknown scan mode")
+	}
+}
+
+func TestParseTerraformPlanWithModules(t *testing.T) {
+	plan := []byte(`{
+	"resource_changes": [
+		{
+			"address": "aws_s3_bucket.this",
+			"mode": "managed",
+			"type": "aws_s3_bucket",
+			"name": "this",
+			"change": {"actions": ["create"], "after": {"bucket": "root"}}
+		},
+		{
+			"address": "module.logs.aws_s3_bucket.this",
+			"module_address": "module.logs",
+			"mode": "managed",
+			"type": "aws_s3_bucket",
+			"name": "this",
+			"change": {"actions": ["create"], "after": {"bucket": "logs"}}
+		},
+		{
+			"address": "module.data[\"eu\"].module.bucket.aws_s3_bucket.this[0]",
+			"module_address": "module.data[\"eu\"].module.bucket",
+			"mode": "managed",
+			"type": "aws_s3_bucket",
+			"name": "this",
+			"index": 0,
+			"change": {"actions": ["create"], "after": {"bucket": "data"}}
+		}
+	],
+	"configuration": {
+		"root_module": {
+			"resources": [
+				{
+					"address": "aws_s3_bucket.this",
+					"mode": "managed",
+					"type": "aws_s3_bucket",
+					"name": "this",
+					"expressions": {"policy": {"references": ["aws_iam_policy_document.root"]}}
+				}
+			],
+			"module_calls": {
+				"logs": {
+					"module": {
+						"resources": [
+							{
+								"address": "aws_s3_bucket.this",
+								"mode": "managed",
+								"type": "aws_s3_bucket",
+								"name": "this",
+								"expressions": {"policy": {"references": ["aws_iam_policy_document.logs"]}}
+							}
+						]
+					}
+				},
+				"data": {
+					"module": {
+						"module_calls": {
+							"bucket": {
+								"module": {
+									"resources": [
+										{
+											"address": "aws_s3_bucket.this",
+											"mode": "managed",
+											"type": "aws_s3_bucket",
+											"name": "this",
+											"expressions": {"policy": {"references": ["aws_iam_policy_document.data"]}}
+										}
+									]
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}`)
+
+	var actual interface{}
+	if err := ParseTerraformPlan(plan, &actual); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"this": map[string]interface{}{
+			"bucket": "root",
+			"policy": "aws_iam_policy_document.root",
+		},
+		"module.logs.this": map[string]interface{}{
+			"bucket": "logs",
+			"policy": "module.logs.aws_iam_policy_document.logs",
+		},
+		`module.data["eu"].module.bucket.this["0"]`: map[string]interface{}{
+			"bucket": "data",
+			"policy": `module.data["eu"].module.bucket.aws_iam_policy_document.data`,
+		},
+	}
+	if buckets := actual.(TerraformScanInput)["resource"]["aws_s3_bucket"]; !reflect.DeepEqual(buckets, expected) {
+		t.Errorf("expected %v, got %v", expected, buckets)
+	}
+
+	// the references of the resources of the child modules are qualified with the address of their module
+	expectedReferences := map[string]interface{}{
+		"this": map[string]interface{}{
+			"policy": []interface{}{"aws_iam_policy_document.root"},
+		},
+		"module.logs.this": map[string]interface{}{
+			"policy": []interface{}{"module.logs.aws_iam_policy_document.logs"},
+		},
+		`module.data["eu"].module.bucket.this["0"]`: map[string]interface{}{
+			"policy": []interface{}{`module.data["eu"].module.bucket.aws_iam_policy_document.data`},
+		},
+	}
+	if references := actual.(TerraformScanInput)["references"]["aws_s3_bucket"]; !reflect.DeepEqual(references, expectedReferences) {
+		t.Errorf("expected %v, got %v", expectedReferences, references)
+	}
+}
+
+func TestParseTerraformPlanWithNestedReferences(t *testing.T) {
+	plan := []byte(`{
+	"resource_changes": [
+		{
+			"address": "aws_s3_bucket.logs",
+			"mode": "managed",
+			"type": "aws_s3_bucket",
+			"name": "logs",
+			"change": {
+				"actions": ["create"],
+				"after": {
+					"bucket": "logs",
+					"server_side_encryption_configuration": [
+						{"rule": [{"apply_server_side_encryption_by_default": [{"sse_algorithm": "aws:kms"}]}]}
+					]
+				}
+			}
+		}
+	],
+	"configuration": {
+		"root_module": {
+			"resources": [
+				{
+					"address": "aws_s3_bucket.logs",
+					"mode": "managed",
+					"type": "aws_s3_bucket",
+					"name": "logs",
+					"expressions": {
+						"bucket": {"constant_value": "logs"},
+						"policy": {"references": ["data.aws_iam_policy_document.logs.json", "data.aws_iam_policy_document.logs"]},
+						"server_side_encryption_configuration": [
+							{
+								"rule": [
+									{
+										"apply_server_side_encryption_by_default": [
+											{
+												"kms_master_key_id": {"references": ["aws_kms_key.logs[0].arn", "aws_kms_key.logs[0]", "aws_kms_key.logs"]},
+												"sse_algorithm": {"constant_value": "aws:kms"}
+											}
+										]
+									}
+								]
+							}
+						]
+					}
+				}
+			]
+		}
+	}
+}`)
+
+	var actual interface{}
+	if err := ParseTerraformPlan(plan, &actual); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedResource := map[string]interface{}{
+		"bucket": "logs",
+		"policy": "data.aws_iam_policy_document.logs",
+		"server_side_encryption_configuration": []interface{}{
+			map[string]interface{}{
+				"rule": []interface{}{
+					map[string]interface{}{
+						"apply_server_side_encryption_by_default": []interface{}{
+							map[string]interface{}{
+								"kms_master_key_id": "aws_kms_key.logs[0]",
+								"sse_algorithm":     "aws:kms",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	scanInput := actual.(TerraformScanInput)
+	if resource := scanInput["resource"]["aws_s3_bucket"]["logs"]; !reflect.DeepEqual(resource, expectedResource) {
+		t.Errorf("expected %v, got %v", expectedResource, resource)
+	}
+
+	expectedReferences := map[string]interface{}{
+		"policy": []interface{}{"data.aws_iam_policy_document.logs.json", "data.aws_iam_policy_document.logs"},
+		"server_side_encryption_configuration[0].rule[0].apply_server_side_encryption_by_default[0].kms_master_key_id": []interface{}{
+			"aws_kms_key.logs[0].arn", "aws_kms_key.logs[0]", "aws_kms_key.logs",
+		},
+	}
+	if references := scanInput["references"]["aws_s3_bucket"]["logs"]; !reflect.DeepEqual(references, expectedReferences) {
+		t.Errorf("expected %v, got %v", expectedReferences, references)
+	}
+}
+
+func TestGetPreferredReference(t *testing.T) {
+	testTable := []struct {
+		references []interface{}
+		expected   interface{}
+	}{
+		{references: []interface{}{"aws_s3_bucket.logs.id", "aws_s3_bucket.logs"}, expected: "aws_s3_bucket.logs"},
+		{references: []interface{}{"aws_instance.web", "aws_instance.web[\"a\"]"}, expected: "aws_instance.web[\"a\"]"},
+		{references: []interface{}{"var.region", "data.aws_region.current"}, expected: "data.aws_region.current"},
+		{references: []interface{}{"aws_codebuild_project.ci", "data.aws_caller_identity.current"}, expected: "aws_codebuild_project.ci"},
+		{references: []interface{}{"module.vpc.vpc_id", "module.vpc"}, expected: "module.vpc.vpc_id"},
+		{references: []interface{}{"local.name", "each.key"}, expected: "local.name"},
+	}
+
+	for _, tc := range testTable {
+		if actual := getPreferredReference(tc.references); actual != tc.expected {
+			t.Errorf("expected %v for %v, got %v", tc.expected, tc.references, actual)
+		}
+	}
+}
+
+func TestMatchAddress(t *testing.T) {
+	testTable := []struct {
+		pattern  string
+		address  string
+		expected bool
+	}{
+		{pattern: "aws_s3_bucket.*", address: "aws_s3_bucket.logs", expected: true},
+		{pattern: "module.*.aws_s3_bucket.logs", address: "module.storage.aws_s3_bucket.logs", expected: true},
+		{pattern: "aws_instance.web[?]", address: "aws_instance.web[0]", expected: true},
+		{pattern: "aws_instance.web[?]", address: "aws_instance.web[10]", expected: false},
+		{pattern: "*logs*", address: "aws_s3_bucket.logs", expected: true},
+		{pattern: "*", address: "", expected: true},
+		{pattern: "aws_s3_bucket.*", address: "aws_kms_key.logs", expected: false},
+		// many stars which fail to match at the end of a long address do not backtrack exponentially
+		{pattern: strings.Repeat("*a", 30) + "b", address: strings.Repeat("a", 100), expected: false},
+	}
+
+	for _, tc := range testTable {
+		if actual := matchAddress(tc.pattern, tc.address); actual != tc.expected {
+			t.Errorf("expected %v for %s against %s, got %v", tc.expected, tc.address, tc.pattern, actual)
+		}
+	}
+}
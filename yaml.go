@@ -24,6 +24,15 @@ func ParseYAML(p []byte, v interface{}) error {
 	return nil
 }
 
+// ParseYAMLWithSuppressions unmarshals YAML files like ParseYAML
+// and also returns the suppressions found in their comments.
+func ParseYAMLWithSuppressions(p []byte, v interface{}) (Suppressions, error) {
+	if err := ParseYAML(p, v); err != nil {
+		return nil, err
+	}
+	return extractYAMLSuppressions(p), nil
+}
+
 func separateSubDocuments(data []byte) [][]byte {
 	linebreak := "\n"
 	if bytes.Contains(data, []byte("\r\n---\r\n")) {